@@ -11,11 +11,9 @@ type AccountProofCircuit struct {
 	AddressHash          [64]frontend.Variable `gnark:",public"`
 	KeyFragmentStarts    [mpt.AccountMPTMaxDepth]frontend.Variable
 	AddressRlp           [228]frontend.Variable
-	LeafRlp              [272 * 2]frontend.Variable // maxRlpLength = 304 ===> 272 * 2
-	LeafRoundIndex       frontend.Variable
+	LeafRlp              [mpt.AccountLeafMaxBlockHexLen]frontend.Variable // maxRlpLength = 304 ===> 272 * 2
 	LeafPathPrefixLength frontend.Variable
 	NodeRlp              [mpt.AccountMPTMaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	NodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	NodeTypes            [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	Depth                frontend.Variable
@@ -37,10 +35,8 @@ func (c *AccountProofCircuit) Define(api frontend.API) error {
 		c.KeyFragmentStarts[:],
 		c.AddressRlp,
 		c.LeafRlp[:],
-		c.LeafRoundIndex,
 		c.LeafPathPrefixLength,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength[:],
 		c.NodeTypes[:],
 		c.Depth,
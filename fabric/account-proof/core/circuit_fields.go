@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/consensys/gnark/frontend"
+)
+
+// AccountFieldsProofCircuit is the AccountProofCircuit variant that also exposes the decoded account fields
+type AccountFieldsProofCircuit struct {
+	// Input
+	StateRoot            [64]frontend.Variable `gnark:",public"`
+	AddressHash          [64]frontend.Variable `gnark:",public"`
+	KeyFragmentStarts    [mpt.AccountMPTMaxDepth]frontend.Variable
+	AddressRlp           [228]frontend.Variable
+	LeafRlp              [mpt.AccountLeafMaxBlockHexLen]frontend.Variable // maxRlpLength = 304 ===> 272 * 2
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [mpt.AccountMPTMaxDepth - 1][272 * 4]frontend.Variable
+	NodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable
+	NodeTypes            [mpt.AccountMPTMaxDepth - 1]frontend.Variable
+	Depth                frontend.Variable
+
+	// Output
+	Nonce       frontend.Variable     `gnark:",public"`
+	Balance     frontend.Variable     `gnark:",public"`
+	StorageRoot [64]frontend.Variable `gnark:",public"`
+	CodeHash    [2]frontend.Variable  `gnark:",public"` // big endian upper and lower 128 bits
+}
+
+func (c *AccountFieldsProofCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := 0; i < len(c.NodeRlp); i++ {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	result := mpt.CheckEthAccountProof(
+		api,
+		mpt.AccountMPTMaxDepth,
+		c.StateRoot,
+		c.AddressHash,
+		c.KeyFragmentStarts[:],
+		c.AddressRlp,
+		c.LeafRlp[:],
+		c.LeafPathPrefixLength,
+		nodeRlp,
+		c.NodePathPrefixLength[:],
+		c.NodeTypes[:],
+		c.Depth,
+	)
+	api.AssertIsEqual(result.Output, 1)
+
+	api.AssertIsEqual(result.NonceValue, c.Nonce)
+	api.AssertIsEqual(result.BalanceValue, c.Balance)
+	for i := 0; i < 64; i++ {
+		api.AssertIsEqual(result.StorageRoot[i], c.StorageRoot[i])
+	}
+	for i := 0; i < 2; i++ {
+		api.AssertIsEqual(result.CodeHashLimbs[i], c.CodeHash[i])
+	}
+
+	return nil
+}
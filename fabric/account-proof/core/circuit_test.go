@@ -0,0 +1,88 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAccountFieldsProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	sdb, err := state.New(common.Hash{}, db, nil)
+	assert.NoError(err)
+	for i := 0; i < 100; i++ {
+		addr := common.BytesToAddress(crypto.Keccak256(big.NewInt(int64(i)).Bytes()))
+		sdb.SetNonce(addr, uint64(i))
+		sdb.SetBalance(addr, big.NewInt(int64(i+1)*1e15))
+	}
+	maxBalance, _ := new(big.Int).SetString("ffffffffffffffffffffffff", 16)
+	accounts := []struct {
+		address common.Address
+		nonce   uint64
+		balance *big.Int
+		code    []byte
+	}{
+		{common.HexToAddress("0x881D40237659C251811CEC9c364ef91dC08D300C"), 1, new(big.Int).SetUint64(0x91a55d622bd2bb4a), []byte{0x60, 0x80, 0x60, 0x40, 0x52}},
+		{common.HexToAddress("0x690b9a9e9aa1c9db991c7721a92d351db4fac990"), 0xffffffffffffffff, maxBalance, nil},
+		// empty accounts are deleted from the state, code keeps a zero nonce and balance account alive
+		{common.HexToAddress("0x0000000000000000000000000000000000000001"), 0, big.NewInt(0), []byte{0x00}},
+	}
+	for _, account := range accounts {
+		sdb.SetNonce(account.address, account.nonce)
+		sdb.SetBalance(account.address, account.balance)
+		sdb.SetCode(account.address, account.code)
+	}
+	root, err := sdb.Commit(true)
+	assert.NoError(err)
+	sdb, err = state.New(root, db, nil)
+	assert.NoError(err)
+
+	for _, account := range accounts {
+		proof, err := sdb.GetProof(account.address)
+		assert.NoError(err)
+		addressHash := crypto.Keccak256(account.address.Bytes())
+		p, err := witness.NewProof(proof, witness.BytesToNibbles(addressHash), mpt.AccountKeyLength, mpt.AccountMPTMaxDepth)
+		assert.NoError(err)
+		leafRlp, err := p.LeafRlp(272 * 2)
+		assert.NoError(err)
+
+		codeHash := sdb.GetCodeHash(account.address)
+		w := &AccountFieldsProofCircuit{
+			LeafPathPrefixLength: p.Leaf.PathPrefixLength,
+			Depth:                p.Depth,
+			Nonce:                account.nonce,
+			Balance:              account.balance,
+			CodeHash:             [2]frontend.Variable{codeHash[:16], codeHash[16:]},
+		}
+		copy(w.StateRoot[:], witness.NibblesToVariables(witness.BytesToNibbles(root.Bytes()), 64))
+		copy(w.AddressHash[:], witness.NibblesToVariables(witness.BytesToNibbles(addressHash), 64))
+		copy(w.KeyFragmentStarts[:], p.KeyFragmentStarts)
+		copy(w.AddressRlp[:], witness.NibblesToVariables(witness.BytesToNibbles(p.Leaf.Value), 228))
+		copy(w.LeafRlp[:], leafRlp)
+		for i := range w.NodeRlp {
+			copy(w.NodeRlp[i][:], p.NodeRlp[i])
+		}
+		copy(w.NodePathPrefixLength[:], p.NodePathPrefixLength)
+		copy(w.NodeTypes[:], p.NodeTypes)
+		copy(w.StorageRoot[:], witness.NibblesToVariables(witness.BytesToNibbles(types.EmptyRootHash.Bytes()), 64))
+
+		err = test.IsSolved(&AccountFieldsProofCircuit{}, w, ecc.BN254.ScalarField())
+		assert.NoError(err, account.address.Hex())
+
+		w.Balance = new(big.Int).Add(account.balance, big.NewInt(1))
+		err = test.IsSolved(&AccountFieldsProofCircuit{}, w, ecc.BN254.ScalarField())
+		assert.Error(err, account.address.Hex())
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"strconv"
 
 	"github.com/celer-network/brevis-circuits/fabric/account-proof/core"
-	"github.com/celer-network/brevis-circuits/gadgets/keccak"
 	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
 	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/groth16"
@@ -27,8 +27,6 @@ type AccountInfo struct {
 
 func main() {
 
-	depth := 9
-
 	keyRlpHexString := "8d2a6e4b67bce79287a24c5e8453dee4b1c363dfccc5960e98b02dc0f56374bf"
 	keyHexLen := len(keyRlpHexString)
 	var keyRlpHex [mpt.AccountKeyLength]frontend.Variable
@@ -60,42 +58,7 @@ func main() {
 		accountProofRootHashHex[i] = intValue
 	}
 
-	var keyFragmentStarts [mpt.AccountMPTMaxDepth]frontend.Variable
-	for i := 0; i < mpt.AccountMPTMaxDepth; i++ {
-		keyFragmentStarts[i] = i
-		if i > depth-1 {
-			keyFragmentStarts[i] = 64
-		}
-	}
-
 	leafRlpHexString := "0xf8669d2067bce79287a24c5e8453dee4b1c363dfccc5960e98b02dc0f56374bfb846f8440280a01eb0e8ed889315b2a7f6e076d0939a6ed1fe4e3d9b0eeb366c47ec5e8a52fd3fa0cc34a85a74e46f422c2b06b16156799b7c313a71390b4465cbc463bd99d76764"
-	// pad leaf,And convert it to nibbles
-	leafRlpBytes, _ := hexutil.Decode(leafRlpHexString)
-	paddedLeafRlpBytes := keccak.Pad101Bytes(leafRlpBytes)
-
-	var paddedLeafRlpHex [272 * 2]frontend.Variable
-	for i, b := range paddedLeafRlpBytes {
-		n1 := b >> 4
-		n2 := b & 0x0F
-
-		paddedLeafRlpHex[i*2] = n1
-		paddedLeafRlpHex[i*2+1] = n2
-	}
-
-	for i := len(paddedLeafRlpBytes) * 2; i < 272*2; i++ {
-		paddedLeafRlpHex[i] = 0
-	}
-
-	leafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(leafRlpHexString) - 2)
-
-	/// 64 - (depth - 1) ===> length of nibbles represented by branch/extension node
-	/// depth = len(accountProof[])/len(storageProof[])
-	var leafPathPrefixLength int // 20 67bce79287a24c5e8453dee4b1c363dfccc5960e98b02dc0f56374bf
-	if (64-depth+1)%2 == 0 {
-		leafPathPrefixLength = 2
-	} else {
-		leafPathPrefixLength = 1
-	}
 
 	/// Proofs without leaf level ---> accountProof[0:len(accountProof) - 1]
 	nodeRlpHexStrings := []string{
@@ -109,48 +72,34 @@ func main() {
 		"0xf85180808080a0deaab39c886e0601409672763ab78663fbc0aa328689b2c553eb1515b4c4e460808080808080a00c7eab0286642501eb5d88954aa73ba3bd67a8b79137972c774f6212b4f697578080808080",
 	}
 
-	realDataLength := len(nodeRlpHexStrings)
+	var proofNodes [][]byte
+	for _, rlpHexString := range append(nodeRlpHexStrings, leafRlpHexString) {
+		node, _ := hexutil.Decode(rlpHexString)
+		proofNodes = append(proofNodes, node)
+	}
+	addressHash, _ := hexutil.Decode("0x" + keyRlpHexString)
+	proofWitness, err := witness.NewProof(proofNodes, witness.BytesToNibbles(addressHash), mpt.AccountKeyLength, mpt.AccountMPTMaxDepth)
+	if err != nil {
+		log.Fatal("failed to prepare the account proof witness", err)
+	}
+	leafRlp, err := proofWitness.LeafRlp(272 * 2)
+	if err != nil {
+		log.Fatal("failed to prepare the account proof witness", err)
+	}
+
+	var keyFragmentStarts [mpt.AccountMPTMaxDepth]frontend.Variable
+	copy(keyFragmentStarts[:], proofWitness.KeyFragmentStarts)
+	var paddedLeafRlpHex [272 * 2]frontend.Variable
+	copy(paddedLeafRlpHex[:], leafRlp)
 
 	var nodeRlp [mpt.AccountMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	var nodeTypes [mpt.AccountMPTMaxDepth - 1]frontend.Variable
-
 	for i := 0; i < mpt.AccountMPTMaxDepth-1; i++ {
-		if i < realDataLength {
-			// Feed actual data
-			rlpHexString := nodeRlpHexStrings[i]
-			bytes, _ := hexutil.Decode(rlpHexString)
-			paddedBytes := keccak.Pad101Bytes(bytes)
-			var rlp [mpt.BranchNodeMaxBlockSize]frontend.Variable
-			for i, b := range paddedBytes {
-				n1 := b >> 4
-				n2 := b & 0x0F
-				rlp[i*2] = n1
-				rlp[i*2+1] = n2
-			}
-
-			for j := len(paddedBytes) * 2; j < 272*4; j++ {
-				rlp[j] = 0
-			}
-
-			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
-		} else {
-			// Add placeholder data
-			var empty [1088]frontend.Variable
-			for j := 0; j < 1088; j++ {
-				empty[j] = 0
-			}
-			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
-		}
-
-		// TODO: calculate prefix length and node types dynamically
-		nodePathPrefixLength[i] = 0
-		nodeTypes[i] = 0
+		copy(nodeRlp[i][:], proofWitness.NodeRlp[i])
 	}
+	copy(nodePathPrefixLength[:], proofWitness.NodePathPrefixLength)
+	copy(nodeTypes[:], proofWitness.NodeTypes)
 
 	leafRlpInBytes, _ := hexutil.Decode("0x" + valueRlpHexString)
 	var accountInfo AccountInfo
@@ -171,13 +120,11 @@ func main() {
 		KeyFragmentStarts:    keyFragmentStarts,
 		AddressRlp:           valueRlpHex,
 		LeafRlp:              paddedLeafRlpHex,
-		LeafRoundIndex:       leafRlpRoundIndex,
-		LeafPathPrefixLength: leafPathPrefixLength,
+		LeafPathPrefixLength: proofWitness.Leaf.PathPrefixLength,
 		NodeRlp:              nodeRlp,
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
 		NodePathPrefixLength: nodePathPrefixLength,
 		NodeTypes:            nodeTypes,
-		Depth:                depth,
+		Depth:                proofWitness.Depth,
 
 		// Output
 		StorageRoot: storageRoot,
@@ -195,10 +142,10 @@ func main() {
 		log.Fatal("groth16.Setup")
 	}
 
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, _ := witness.Public()
+	fullWitness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	publicWitness, _ := fullWitness.Public()
 
-	proof, err := groth16.Prove(ccs, pk, witness)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
 	if err != nil {
 		debug.PrintStack()
 		log.Fatal("prove computation failed...", err)
@@ -7,33 +7,34 @@ import (
 )
 
 type EthAddressStorageProof struct {
-	BlockHash                   [2]frontend.Variable `gnark:",public"`
-	AddressProofKey             [2]frontend.Variable `gnark:",public"` // padded address hash
-	Slot                        [2]frontend.Variable `gnark:",public"`
-	SlotValue                   [2]frontend.Variable `gnark:",public"`
-	BlockNumber                 frontend.Variable    `gnark:",public"`
+	BlockHash       [2]frontend.Variable `gnark:",public"`
+	AddressProofKey [2]frontend.Variable `gnark:",public"` // padded address hash
+	Slot            [2]frontend.Variable `gnark:",public"`
+	SlotValue       [2]frontend.Variable `gnark:",public"`
+	BlockNumber     frontend.Variable    `gnark:",public"`
+	AddressStorageBlockProof
+}
+
+// AddressStorageBlockProof is the block header, account trie and storage trie witness of EthAddressStorageProof and
+// EthAddressStorageChunkProof
+type AddressStorageBlockProof struct {
 	BlockHashRlp                [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
 	BlockRlpFieldNum            frontend.Variable
-	BlockRoundIndex             frontend.Variable
 	AddressKeyFragmentStarts    [mpt.AccountMPTMaxDepth]frontend.Variable // [addressMaxDepth]
 	AddressRlp                  [mpt.MaxValueLengthForAccount]frontend.Variable
-	AddressLeafRlp              [mpt.StorageLeafMaxBlockHexLen * 2]frontend.Variable // [addressMaxLeafRlpLength]
-	AddressLeafRoundIndex       frontend.Variable
+	AddressLeafRlp              [mpt.AccountLeafMaxBlockHexLen]frontend.Variable // [addressMaxLeafRlpLength]
 	AddressLeafPathPrefixLength frontend.Variable
 	AddressNodeRlp              [mpt.AccountMPTMaxDepth - 1][mpt.StorageLeafMaxBlockHexLen * 4]frontend.Variable // [addressMaxDepth - 1][addressMaxBranchRlpLength]
-	AddressNodeRlpRoundIndexes  [mpt.AccountMPTMaxDepth - 1]frontend.Variable
-	AddressNodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable // [addressMaxDepth - 1]
-	AddressNodeTypes            [mpt.AccountMPTMaxDepth - 1]frontend.Variable // [addressMaxDepth - 1]
+	AddressNodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable                                    // [addressMaxDepth - 1]
+	AddressNodeTypes            [mpt.AccountMPTMaxDepth - 1]frontend.Variable                                    // [addressMaxDepth - 1]
 	AddressDepth                frontend.Variable
 	StorageKeyFragmentStarts    [mpt.StorageMPTMaxDepth]frontend.Variable // [storageMaxDepth]
 	StorageValueRlp             [66]frontend.Variable
 	StorageLeafRlp              [mpt.StorageLeafMaxBlockHexLen]frontend.Variable // [storageMaxLeafRlpLength]
-	StorageLeafRoundIndex       frontend.Variable
 	StorageLeafPathPrefixLength frontend.Variable
 	StorageNodeRlp              [mpt.StorageMPTMaxDepth - 1][mpt.StorageLeafMaxBlockHexLen * 4]frontend.Variable // [storageMaxDepth - 1][storageMaxBranchRlpLength]
-	StorageNodeRlpRoundIndex    [mpt.StorageMPTMaxDepth - 1]frontend.Variable
-	StorageNodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable // [storageMaxDepth - 1]
-	StorageNodeTypes            [mpt.StorageMPTMaxDepth - 1]frontend.Variable // [storageMaxDepth - 1]
+	StorageNodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable                                    // [storageMaxDepth - 1]
+	StorageNodeTypes            [mpt.StorageMPTMaxDepth - 1]frontend.Variable                                    // [storageMaxDepth - 1]
 	StorageProofDepth           frontend.Variable
 }
 
@@ -91,27 +92,22 @@ func (c *EthAddressStorageProof) Define(api frontend.API) error {
 		8,
 		blockHashNibbles,
 		c.BlockRlpFieldNum,
-		c.BlockRoundIndex,
 		addressProofKeyNibbles,
 		slotNibbles,
 		c.BlockHashRlp,
 		c.AddressKeyFragmentStarts[:],
 		c.AddressRlp,
 		c.AddressLeafRlp[:],
-		c.AddressLeafRoundIndex,
 		c.AddressLeafPathPrefixLength,
 		addressNodeRlp,
-		c.AddressNodeRlpRoundIndexes,
 		c.AddressNodePathPrefixLength[:],
 		c.AddressNodeTypes[:],
 		c.AddressDepth,
 		c.StorageKeyFragmentStarts[:],
 		c.StorageValueRlp,
 		c.StorageLeafRlp[:],
-		c.StorageLeafRoundIndex,
 		c.StorageLeafPathPrefixLength,
 		storageNodeRlp,
-		c.StorageNodeRlpRoundIndex[:],
 		c.StorageNodePathPrefixLength[:],
 		c.StorageNodeTypes[:],
 		c.StorageProofDepth,
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// EthAddressStorageChunkProof is EthAddressStorageProof against the ChunkRoot of a chunk of headers instead of a
+// block hash. The block hash is private, it is the leaf of index BlockIndex in the tree of the chunk with the
+// siblings ChunkProof. The depth of the chunk is fixed when the circuit is compiled, use
+// NewEthAddressStorageChunkProof to allocate both the circuit and its assignment.
+type EthAddressStorageChunkProof struct {
+	ChunkRoot       [2]frontend.Variable `gnark:",public"`
+	AddressProofKey [2]frontend.Variable `gnark:",public"` // padded address hash
+	Slot            [2]frontend.Variable `gnark:",public"`
+	SlotValue       [2]frontend.Variable `gnark:",public"`
+	BlockNumber     frontend.Variable    `gnark:",public"`
+	BlockHash       [2]frontend.Variable
+	BlockIndex      frontend.Variable
+	ChunkProof      [][2]frontend.Variable
+	AddressStorageBlockProof
+}
+
+// NewEthAddressStorageChunkProof allocates the circuit for chunk proofs of chunkDepth siblings, see
+// mpt.CheckEthBlockHashInChunk
+func NewEthAddressStorageChunkProof(chunkDepth int) *EthAddressStorageChunkProof {
+	return &EthAddressStorageChunkProof{ChunkProof: make([][2]frontend.Variable, chunkDepth)}
+}
+
+// NewEthAddressStorageChunkWitness returns the assignment of the chunk circuit for the assignment w of
+// EthAddressStorageProof, with the block of w at index blockIndex in the chunk of chunkRoot, see
+// headerutil.ComputeChunkProof
+func NewEthAddressStorageChunkWitness(w *EthAddressStorageProof, chunkRoot []byte, blockIndex int, chunkProof [][]byte) *EthAddressStorageChunkProof {
+	c := &EthAddressStorageChunkProof{
+		ChunkRoot:                [2]frontend.Variable{chunkRoot[:16], chunkRoot[16:]},
+		AddressProofKey:          w.AddressProofKey,
+		Slot:                     w.Slot,
+		SlotValue:                w.SlotValue,
+		BlockNumber:              w.BlockNumber,
+		BlockHash:                w.BlockHash,
+		BlockIndex:               blockIndex,
+		AddressStorageBlockProof: w.AddressStorageBlockProof,
+	}
+	for _, sibling := range chunkProof {
+		c.ChunkProof = append(c.ChunkProof, [2]frontend.Variable{sibling[:16], sibling[16:]})
+	}
+	return c
+}
+
+func (c *EthAddressStorageChunkProof) Define(api frontend.API) error {
+	mpt.CheckEthBlockHashInChunk(api, c.ChunkRoot, c.BlockHash, c.BlockIndex, c.ChunkProof)
+
+	// the storage against the block hash
+	block := &EthAddressStorageProof{
+		BlockHash:                c.BlockHash,
+		AddressProofKey:          c.AddressProofKey,
+		Slot:                     c.Slot,
+		SlotValue:                c.SlotValue,
+		BlockNumber:              c.BlockNumber,
+		AddressStorageBlockProof: c.AddressStorageBlockProof,
+	}
+	return block.Define(api)
+}
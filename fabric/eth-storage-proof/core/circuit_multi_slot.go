@@ -0,0 +1,110 @@
+package core
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// StorageSlotProof is the storage trie witness of one slot in EthAddressMultiStorageProof
+type StorageSlotProof struct {
+	KeyFragmentStarts    [mpt.StorageMPTMaxDepth]frontend.Variable // [storageMaxDepth]
+	ValueRlp             [66]frontend.Variable
+	LeafRlp              [mpt.StorageLeafMaxBlockHexLen]frontend.Variable // [storageMaxLeafRlpLength]
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [mpt.StorageMPTMaxDepth - 1][mpt.StorageLeafMaxBlockHexLen * 4]frontend.Variable // [storageMaxDepth - 1][storageMaxBranchRlpLength]
+	NodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable                                    // [storageMaxDepth - 1]
+	NodeTypes            [mpt.StorageMPTMaxDepth - 1]frontend.Variable                                    // [storageMaxDepth - 1]
+	Depth                frontend.Variable
+}
+
+// EthAddressMultiStorageProof proves several storage slots of one account at one block. The block header and the
+// account path are verified once, every slot is then checked against the storage root of the account. The number
+// of slots is fixed when the circuit is compiled, use NewEthAddressMultiStorageProof to allocate both the circuit
+// and its assignment.
+type EthAddressMultiStorageProof struct {
+	BlockHash                   [2]frontend.Variable   `gnark:",public"`
+	AddressProofKey             [2]frontend.Variable   `gnark:",public"` // padded address hash
+	Slots                       [][2]frontend.Variable `gnark:",public"`
+	SlotValues                  [][2]frontend.Variable `gnark:",public"`
+	BlockNumber                 frontend.Variable      `gnark:",public"`
+	BlockHashRlp                [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
+	BlockRlpFieldNum            frontend.Variable
+	AddressKeyFragmentStarts    [mpt.AccountMPTMaxDepth]frontend.Variable // [addressMaxDepth]
+	AddressRlp                  [mpt.MaxValueLengthForAccount]frontend.Variable
+	AddressLeafRlp              [mpt.AccountLeafMaxBlockHexLen]frontend.Variable // [addressMaxLeafRlpLength]
+	AddressLeafPathPrefixLength frontend.Variable
+	AddressNodeRlp              [mpt.AccountMPTMaxDepth - 1][mpt.StorageLeafMaxBlockHexLen * 4]frontend.Variable // [addressMaxDepth - 1][addressMaxBranchRlpLength]
+	AddressNodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable                                    // [addressMaxDepth - 1]
+	AddressNodeTypes            [mpt.AccountMPTMaxDepth - 1]frontend.Variable                                    // [addressMaxDepth - 1]
+	AddressDepth                frontend.Variable
+	StorageProofs               []StorageSlotProof
+}
+
+func NewEthAddressMultiStorageProof(slotNum int) *EthAddressMultiStorageProof {
+	return &EthAddressMultiStorageProof{
+		Slots:         make([][2]frontend.Variable, slotNum),
+		SlotValues:    make([][2]frontend.Variable, slotNum),
+		StorageProofs: make([]StorageSlotProof, slotNum),
+	}
+}
+
+func (c *EthAddressMultiStorageProof) Define(api frontend.API) error {
+	var addressNodeRlp [][]frontend.Variable
+	for i := 0; i < len(c.AddressNodeRlp); i++ {
+		addressNodeRlp = append(addressNodeRlp, c.AddressNodeRlp[i][:])
+	}
+
+	blockHashNibbles := Recompose32ByteToNibbles(api, c.BlockHash)
+	addressProofKeyNibbles := Recompose32ByteToNibbles(api, c.AddressProofKey)
+
+	blockAccountResult := mpt.CheckEthBlockAccountProof(
+		api,
+		mpt.AccountMPTMaxDepth,
+		blockHashNibbles,
+		c.BlockRlpFieldNum,
+		addressProofKeyNibbles,
+		c.BlockHashRlp,
+		c.AddressKeyFragmentStarts[:],
+		c.AddressRlp,
+		c.AddressLeafRlp[:],
+		c.AddressLeafPathPrefixLength,
+		addressNodeRlp,
+		c.AddressNodePathPrefixLength[:],
+		c.AddressNodeTypes[:],
+		c.AddressDepth,
+	)
+	api.AssertIsEqual(blockAccountResult.Output, 1)
+	api.AssertIsEqual(c.BlockNumber, blockAccountResult.BlockNumber)
+
+	for i := range c.StorageProofs {
+		storageProof := &c.StorageProofs[i]
+
+		var storageNodeRlp [][]frontend.Variable
+		for j := 0; j < len(storageProof.NodeRlp); j++ {
+			storageNodeRlp = append(storageNodeRlp, storageProof.NodeRlp[j][:])
+		}
+
+		storageResult := mpt.CheckEthStorageProof(
+			api,
+			mpt.StorageMPTMaxDepth,
+			blockAccountResult.StorageRoot,
+			Recompose32ByteToNibbles(api, c.Slots[i]),
+			storageProof.ValueRlp,
+			storageProof.KeyFragmentStarts[:],
+			storageProof.LeafRlp[:],
+			storageProof.LeafPathPrefixLength,
+			storageNodeRlp,
+			storageProof.NodePathPrefixLength[:],
+			storageProof.NodeTypes[:],
+			storageProof.Depth,
+		)
+		api.AssertIsEqual(storageResult.Output, 1)
+
+		for j := 0; j < 2; j++ {
+			api.AssertIsEqual(c.SlotValues[i][j], storageResult.SlotValue[j])
+		}
+	}
+
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func TestEthAddressStorage(t *testing.T) {
@@ -21,3 +24,23 @@ func TestEthAddressStorage(t *testing.T) {
 	assert.NoError(err)
 
 }
+
+func TestEthAddressStorageChunk(t *testing.T) {
+	assert := test.NewAssert(t)
+	w := GetEthAddressProofTestWitness()
+
+	// the block is the second of a chunk of 3 blocks
+	blockHash := append(append([]byte{}, w.BlockHash[0].([]byte)...), w.BlockHash[1].([]byte)...)
+	leaves := [][]byte{crypto.Keccak256([]byte("prev")), blockHash, crypto.Keccak256([]byte("next"))}
+	root, err := native.PaddedKeccakMerkleRoot(leaves)
+	assert.NoError(err)
+	proof, err := native.PaddedKeccakMerkleProof(leaves, 1)
+	assert.NoError(err)
+
+	circuit := NewEthAddressStorageChunkProof(len(proof))
+	chunkWitness := NewEthAddressStorageChunkWitness(w, root, 1, proof)
+	assert.NoError(test.IsSolved(circuit, chunkWitness, ecc.BN254.ScalarField()))
+
+	chunkWitness.BlockIndex = 2
+	assert.Error(test.IsSolved(circuit, chunkWitness, ecc.BN254.ScalarField()))
+}
@@ -5,8 +5,8 @@ import (
 	"math/big"
 	"strconv"
 
-	"github.com/celer-network/brevis-circuits/gadgets/keccak"
 	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
 
 	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark/frontend"
@@ -21,24 +21,29 @@ type AccountInfo struct {
 	CodeHash    []byte
 }
 
+// EthAddressProofTestBlockRlp is the rlp encoding of the header of the mainnet block 0x103f9e8 of
+// GetEthAddressProofTestWitness
+const EthAddressProofTestBlockRlp = "0xf9022fa0b0ff4a0678831b194b50d4147cef9cd4e360e8ac4569a8ccdcfee81f40d82acda01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d4934794690b9a9e9aa1c9db991c7721a92d351db4fac990a054820fb8648ea7eacfcdd0668e1a8afbe933341e787d0a09636ac19e11d8ec18a0bffc95ed579c768622108e9d04f8b8f7ee2629d19b016c865848da6639be70dca094c810c747828c9bebcb56c31ec854d1753d37c07bc87081be01a0699117b444b9010075a7e50fff80a746fae996a9c340da630b29104bd3062a11063915f5d645451a1ec743408605052001193b9dd342bdd893890863ab6bbcec0a9c3b03796c6cf19c967f21e53aadaa7ba37b4c12d943648a100191da67594ad270642ccc3c6e23db801f2052478b232d193818e4cdac45a69868f6099c9e8d4276465981ec1c65d1ab2fd24566fa4634cd6c0987be138e7910f5ef0943caf8792d27e80e31b3049abd64ef1192e1c3245349e895b5ade8a77404c0e79bfa0a206eeb6e9a8a5a42f18e4242ba4524450c60b46aa5df3f34d1494b87aebf28944c7f3d069828fbe3b77cbf68488c908624499fefa4e02813a53c0a584dbf6e566fc999c3c1f1d68180840103f9e88401c9c38083f70ce7846437ce7f8c406275696c64657230783639a04f5e3a77c67e55193fc97590474c6a09fe530f231d02ba6ba6c79e00fdc23a568800000000000000008508b5987915a0c3c77de387b43bdb4e36871270f1255e0f1d6255b00b87476e8a1bcb15d53788"
+
 func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 	// test data:
 	// block number 0x103f9e8
 	// get proof "params":["0x881D40237659C251811CEC9c364ef91dC08D300C", ["0x1"], "0x103f9e8"]
 
 	// ================ block header test data ======================
-	blockRlpHex := "0xf9022fa0b0ff4a0678831b194b50d4147cef9cd4e360e8ac4569a8ccdcfee81f40d82acda01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d4934794690b9a9e9aa1c9db991c7721a92d351db4fac990a054820fb8648ea7eacfcdd0668e1a8afbe933341e787d0a09636ac19e11d8ec18a0bffc95ed579c768622108e9d04f8b8f7ee2629d19b016c865848da6639be70dca094c810c747828c9bebcb56c31ec854d1753d37c07bc87081be01a0699117b444b9010075a7e50fff80a746fae996a9c340da630b29104bd3062a11063915f5d645451a1ec743408605052001193b9dd342bdd893890863ab6bbcec0a9c3b03796c6cf19c967f21e53aadaa7ba37b4c12d943648a100191da67594ad270642ccc3c6e23db801f2052478b232d193818e4cdac45a69868f6099c9e8d4276465981ec1c65d1ab2fd24566fa4634cd6c0987be138e7910f5ef0943caf8792d27e80e31b3049abd64ef1192e1c3245349e895b5ade8a77404c0e79bfa0a206eeb6e9a8a5a42f18e4242ba4524450c60b46aa5df3f34d1494b87aebf28944c7f3d069828fbe3b77cbf68488c908624499fefa4e02813a53c0a584dbf6e566fc999c3c1f1d68180840103f9e88401c9c38083f70ce7846437ce7f8c406275696c64657230783639a04f5e3a77c67e55193fc97590474c6a09fe530f231d02ba6ba6c79e00fdc23a568800000000000000008508b5987915a0c3c77de387b43bdb4e36871270f1255e0f1d6255b00b87476e8a1bcb15d53788"
+	blockRlpHex := EthAddressProofTestBlockRlp
 
 	// 0x67c5d26ae6ef00adcf970d9b1876f0eaec41f94d88b7a0299e9d6109cdd9bcd8
 	rlpBytes, _ := hexutil.Decode(blockRlpHex)
 
-	paddedRlpBytes := keccak.Pad101Bytes(rlpBytes)
-
 	var blockHeadRlpAsNibbles [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
-	for i, b := range paddedRlpBytes {
+	for i, b := range rlpBytes {
 		blockHeadRlpAsNibbles[i*2] = b >> 4
 		blockHeadRlpAsNibbles[i*2+1] = b & 0x0F
 	}
+	for i := len(rlpBytes) * 2; i < mpt.EthBlockHeadMaxBlockHexSize; i++ {
+		blockHeadRlpAsNibbles[i] = 0
+	}
 
 	blockHash := "0x67c5d26ae6ef00adcf970d9b1876f0eaec41f94d88b7a0299e9d6109cdd9bcd8"
 	hashRootBytes, _ := hexutil.Decode(blockHash)
@@ -58,8 +63,6 @@ func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 		"0xf9013180a0616f466053f01c2c103775b76b0c9547f5839e55617a4d61c5d3a463c882383aa0d15ee7006c4d4cd5f4a702fbd112399abca3df889e0fa3cc7e1c20f1f180d23e8080a07c58c2d01f9420ddb2fb8639c82ec7ff267bcfbf49eecd9e5a9b0966d9a37f51a0ac0890c2539cf91e5fa1b33c5aa8367ddcb04e3fc865994d4722f718827205fd80a01757cf1793c1420b486d5e45316d4ffc1809fda5197897afcffb3e130b754834a0a23c05a5a8f9d0db67bfa43f97a5c9aafbdda34c9b236c3114e753a3403ede39a0908e21ccaae061a950dd75f68ce3bc375ae1d9ce137897ca37bd21a4ff9c290ba07a140996704380378e802596e2bc9d17684bd6bfefc5161927a7dbbfba7846b68080a0c439ea63a71d280613439a03eedb8a70df9f62a946988a905508063080ea94958080",
 	}
 
-	addressDepth := len(nodeRlpHexStrings) + 1
-
 	// keccak256 address as the account proof rlp key
 	addressHash := "0xe6421abff3b5bb3c807e27089b297419fb09d898a94c8dacd695825e8d803c38"
 	addressBytes, _ := hexutil.Decode(addressHash)
@@ -79,78 +82,24 @@ func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 		}
 	}
 
-	var keyFragmentStarts [mpt.AccountMPTMaxDepth]frontend.Variable
-	for i := 0; i < mpt.AccountMPTMaxDepth; i++ {
-		keyFragmentStarts[i] = i
-		if i > addressDepth-1 {
-			keyFragmentStarts[i] = 64
-		}
-	}
-
 	leafRlpHexString := "0xf86e9d3ff3b5bb3c807e27089b297419fb09d898a94c8dacd695825e8d803c38b84ef84c018891a55d622bd2bb4aa0223ffdd6234ec3e83c9dbe8e31e6b7fde781c791b894b9a310878f98a84e4702a0d2b0c127b5a063e6adb2049fd927777ec7d5344a44ffad27767e461acd55b499"
-	// pad leaf,And convert it to nibbles
-	leafRlpBytes, _ := hexutil.Decode(leafRlpHexString)
-	paddedLeafRlpBytes := keccak.Pad101Bytes(leafRlpBytes)
-
-	var paddedLeafRlpHex [272 * 2]frontend.Variable
-	for i, b := range paddedLeafRlpBytes {
-		n1 := b >> 4
-		n2 := b & 0x0F
-
-		paddedLeafRlpHex[i*2] = n1
-		paddedLeafRlpHex[i*2+1] = n2
-	}
+	addressProof := newProofWitness(append(nodeRlpHexStrings, leafRlpHexString), addressBytes, mpt.AccountMPTMaxDepth)
 
-	for i := len(paddedLeafRlpBytes) * 2; i < 272*2; i++ {
-		paddedLeafRlpHex[i] = 0
-	}
-
-	leafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(leafRlpHexString) - 2)
-
-	leafPathPrefixLength := getRlpLeafPrefixLength(leafRlpHexString)
+	var keyFragmentStarts [mpt.AccountMPTMaxDepth]frontend.Variable
+	copy(keyFragmentStarts[:], addressProof.KeyFragmentStarts)
 
-	realDataLength := len(nodeRlpHexStrings)
+	var paddedLeafRlpHex [272 * 2]frontend.Variable
+	leafRlp, _ := addressProof.LeafRlp(272 * 2)
+	copy(paddedLeafRlpHex[:], leafRlp)
 
 	var nodeRlp [mpt.AccountMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [mpt.AccountMPTMaxDepth - 1]frontend.Variable
 	var nodeTypes [mpt.AccountMPTMaxDepth - 1]frontend.Variable
-
 	for i := 0; i < mpt.AccountMPTMaxDepth-1; i++ {
-		if i < realDataLength {
-			// Feed actual data
-			rlpHexString := nodeRlpHexStrings[i]
-			bytes, _ := hexutil.Decode(rlpHexString)
-			paddedBytes := keccak.Pad101Bytes(bytes)
-			var rlp [mpt.BranchNodeMaxBlockSize]frontend.Variable
-			for i, b := range paddedBytes {
-				n1 := b >> 4
-				n2 := b & 0x0F
-				rlp[i*2] = n1
-				rlp[i*2+1] = n2
-			}
-
-			for j := len(paddedBytes) * 2; j < 272*4; j++ {
-				rlp[j] = 0
-			}
-
-			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
-		} else {
-			// Add placeholder data
-			var empty [1088]frontend.Variable
-			for j := 0; j < 1088; j++ {
-				empty[j] = 0
-			}
-			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
-		}
-
-		// TODO: calculate prefix length and node types dynamically
-		nodePathPrefixLength[i] = 0
-		nodeTypes[i] = 0
+		copy(nodeRlp[i][:], addressProof.NodeRlp[i])
 	}
+	copy(nodePathPrefixLength[:], addressProof.NodePathPrefixLength)
+	copy(nodeTypes[:], addressProof.NodeTypes)
 
 	leafRlpInBytes, _ := hexutil.Decode("0x" + valueRlpHexString)
 	var accountInfo AccountInfo
@@ -175,8 +124,6 @@ func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 		"0xf8d18080a0f9be7c17de345b523e5d9568cb6f26c854d73ef3e882e6af23ee833f0510d52b8080a0542aa8570d4008344b7f2654df9ffd95d0d98f62c4ff3dd9d30c2e18b696c3ae80a067b5689f960a0a27d7f6007d653884d2dcdef406544ca5aaebeb89a117844e3f80a0812bd6ad9a9f19ddc271b0456f207ca0cf41edd070cebd0782ec14d89a66facf8080a04685e9074d76098f1c9c0cf91b384b0ce4d8233f6a84d969db62f1df7e26c9aa8080a01a523d1382b3819a0a63aa565d87fcf271c1f3a08e911e2aa74a6081e3cadcca80",
 	}
 
-	storageProofDepth := len(storageNodeRlpHexStrings) + 1
-
 	storageKeyRlpHexString := "0xc2575a0e9e593c00f959f8c92f12db2869c3395a3b0502d05e2516446f71f85b"
 	storageKeyRlpBytes, _ := hexutil.Decode(storageKeyRlpHexString)
 	var storagekeyRlpPiece [2]frontend.Variable
@@ -202,73 +149,24 @@ func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 		storageRootHashHex[i] = intValue
 	}
 
-	var storageKeyFragmentStarts [mpt.StorageMPTMaxDepth]frontend.Variable
-	for i := 0; i < mpt.StorageMPTMaxDepth; i++ {
-		storageKeyFragmentStarts[i] = i
-		if i > addressDepth-1 {
-			storageKeyFragmentStarts[i] = 64
-		}
-	}
-
 	storageLeafRlpHexString := "0xf8419e3a0e9e593c00f959f8c92f12db2869c3395a3b0502d05e2516446f71f85ba1a0ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
-	storageLeafRlpBytes, _ := hexutil.Decode(storageLeafRlpHexString)
-	storagePaddedLeafRlpBytes := keccak.Pad101Bytes(storageLeafRlpBytes)
+	storageProof := newProofWitness(append(storageNodeRlpHexStrings, storageLeafRlpHexString), storageKeyRlpBytes, mpt.StorageMPTMaxDepth)
 
-	storageLeafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(storageLeafRlpHexString) - 2)
+	var storageKeyFragmentStarts [mpt.StorageMPTMaxDepth]frontend.Variable
+	copy(storageKeyFragmentStarts[:], storageProof.KeyFragmentStarts)
 
 	var storagePaddedLeafRlpHex [272]frontend.Variable
-	for i, b := range storagePaddedLeafRlpBytes {
-		n1 := b >> 4
-		n2 := b & 0x0F
-
-		storagePaddedLeafRlpHex[i*2] = n1
-		storagePaddedLeafRlpHex[i*2+1] = n2
-	}
-
-	storageLeafPathPrefixLength := getRlpLeafPrefixLength(storageLeafRlpHexString)
+	storageLeafRlp, _ := storageProof.LeafRlp(272)
+	copy(storagePaddedLeafRlpHex[:], storageLeafRlp)
 
 	var storageNodeRlp [mpt.StorageMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var storageNodeRlpRoundIndexes [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	var storageNodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	var storageNodeTypes [mpt.StorageMPTMaxDepth - 1]frontend.Variable
-
-	storageRealDataLength := len(storageNodeRlpHexStrings)
-
 	for i := 0; i < mpt.StorageMPTMaxDepth-1; i++ {
-		if i < storageRealDataLength {
-			// Feed actual data
-			rlpHexString := storageNodeRlpHexStrings[i]
-			bytes, _ := hexutil.Decode(rlpHexString)
-			paddedBytes := keccak.Pad101Bytes(bytes)
-			var rlp [mpt.BranchNodeMaxBlockSize]frontend.Variable
-			for i, b := range paddedBytes {
-				n1 := b >> 4
-				n2 := b & 0x0F
-				rlp[i*2] = n1
-				rlp[i*2+1] = n2
-			}
-
-			for j := len(paddedBytes) * 2; j < 272*4; j++ {
-				rlp[j] = 0
-			}
-
-			storageNodeRlp[i] = rlp
-
-			storageNodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
-		} else {
-			// Add placeholder data
-			var empty [1088]frontend.Variable
-			for j := 0; j < 1088; j++ {
-				empty[j] = 0
-			}
-			storageNodeRlp[i] = empty
-			storageNodeRlpRoundIndexes[i] = 0
-		}
-
-		// TODO: calculate prefix length and node types dynamically
-		storageNodePathPrefixLength[i] = 0
-		storageNodeTypes[i] = 0
+		copy(storageNodeRlp[i][:], storageProof.NodeRlp[i])
 	}
+	copy(storageNodePathPrefixLength[:], storageProof.NodePathPrefixLength)
+	copy(storageNodeTypes[:], storageProof.NodeTypes)
 
 	storageLeafRlpInBytes, _ := hexutil.Decode("0x" + storageValueRlpHexString)
 	var storageInfo []byte
@@ -287,65 +185,47 @@ func GetEthAddressProofTestWitness() *EthAddressStorageProof {
 	storageSlotValuePiece[0] = storageSlotValueByte[0:16]
 	storageSlotValuePiece[1] = storageSlotValueByte[16:32]
 
-	witness := &EthAddressStorageProof{
-		BlockHash:                   hashRootPiece,
-		AddressProofKey:             addressPiece,
-		Slot:                        storagekeyRlpPiece,
-		SlotValue:                   storageSlotValuePiece,
-		BlockNumber:                 big.NewInt(17037800),
-		BlockRlpFieldNum:            17,
-		BlockRoundIndex:             4,
-		BlockHashRlp:                blockHeadRlpAsNibbles,
-		AddressKeyFragmentStarts:    keyFragmentStarts,
-		AddressRlp:                  valueRlpHex,
-		AddressLeafRlp:              paddedLeafRlpHex,
-		AddressLeafRoundIndex:       leafRlpRoundIndex,
-		AddressLeafPathPrefixLength: leafPathPrefixLength,
-		AddressNodeRlp:              nodeRlp,
-		AddressNodeRlpRoundIndexes:  nodeRlpRoundIndexes,
-		AddressNodePathPrefixLength: nodePathPrefixLength,
-		AddressNodeTypes:            nodeTypes,
-		AddressDepth:                addressDepth,
-		StorageKeyFragmentStarts:    storageKeyFragmentStarts,
-		StorageValueRlp:             storageValueRlpHex,
-		StorageLeafRlp:              storagePaddedLeafRlpHex,
-		StorageLeafRoundIndex:       storageLeafRlpRoundIndex,
-		StorageLeafPathPrefixLength: storageLeafPathPrefixLength,
-		StorageNodeRlp:              storageNodeRlp,
-		StorageNodePathPrefixLength: storageNodePathPrefixLength,
-		StorageNodeRlpRoundIndex:    storageNodeRlpRoundIndexes,
-		StorageNodeTypes:            storageNodeTypes,
-		StorageProofDepth:           storageProofDepth,
-	}
-
-	return witness
+	assignment := &EthAddressStorageProof{
+		BlockHash:       hashRootPiece,
+		AddressProofKey: addressPiece,
+		Slot:            storagekeyRlpPiece,
+		SlotValue:       storageSlotValuePiece,
+		BlockNumber:     big.NewInt(17037800),
+		AddressStorageBlockProof: AddressStorageBlockProof{
+			BlockRlpFieldNum:            17,
+			BlockHashRlp:                blockHeadRlpAsNibbles,
+			AddressKeyFragmentStarts:    keyFragmentStarts,
+			AddressRlp:                  valueRlpHex,
+			AddressLeafRlp:              paddedLeafRlpHex,
+			AddressLeafPathPrefixLength: addressProof.Leaf.PathPrefixLength,
+			AddressNodeRlp:              nodeRlp,
+			AddressNodePathPrefixLength: nodePathPrefixLength,
+			AddressNodeTypes:            nodeTypes,
+			AddressDepth:                addressProof.Depth,
+			StorageKeyFragmentStarts:    storageKeyFragmentStarts,
+			StorageValueRlp:             storageValueRlpHex,
+			StorageLeafRlp:              storagePaddedLeafRlpHex,
+			StorageLeafPathPrefixLength: storageProof.Leaf.PathPrefixLength,
+			StorageNodeRlp:              storageNodeRlp,
+			StorageNodePathPrefixLength: storageNodePathPrefixLength,
+			StorageNodeTypes:            storageNodeTypes,
+			StorageProofDepth:           storageProof.Depth,
+		},
+	}
+
+	return assignment
 }
 
-func getRlpLeafPrefixLength(leafRlp string) (pathPrefixLength int) {
-	input, err := hexutil.Decode(leafRlp)
-
-	if err != nil {
-		log.Error("Failed to decode leaf rlp", leafRlp, err.Error())
+// newProofWitness prepares the mpt witness of the hex encoded proof nodes, the last node being the leaf
+func newProofWitness(nodeRlpHexStrings []string, key []byte, maxDepth int) *witness.Proof {
+	var nodes [][]byte
+	for _, rlpHexString := range nodeRlpHexStrings {
+		node, _ := hexutil.Decode(rlpHexString)
+		nodes = append(nodes, node)
 	}
-
-	var decodeValue [][]byte
-	err = rlp.Decode(bytes.NewReader(input), &decodeValue)
-
+	proof, err := witness.NewProof(nodes, witness.BytesToNibbles(key), 64, maxDepth)
 	if err != nil {
-		log.Error("Failed to decode", err)
+		log.Fatal("failed to prepare the proof witness", err)
 	}
-
-	if len(decodeValue) == 2 {
-		if decodeValue[0][0] == 32 {
-			pathPrefixLength = 2
-		} else {
-			pathPrefixLength = 1
-		}
-		return
-	}
-
-	log.Error("Failed to decide leaf type", leafRlp, decodeValue)
-
-	pathPrefixLength = 0
-	return
+	return proof
 }
@@ -0,0 +1,346 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/celer-network/brevis-circuits/fabric/eth-storage-proof/core"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
+	"github.com/celer-network/goutils/log"
+	"github.com/consensys/gnark/frontend"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/iden3/go-iden3-crypto/keccak256"
+)
+
+// StorageProofResult is a single entry of eth_getProof's storageProof list
+type StorageProofResult struct {
+	Key   string   `json:"key"`
+	Value string   `json:"value"`
+	Proof []string `json:"proof"`
+}
+
+// EthGetProofResult is the result object of an eth_getProof call
+type EthGetProofResult struct {
+	Address      string               `json:"address"`
+	AccountProof []string             `json:"accountProof"`
+	Balance      string               `json:"balance"`
+	CodeHash     string               `json:"codeHash"`
+	Nonce        string               `json:"nonce"`
+	StorageHash  string               `json:"storageHash"`
+	StorageProof []StorageProofResult `json:"storageProof"`
+}
+
+type jsonRpcResponse struct {
+	Result json.RawMessage `json:"result"`
+}
+
+// LoadEthGetProof reads a saved eth_getProof response. Both the full json-rpc response and the bare
+// result object are accepted.
+func LoadEthGetProof(path string) (*EthGetProofResult, error) {
+	data, err := readJsonRpcResult(path)
+	if err != nil {
+		return nil, err
+	}
+	var res EthGetProofResult
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse eth_getProof result %s: %s", path, err.Error())
+	}
+	if len(res.AccountProof) == 0 {
+		return nil, fmt.Errorf("no account proof found in %s", path)
+	}
+	return &res, nil
+}
+
+// LoadBlockHeaderRlp reads a saved raw block header, e.g. the response of debug_getRawHeader. Both the
+// full json-rpc response and a bare hex string are accepted.
+func LoadBlockHeaderRlp(path string) ([]byte, error) {
+	data, err := readJsonRpcResult(path)
+	if err != nil {
+		return nil, err
+	}
+	var headerRlpHex string
+	if err = json.Unmarshal(data, &headerRlpHex); err != nil {
+		return nil, fmt.Errorf("failed to parse block header rlp %s: %s", path, err.Error())
+	}
+	return hexutil.Decode(headerRlpHex)
+}
+
+func readJsonRpcResult(path string) (json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resp jsonRpcResponse
+	if err = json.Unmarshal(data, &resp); err == nil && len(resp.Result) > 0 {
+		return resp.Result, nil
+	}
+	return data, nil
+}
+
+// GenerateEthAddressStorageProofWitness builds the EthAddressStorageProof assignment for the first storage
+// proof in the saved eth_getProof response, using the saved raw header of the block the proof was taken at.
+func GenerateEthAddressStorageProofWitness(proofFile, blockHeaderFile string) (*core.EthAddressStorageProof, error) {
+	proof, err := LoadEthGetProof(proofFile)
+	if err != nil {
+		log.Errorf("Failed to load eth_getProof %s: %s\n", proofFile, err.Error())
+		return nil, err
+	}
+	headerRlp, err := LoadBlockHeaderRlp(blockHeaderFile)
+	if err != nil {
+		log.Errorf("Failed to load block header %s: %s\n", blockHeaderFile, err.Error())
+		return nil, err
+	}
+	return BuildEthAddressStorageProofWitness(proof, 0, headerRlp)
+}
+
+// BuildEthAddressStorageProofWitness builds the EthAddressStorageProof assignment for
+// proof.StorageProof[storageIndex] against the block whose rlp encoded header is headerRlp
+func BuildEthAddressStorageProofWitness(proof *EthGetProofResult, storageIndex int, headerRlp []byte) (*core.EthAddressStorageProof, error) {
+	if storageIndex < 0 || storageIndex >= len(proof.StorageProof) {
+		return nil, fmt.Errorf("storage proof index %d out of range, proof has %d storage proofs", storageIndex, len(proof.StorageProof))
+	}
+
+	header, err := newHeaderWitness(headerRlp)
+	if err != nil {
+		return nil, err
+	}
+	account, err := newAccountWitness(proof, header.stateRoot)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := newStorageWitness(proof.StorageProof[storageIndex], account.info.StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &core.EthAddressStorageProof{
+		BlockHash:       split32Bytes(keccak256.Hash(headerRlp)),
+		AddressProofKey: split32Bytes(account.key),
+		Slot:            split32Bytes(storage.key),
+		SlotValue:       split32Bytes(storage.value[:]),
+		BlockNumber:     header.blockNumber,
+		AddressStorageBlockProof: core.AddressStorageBlockProof{
+			BlockHashRlp:     header.rlp,
+			BlockRlpFieldNum: header.fieldNum,
+
+			AddressLeafPathPrefixLength: account.proof.Leaf.PathPrefixLength,
+			AddressDepth:                account.proof.Depth,
+
+			StorageLeafPathPrefixLength: storage.proof.Leaf.PathPrefixLength,
+			StorageProofDepth:           storage.proof.Depth,
+		},
+	}
+
+	if err = fillProofWitness(account.proof, assignment.AddressKeyFragmentStarts[:], assignment.AddressRlp[:], assignment.AddressLeafRlp[:],
+		assignment.AddressNodeRlp[:], assignment.AddressNodePathPrefixLength[:], assignment.AddressNodeTypes[:]); err != nil {
+		return nil, err
+	}
+	if err = fillProofWitness(storage.proof, assignment.StorageKeyFragmentStarts[:], assignment.StorageValueRlp[:], assignment.StorageLeafRlp[:],
+		assignment.StorageNodeRlp[:], assignment.StorageNodePathPrefixLength[:], assignment.StorageNodeTypes[:]); err != nil {
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+// BuildEthAddressMultiStorageProofWitness builds the EthAddressMultiStorageProof assignment for all storage proofs
+// in proof. The circuit it is checked against must be allocated for len(proof.StorageProof) slots.
+func BuildEthAddressMultiStorageProofWitness(proof *EthGetProofResult, headerRlp []byte) (*core.EthAddressMultiStorageProof, error) {
+	if len(proof.StorageProof) == 0 {
+		return nil, fmt.Errorf("proof has no storage proofs")
+	}
+
+	header, err := newHeaderWitness(headerRlp)
+	if err != nil {
+		return nil, err
+	}
+	account, err := newAccountWitness(proof, header.stateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := core.NewEthAddressMultiStorageProof(len(proof.StorageProof))
+	assignment.BlockHash = split32Bytes(keccak256.Hash(headerRlp))
+	assignment.AddressProofKey = split32Bytes(account.key)
+	assignment.BlockNumber = header.blockNumber
+	assignment.BlockHashRlp = header.rlp
+	assignment.BlockRlpFieldNum = header.fieldNum
+	assignment.AddressLeafPathPrefixLength = account.proof.Leaf.PathPrefixLength
+	assignment.AddressDepth = account.proof.Depth
+
+	if err = fillProofWitness(account.proof, assignment.AddressKeyFragmentStarts[:], assignment.AddressRlp[:], assignment.AddressLeafRlp[:],
+		assignment.AddressNodeRlp[:], assignment.AddressNodePathPrefixLength[:], assignment.AddressNodeTypes[:]); err != nil {
+		return nil, err
+	}
+
+	for i, storageProof := range proof.StorageProof {
+		storage, err := newStorageWitness(storageProof, account.info.StorageRoot)
+		if err != nil {
+			return nil, fmt.Errorf("storage proof %d: %s", i, err.Error())
+		}
+		assignment.Slots[i] = split32Bytes(storage.key)
+		assignment.SlotValues[i] = split32Bytes(storage.value[:])
+
+		slotProof := &assignment.StorageProofs[i]
+		slotProof.LeafPathPrefixLength = storage.proof.Leaf.PathPrefixLength
+		slotProof.Depth = storage.proof.Depth
+		if err = fillProofWitness(storage.proof, slotProof.KeyFragmentStarts[:], slotProof.ValueRlp[:], slotProof.LeafRlp[:],
+			slotProof.NodeRlp[:], slotProof.NodePathPrefixLength[:], slotProof.NodeTypes[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return assignment, nil
+}
+
+type headerWitness struct {
+	rlp         [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
+	fieldNum    int
+	stateRoot   []byte
+	blockNumber *big.Int
+}
+
+func newHeaderWitness(headerRlp []byte) (*headerWitness, error) {
+	var headerFields [][]byte
+	if err := rlp.DecodeBytes(headerRlp, &headerFields); err != nil {
+		return nil, fmt.Errorf("failed to decode block header: %s", err.Error())
+	}
+	if len(headerFields) < 9 {
+		return nil, fmt.Errorf("invalid block header, only %d fields", len(headerFields))
+	}
+
+	headerNibbles, err := witness.PaddedNibbles(headerRlp, mpt.EthBlockHeadMaxBlockHexSize)
+	if err != nil {
+		return nil, fmt.Errorf("block header rlp too long: %s", err.Error())
+	}
+	header := &headerWitness{
+		fieldNum:    len(headerFields),
+		stateRoot:   headerFields[3],
+		blockNumber: new(big.Int).SetBytes(headerFields[8]),
+	}
+	copy(header.rlp[:], headerNibbles)
+	return header, nil
+}
+
+type accountWitness struct {
+	key   []byte
+	proof *witness.Proof
+	info  core.AccountInfo
+}
+
+func newAccountWitness(proof *EthGetProofResult, stateRoot []byte) (*accountWitness, error) {
+	accountNodes, err := decodeProofNodes(proof.AccountProof)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(keccak256.Hash(accountNodes[0]), stateRoot) {
+		return nil, fmt.Errorf("account proof root does not match block state root %x", stateRoot)
+	}
+	address, err := hexutil.Decode(proof.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %s", proof.Address, err.Error())
+	}
+	account := &accountWitness{key: keccak256.Hash(address)}
+
+	account.proof, err = witness.NewProof(accountNodes, witness.BytesToNibbles(account.key), mpt.AccountKeyLength, mpt.AccountMPTMaxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account proof: %s", err.Error())
+	}
+	if len(account.proof.Leaf.Value)*2 > mpt.MaxValueLengthForAccount {
+		return nil, fmt.Errorf("account rlp too long: %d bytes", len(account.proof.Leaf.Value))
+	}
+	if err = rlp.DecodeBytes(account.proof.Leaf.Value, &account.info); err != nil {
+		return nil, fmt.Errorf("failed to decode account: %s", err.Error())
+	}
+	return account, nil
+}
+
+type storageWitness struct {
+	key   []byte
+	value [32]byte // the circuit decodes the slot value left aligned
+	proof *witness.Proof
+}
+
+func newStorageWitness(storageProof StorageProofResult, storageRoot []byte) (*storageWitness, error) {
+	if len(storageProof.Proof) == 0 {
+		return nil, fmt.Errorf("storage proof of slot %s is empty", storageProof.Key)
+	}
+	storageNodes, err := decodeProofNodes(storageProof.Proof)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(keccak256.Hash(storageNodes[0]), storageRoot) {
+		return nil, fmt.Errorf("storage proof root does not match account storage root %x", storageRoot)
+	}
+	// eth_getProof echoes the requested keys, which are not necessarily zero padded
+	slot, ok := new(big.Int).SetString(strings.TrimPrefix(storageProof.Key, "0x"), 16)
+	if !ok || slot.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid storage key %s", storageProof.Key)
+	}
+	storage := &storageWitness{key: keccak256.Hash(common.BigToHash(slot).Bytes())}
+
+	storage.proof, err = witness.NewProof(storageNodes, witness.BytesToNibbles(storage.key), mpt.AccountKeyLength, mpt.StorageMPTMaxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage proof: %s", err.Error())
+	}
+	if len(storage.proof.Leaf.Value)*2 > mpt.MaxValueLengthForStorage {
+		return nil, fmt.Errorf("storage value rlp too long: %d bytes", len(storage.proof.Leaf.Value))
+	}
+	var slotValue []byte
+	if err = rlp.DecodeBytes(storage.proof.Leaf.Value, &slotValue); err != nil {
+		return nil, fmt.Errorf("failed to decode storage value: %s", err.Error())
+	}
+	copy(storage.value[:], slotValue)
+	return storage, nil
+}
+
+// fillProofWitness copies the prepared mpt witness into the fixed size arrays of the circuit assignment
+func fillProofWitness(
+	proof *witness.Proof,
+	keyFragmentStarts []frontend.Variable,
+	valueRlp []frontend.Variable,
+	leafRlp []frontend.Variable,
+	nodeRlp [][mpt.BranchNodeMaxBlockSize]frontend.Variable,
+	nodePathPrefixLength []frontend.Variable,
+	nodeTypes []frontend.Variable,
+) error {
+	leaf, err := proof.LeafRlp(len(leafRlp))
+	if err != nil {
+		return fmt.Errorf("leaf rlp too long: %s", err.Error())
+	}
+	copy(leafRlp, leaf)
+	copy(valueRlp, witness.NibblesToVariables(witness.BytesToNibbles(proof.Leaf.Value), len(valueRlp)))
+	copy(keyFragmentStarts, proof.KeyFragmentStarts)
+	for i := range nodeRlp {
+		copy(nodeRlp[i][:], proof.NodeRlp[i])
+	}
+	copy(nodePathPrefixLength, proof.NodePathPrefixLength)
+	copy(nodeTypes, proof.NodeTypes)
+	return nil
+}
+
+func decodeProofNodes(proof []string) ([][]byte, error) {
+	var nodes [][]byte
+	for i, p := range proof {
+		node, err := hexutil.Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode proof node %d: %s", i, err.Error())
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("empty proof")
+	}
+	return nodes, nil
+}
+
+func split32Bytes(b []byte) [2]frontend.Variable {
+	return [2]frontend.Variable{b[0:16], b[16:32]}
+}
@@ -0,0 +1,171 @@
+package util
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/fabric/eth-storage-proof/core"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestGenerateEthAddressStorageProofWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	proofFile, headerFile := writeTestProofFiles(t, []common.Hash{common.HexToHash("0x1")})
+	witness, err := GenerateEthAddressStorageProofWitness(proofFile, headerFile)
+	assert.NoError(err)
+
+	err = test.IsSolved(&core.EthAddressStorageProof{}, witness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
+
+func TestBuildEthAddressStorageProofWitnessRootMismatch(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	proofFile, headerFile := writeTestProofFiles(t, []common.Hash{common.HexToHash("0x1")})
+	proof, err := LoadEthGetProof(proofFile)
+	assert.NoError(err)
+	headerRlp, err := LoadBlockHeaderRlp(headerFile)
+	assert.NoError(err)
+
+	proof.StorageProof[0].Proof = proof.StorageProof[0].Proof[1:]
+	_, err = BuildEthAddressStorageProofWitness(proof, 0, headerRlp)
+	assert.Error(err)
+}
+
+func TestBuildEthAddressMultiStorageProofWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	slots := []common.Hash{common.HexToHash("0x1"), common.HexToHash("0x2"), common.HexToHash("0x2a"), common.HexToHash("0x3f")}
+	proofFile, headerFile := writeTestProofFiles(t, slots)
+	proof, err := LoadEthGetProof(proofFile)
+	assert.NoError(err)
+	headerRlp, err := LoadBlockHeaderRlp(headerFile)
+	assert.NoError(err)
+
+	witness, err := BuildEthAddressMultiStorageProofWitness(proof, headerRlp)
+	assert.NoError(err)
+	err = test.IsSolved(core.NewEthAddressMultiStorageProof(len(slots)), witness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+
+	// the value of slot 0x2a is 42*42+1, which the circuit decodes left aligned into the upper half
+	witness.SlotValues[2][0] = 0
+	err = test.IsSolved(core.NewEthAddressMultiStorageProof(len(slots)), witness, ecc.BN254.ScalarField())
+	assert.Error(err)
+}
+
+// writeTestProofFiles builds a local state with a few hundred accounts and one contract with a populated
+// storage, then saves the eth_getProof response for the contract's slots and the debug_getRawHeader
+// response of a london header committing to the state
+func writeTestProofFiles(t *testing.T, slots []common.Hash) (proofFile string, headerFile string) {
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	sdb, err := state.New(common.Hash{}, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		addr := common.BytesToAddress(crypto.Keccak256(big.NewInt(int64(i)).Bytes()))
+		sdb.SetNonce(addr, uint64(i))
+		sdb.SetBalance(addr, big.NewInt(int64(i+1)*1e15))
+	}
+	contract := common.HexToAddress("0x881D40237659C251811CEC9c364ef91dC08D300C")
+	sdb.SetNonce(contract, 1)
+	sdb.SetBalance(contract, new(big.Int).SetUint64(0x91a55d622bd2bb4a))
+	sdb.SetCode(contract, []byte{0x60, 0x80, 0x60, 0x40, 0x52})
+	for i := 0; i < 64; i++ {
+		sdb.SetState(contract, common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(int64(i*i+1))))
+	}
+	sdb.SetState(contract, common.HexToHash("0x1"), common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
+	root, err := sdb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb, err = state.New(root, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountProof, err := sdb.GetProof(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageTrie, err := sdb.StorageTrie(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := EthGetProofResult{
+		Address:      hexutil.Encode(contract.Bytes()),
+		AccountProof: encodeProof(accountProof),
+		Balance:      hexutil.EncodeBig(sdb.GetBalance(contract)),
+		CodeHash:     sdb.GetCodeHash(contract).Hex(),
+		Nonce:        hexutil.EncodeUint64(sdb.GetNonce(contract)),
+		StorageHash:  storageTrie.Hash().Hex(),
+	}
+	for _, slot := range slots {
+		storageProof, err := sdb.GetStorageProof(contract, slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.StorageProof = append(res.StorageProof, StorageProofResult{
+			Key:   hexutil.EncodeBig(slot.Big()),
+			Value: hexutil.EncodeBig(sdb.GetState(contract, slot).Big()),
+			Proof: encodeProof(storageProof),
+		})
+	}
+
+	header := &types.Header{
+		ParentHash:  common.HexToHash("0xb0ff4a0678831b194b50d4147cef9cd4e360e8ac4569a8ccdcfee81f40d82acd"),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.HexToAddress("0x690b9a9e9aa1c9db991c7721a92d351db4fac990"),
+		Root:        root,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0),
+		Number:      big.NewInt(17037800),
+		GasLimit:    30000000,
+		GasUsed:     16190695,
+		Time:        1681379967,
+		Extra:       []byte("@builder0x69"),
+		BaseFee:     big.NewInt(37405014293),
+	}
+	headerRlp, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	proofFile = filepath.Join(dir, "proof.json")
+	headerFile = filepath.Join(dir, "header.json")
+	writeJsonRpcResult(t, proofFile, res)
+	writeJsonRpcResult(t, headerFile, hexutil.Encode(headerRlp))
+	return
+}
+
+func writeJsonRpcResult(t *testing.T, path string, result interface{}) {
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func encodeProof(proof [][]byte) []string {
+	var ret []string
+	for _, node := range proof {
+		ret = append(ret, hexutil.Encode(node))
+	}
+	return ret
+}
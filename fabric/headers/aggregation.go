@@ -0,0 +1,128 @@
+package headers
+
+import (
+	"fmt"
+	"math/big"
+
+	util "github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
+	"github.com/celer-network/brevis-circuits/fabric/transaction-proof/core"
+	"github.com/celer-network/brevis-circuits/gadgets/conv"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ChunkOutput is the public witness of a chunk proof of Circuit, in the order of the public fields of Circuit
+type ChunkOutput struct {
+	ChunkRoot     [2]frontend.Variable
+	PrevHash      [2]frontend.Variable
+	EndHash       [2]frontend.Variable
+	StartBlockNum frontend.Variable
+	EndBlockNum   frontend.Variable
+}
+
+func (o ChunkOutput) publicInputs() []frontend.Variable {
+	var inputs []frontend.Variable
+	inputs = append(inputs, o.ChunkRoot[:]...)
+	inputs = append(inputs, o.PrevHash[:]...)
+	inputs = append(inputs, o.EndHash[:]...)
+	return append(inputs, o.StartBlockNum, o.EndBlockNum)
+}
+
+// AggregationCircuit verifies the BLS12-377 groth16 proofs of consecutive chunks of Circuit in a BW6-761 circuit.
+// The EndHash of each chunk must be the PrevHash of the next one, Root is the keccak merkle root of the chunk roots
+// padded with zero leaves to the next power of two as in native.PaddedKeccakMerkleRoot.
+type AggregationCircuit struct {
+	Root          [2]frontend.Variable `gnark:",public"`
+	PrevHash      [2]frontend.Variable `gnark:",public"`
+	EndHash       [2]frontend.Variable `gnark:",public"`
+	StartBlockNum frontend.Variable    `gnark:",public"`
+	EndBlockNum   frontend.Variable    `gnark:",public"`
+
+	Chunks      []ChunkOutput
+	ChunkProofs []core.Proof
+	ChunkVk     core.VerifyingKey
+
+	// chunkVk is the verifying key of the chunk circuit that ChunkVk is fixed to, so that only the proofs of the chunk
+	// circuit are accepted. The verifier can't take constant points, the verifying key is a witness fixed by equality.
+	chunkVk core.VerifyingKey `gnark:"-"`
+}
+
+func (c *AggregationCircuit) Define(api frontend.API) error {
+	if len(c.Chunks) == 0 || len(c.Chunks) != len(c.ChunkProofs) {
+		panic(fmt.Sprintf("%d chunks with %d proofs", len(c.Chunks), len(c.ChunkProofs)))
+	}
+	c.ChunkVk.AssertIsEqual(api, c.chunkVk)
+	var chunkRoots [][256]frontend.Variable
+	for i, chunk := range c.Chunks {
+		core.Verify(api, c.ChunkVk, c.ChunkProofs[i], chunk.publicInputs())
+		if i > 0 {
+			prev := c.Chunks[i-1]
+			api.AssertIsEqual(prev.EndHash[0], chunk.PrevHash[0])
+			api.AssertIsEqual(prev.EndHash[1], chunk.PrevHash[1])
+			api.AssertIsEqual(api.Add(prev.EndBlockNum, 1), chunk.StartBlockNum)
+		}
+		chunkRoots = append(chunkRoots, conv.Uint128s2Bits(api, chunk.ChunkRoot))
+	}
+
+	root := conv.Bits2Uint128s(api, merkle.NewKeccak(api).RootVarLen(chunkRoots, len(chunkRoots)))
+	first, last := c.Chunks[0], c.Chunks[len(c.Chunks)-1]
+	for i := 0; i < 2; i++ {
+		api.AssertIsEqual(c.Root[i], root[i])
+		api.AssertIsEqual(c.PrevHash[i], first.PrevHash[i])
+		api.AssertIsEqual(c.EndHash[i], last.EndHash[i])
+	}
+	api.AssertIsEqual(c.StartBlockNum, first.StartBlockNum)
+	api.AssertIsEqual(c.EndBlockNum, last.EndBlockNum)
+	return nil
+}
+
+// NewAggregationCircuit returns the aggregation of the proofs of the chunks with the verifying key of the chunk
+// circuit, it serves both as the circuit to compile and as its witness
+func NewAggregationCircuit(chunkVk groth16.VerifyingKey, chunkProofs []groth16.Proof, chunks []*Circuit) (*AggregationCircuit, error) {
+	if len(chunks) == 0 || len(chunks) != len(chunkProofs) {
+		return nil, fmt.Errorf("%d chunks with %d proofs", len(chunks), len(chunkProofs))
+	}
+	c := &AggregationCircuit{
+		Chunks:      make([]ChunkOutput, len(chunks)),
+		ChunkProofs: make([]core.Proof, len(chunks)),
+	}
+	c.ChunkVk.Assign(chunkVk)
+	c.chunkVk.Assign(chunkVk)
+	var chunkRoots [][]byte
+	for i, chunk := range chunks {
+		if i > 0 && fv2Hash(chunks[i-1].EndHash) != fv2Hash(chunk.PrevHash) {
+			return nil, fmt.Errorf("chunk %d does not follow the end hash of chunk %d", i, i-1)
+		}
+		root := fv2Hash(chunk.ChunkRoot)
+		chunkRoots = append(chunkRoots, root[:])
+		c.Chunks[i] = ChunkOutput{
+			ChunkRoot:     chunk.ChunkRoot,
+			PrevHash:      chunk.PrevHash,
+			EndHash:       chunk.EndHash,
+			StartBlockNum: chunk.StartBlockNum,
+			EndBlockNum:   chunk.EndBlockNum,
+		}
+		c.ChunkProofs[i].Assign(chunkProofs[i])
+	}
+	root, err := native.PaddedKeccakMerkleRoot(chunkRoots)
+	if err != nil {
+		return nil, err
+	}
+	c.Root = util.Hash2FV(root)
+	c.PrevHash = chunks[0].PrevHash
+	c.EndHash = chunks[len(chunks)-1].EndHash
+	c.StartBlockNum = chunks[0].StartBlockNum
+	c.EndBlockNum = chunks[len(chunks)-1].EndBlockNum
+	return c, nil
+}
+
+// fv2Hash is the inverse of util.Hash2FV on the witness of a hash
+func fv2Hash(h [2]frontend.Variable) [32]byte {
+	var hash [32]byte
+	h[0].(*big.Int).FillBytes(hash[:16])
+	h[1].(*big.Int).FillBytes(hash[16:])
+	return hash
+}
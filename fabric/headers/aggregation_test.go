@@ -0,0 +1,171 @@
+package headers
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	util "github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+// chunkStubCircuit has the public witness of Circuit, it stands for the chunk circuit in the aggregation tests
+type chunkStubCircuit struct {
+	ChunkRoot     [2]frontend.Variable `gnark:",public"`
+	PrevHash      [2]frontend.Variable `gnark:",public"`
+	EndHash       [2]frontend.Variable `gnark:",public"`
+	StartBlockNum frontend.Variable    `gnark:",public"`
+	EndBlockNum   frontend.Variable    `gnark:",public"`
+}
+
+func (c *chunkStubCircuit) Define(api frontend.API) error {
+	api.AssertIsLessOrEqual(c.StartBlockNum, c.EndBlockNum)
+	return nil
+}
+
+func newChunkStub(chunk *Circuit) *chunkStubCircuit {
+	return &chunkStubCircuit{
+		ChunkRoot:     chunk.ChunkRoot,
+		PrevHash:      chunk.PrevHash,
+		EndHash:       chunk.EndHash,
+		StartBlockNum: chunk.StartBlockNum,
+		EndBlockNum:   chunk.EndBlockNum,
+	}
+}
+
+// genChunks splits a chain of headers into chunks of size headers
+func genChunks(count, size int) []*Circuit {
+	hs := util.NewHeaders(genHeaders(count))
+	var chunks []*Circuit
+	for i := 0; i < count; i += size {
+		chunks = append(chunks, newChunkProofCircuit(hs[i:i+size], size, false))
+	}
+	return chunks
+}
+
+func TestAggregationChunkPublicInputs(t *testing.T) {
+	chunk := genChunks(1, 1)[0]
+	w, err := frontend.NewWitness(chunk, ecc.BLS12_377.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub, err := frontend.NewWitness(newChunkStub(chunk), ecc.BLS12_377.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := w.MarshalBinary()
+	actual, _ := stub.MarshalBinary()
+	if !bytes.Equal(expected, actual) {
+		t.Error("the public witness of the stub differs from the one of the chunk circuit")
+	}
+}
+
+func TestAggregationCircuit(t *testing.T) {
+	chunks := genChunks(6, 2)
+	ccs, err := frontend.Compile(ecc.BLS12_377.ScalarField(), r1cs.NewBuilder, &chunkStubCircuit{}, frontend.IgnoreUnconstrainedInputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proofs []groth16.Proof
+	for _, chunk := range chunks {
+		w, err := frontend.NewWitness(newChunkStub(chunk), ecc.BLS12_377.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := groth16.Prove(ccs, pk, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs = append(proofs, proof)
+	}
+
+	c, err := NewAggregationCircuit(vk, proofs, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.StartBlockNum.(*big.Int).Int64() != 1 || c.EndBlockNum.(*big.Int).Int64() != 6 {
+		t.Errorf("aggregated blocks %d to %d", c.StartBlockNum, c.EndBlockNum)
+	}
+	err = test.IsSolved(c, c, ecc.BW6_761.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// compiling assigns the variables to the circuit
+	circuit, _ := NewAggregationCircuit(vk, proofs, chunks)
+	aggCcs, err := frontend.Compile(ecc.BW6_761.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("constraints", aggCcs.GetNbConstraints())
+
+	// the chunks must be linked
+	if _, err := NewAggregationCircuit(vk, proofs[1:], chunks[:1:2]); err == nil {
+		t.Error("aggregated chunks of different counts")
+	}
+	if _, err := NewAggregationCircuit(vk, []groth16.Proof{proofs[0], proofs[2]}, []*Circuit{chunks[0], chunks[2]}); err == nil {
+		t.Error("aggregated chunks that are not linked")
+	}
+	w, err := NewAggregationCircuit(vk, []groth16.Proof{proofs[0], proofs[2]}, []*Circuit{chunks[0], chunks[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Chunks[1] = c.Chunks[2]
+	w.EndHash, w.EndBlockNum = c.EndHash, c.EndBlockNum
+	root0, root2 := fv2Hash(chunks[0].ChunkRoot), fv2Hash(chunks[2].ChunkRoot)
+	root, err := native.PaddedKeccakMerkleRoot([][]byte{root0[:], root2[:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Root = util.Hash2FV(root)
+	err = test.IsSolved(w, w, ecc.BW6_761.ScalarField())
+	if err == nil {
+		t.Error("aggregated chunks that are not linked")
+	}
+	// the proofs must be of the chunks
+	w, err = NewAggregationCircuit(vk, []groth16.Proof{proofs[1], proofs[0]}, chunks[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = test.IsSolved(w, w, ecc.BW6_761.ScalarField())
+	if err == nil {
+		t.Error("aggregated chunks with swapped proofs")
+	}
+
+	// the proofs must be of the chunk circuit
+	otherPk, otherVk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var otherProofs []groth16.Proof
+	for _, chunk := range chunks[:2] {
+		w, err := frontend.NewWitness(newChunkStub(chunk), ecc.BLS12_377.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := groth16.Prove(ccs, otherPk, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherProofs = append(otherProofs, proof)
+	}
+	w, err = NewAggregationCircuit(otherVk, otherProofs, chunks[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.chunkVk.Assign(vk)
+	err = test.IsSolved(w, w, ecc.BW6_761.ScalarField())
+	if err == nil {
+		t.Error("aggregated proofs of another verifying key")
+	}
+}
@@ -3,8 +3,10 @@ package headers
 import (
 	"math"
 
+	util "github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
 	"github.com/celer-network/brevis-circuits/gadgets/conv"
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
 	"github.com/celer-network/brevis-circuits/gadgets/merkle"
 	"github.com/celer-network/brevis-circuits/gadgets/rlp"
 
@@ -12,7 +14,13 @@ import (
 )
 
 const PARENT_HASH_RLP_OFFSET = 32
-const CHUNK_HEADER_MAX_HASH_ROUNDS = 5
+const CHUNK_HEADER_MAX_HASH_ROUNDS = keccak.BLOCK_HEADER_ROUNDS
+
+// BLOCK_NUMBER_FIELD is the index of the block number in the header fields, BLOCK_NUMBER_RLP_MAX_HEX_END bounds the
+// end of the block number in the header rlp: the list prefix, the 5 hashes and the coinbase, the bloom, the difficulty
+// and the number of their max lengths with their prefixes
+const BLOCK_NUMBER_FIELD = 8
+const BLOCK_NUMBER_RLP_MAX_HEX_END = 6 + 5*66 + 42 + 518 + 66 + 18
 
 type Circuit struct {
 	ChunkRoot     [2]frontend.Variable `gnark:",public"`
@@ -21,53 +29,148 @@ type Circuit struct {
 	StartBlockNum frontend.Variable    `gnark:",public"`
 	EndBlockNum   frontend.Variable    `gnark:",public"`
 
-	// RLP encoded header bits, pre-padded with 10..1 for keccak, additional zeros are padded in the end
-	// to fill up to maxRounds * 1088
-	Headers       [][]frontend.Variable
-	HashRoundIdxs []frontend.Variable
-
-	api frontend.API
+	// RLP encoded header bits, least significant bit of each byte first, zero padded to maxRounds * 1088. The keccak
+	// padding is applied in circuit after the rlp length, see hashHeader.
+	Headers [][]frontend.Variable
+	// HeaderCount is the number of headers of the chunk, 1 <= HeaderCount <= len(Headers). The headers after it are
+	// not checked, they may be zero.
+	HeaderCount frontend.Variable
+
+	// LookupKeccak computes the keccak permutations with lookup tables instead of bitwise, see
+	// keccakf.LookupPermuter. A chunk of 4 headers takes 6.9M PLONK constraints instead of 8.6M, see
+	// BenchmarkChunkPlonkConstraints. The lookup argument commits to the witness, so these chunk proofs can't be
+	// verified by AggregationCircuit.
+	LookupKeccak bool `gnark:"-"`
+
+	api    frontend.API
+	hasher keccak.Hasher
+	// isLast[i] is 1 for the last header of the chunk, isActive[i] for the headers of the chunk
+	isLast   []frontend.Variable
+	isActive []frontend.Variable
 }
 
 func (c *Circuit) Define(api frontend.API) error {
+	c.define(api)
+	return nil
+}
+
+// define checks the chunk and returns the hashes of its headers
+func (c *Circuit) define(api frontend.API) [][256]frontend.Variable {
 	c.api = api
-	if !isPowerOfTwo(len(c.Headers)) {
-		panic("headers len must be power of two")
+	c.hasher = keccak.NewHasher(api)
+	if c.LookupKeccak {
+		c.hasher.Permuter = keccakf.NewLookupPermuter(api)
+	}
+	if len(c.Headers) == 0 {
+		panic("no headers")
 	}
+	c.decodeHeaderCount()
 	blockHashes := c.computeBlockHashes()
 	parentHashes := c.decodeParentHashes()
 	c.checkConnectivity(blockHashes, parentHashes)
-	c.checkBoundary(parentHashes[0])
+	c.checkBoundary(parentHashes[0], blockHashes)
 	c.checkMerkleRoot(blockHashes)
-	return nil
+	return blockHashes
+}
+
+func (c *Circuit) decodeHeaderCount() {
+	c.isLast = make([]frontend.Variable, len(c.Headers))
+	c.isActive = make([]frontend.Variable, len(c.Headers))
+	var active frontend.Variable = 1
+	for i := range c.Headers {
+		c.isActive[i] = active
+		c.isLast[i] = c.api.IsZero(c.api.Sub(c.HeaderCount, i+1))
+		active = c.api.Sub(active, c.isLast[i])
+	}
+	// 1 <= HeaderCount <= len(c.Headers)
+	c.api.AssertIsEqual(active, 0)
+}
+
+// last returns the item of the last header of the chunk
+func (c *Circuit) last(items []frontend.Variable) frontend.Variable {
+	var item frontend.Variable = 0
+	for i := range items {
+		item = c.api.Add(item, c.api.Mul(c.isLast[i], items[i]))
+	}
+	return item
 }
 
 func (c *Circuit) computeBlockHashes() [][256]frontend.Variable {
 	blockHashes := [][256]frontend.Variable{}
 	for i := 0; i < len(c.Headers); i++ {
-		header := c.Headers[i]
-		roundIdx := c.HashRoundIdxs[i]
-		hash := keccak.Keccak256Bits(c.api, CHUNK_HEADER_MAX_HASH_ROUNDS, roundIdx, header)
+		hash := hashHeader(c.api, c.hasher, c.Headers[i], c.isActive[i])
 		blockHashes = append(blockHashes, hash)
 	}
 	return blockHashes
 }
 
+// hashHeader returns the keccak256 hash of the rlp header given as bits, padding it in circuit. The rlp length is
+// decoded from the list prefix of headers of 256 to 65535 bytes, the bits after it are ignored. The prefix of an
+// inactive header is not checked.
+func hashHeader(api frontend.API, hasher keccak.Hasher, header []frontend.Variable, isActive frontend.Variable) (hash [256]frontend.Variable) {
+	// the bytes fitting in the keccak rounds with the padding
+	maxBytes := CHUNK_HEADER_MAX_HASH_ROUNDS*136 - 1
+	bytes := make([]frontend.Variable, maxBytes)
+	for i := range bytes {
+		bytes[i] = api.FromBinary(header[i*8 : i*8+8]...)
+	}
+	api.AssertIsEqual(api.Mul(isActive, api.Sub(bytes[0], 0xf9)), 0)
+	length := api.Add(3, api.Mul(bytes[1], 256), bytes[2])
+	for i, b := range hasher.Keccak256(maxBytes, bytes, length) {
+		copy(hash[i*8:], api.ToBinary(b, 8))
+	}
+	return
+}
+
+// chunkRoot returns the root of the chunk tree of the depth of the circuit, the headers after the chunk being
+// replaced by zero leaves, see headerutil.ChunkDepth
+func chunkRoot(k merkle.Keccak, blockHashes [][256]frontend.Variable, isActive []frontend.Variable) [256]frontend.Variable {
+	leaves := make([][256]frontend.Variable, 1<<util.ChunkDepth(len(blockHashes)))
+	for i := range leaves {
+		for j := range leaves[i] {
+			leaves[i][j] = 0
+			if i < len(blockHashes) {
+				leaves[i][j] = k.API.Mul(isActive[i], blockHashes[i][j])
+			}
+		}
+	}
+	return k.Root(leaves)
+}
+
 func (c *Circuit) checkMerkleRoot(blockHashes [][256]frontend.Variable) {
-	root := merkle.KeccakMerkleRoot(c.api, blockHashes)
+	root := chunkRoot(merkle.Keccak{API: c.api, Permuter: c.hasher.Permuter}, blockHashes, c.isActive)
 	rootHash := conv.Bits2Uint128s(c.api, root)
 	for i := 0; i < 2; i++ {
 		c.api.AssertIsEqual(c.ChunkRoot[i], rootHash[i])
 	}
 }
 
-func (c *Circuit) checkBoundary(prevHash [256]frontend.Variable) {
+func (c *Circuit) checkBoundary(prevHash [256]frontend.Variable, blockHashes [][256]frontend.Variable) {
+	var endHash [256]frontend.Variable
+	for j := range endHash {
+		bits := make([]frontend.Variable, len(blockHashes))
+		for i := range blockHashes {
+			bits[i] = blockHashes[i][j]
+		}
+		endHash[j] = c.last(bits)
+	}
 	prev := conv.Bits2Uint128s(c.api, prevHash)
+	end := conv.Bits2Uint128s(c.api, endHash)
 	for i := 0; i < 2; i++ {
 		c.api.AssertIsEqual(prev[i], c.PrevHash[i])
+		c.api.AssertIsEqual(end[i], c.EndHash[i])
 	}
 	startBlockNum := c.decodeBlockNumber(c.Headers[0])
-	endBlockNum := c.decodeBlockNumber(c.Headers[len(c.Headers)-1])
+	// the block number of the last header, decoded from its selected bits
+	lastHeader := make([]frontend.Variable, len(c.Headers[0][:BLOCK_NUMBER_RLP_MAX_HEX_END*4]))
+	for j := range lastHeader {
+		bits := make([]frontend.Variable, len(c.Headers))
+		for i, header := range c.Headers {
+			bits[i] = header[j]
+		}
+		lastHeader[j] = c.last(bits)
+	}
+	endBlockNum := c.decodeBlockNumber(lastHeader)
 	c.api.AssertIsEqual(startBlockNum, c.StartBlockNum)
 	c.api.AssertIsEqual(endBlockNum, c.EndBlockNum)
 }
@@ -76,7 +179,7 @@ func (c *Circuit) checkConnectivity(blockHashes [][256]frontend.Variable, parent
 	for i := 1; i < len(blockHashes); i++ {
 		// TODO perf opt: merge hash bits before adding the equality constraint
 		for j := 0; j < 256; j++ {
-			c.api.AssertIsEqual(blockHashes[i-1][j], parentHashes[i][j])
+			c.api.AssertIsEqual(c.api.Mul(c.isActive[i], c.api.Sub(blockHashes[i-1][j], parentHashes[i][j])), 0)
 		}
 	}
 }
@@ -97,21 +200,25 @@ func (c *Circuit) decodeParentHash(header []frontend.Variable) [256]frontend.Var
 	return parentHashBits
 }
 
+// decodeBlockNumber decodes the fields of the header up to the block number, the following fields differ by fork
+// and are not decoded
 func (c *Circuit) decodeBlockNumber(hBits []frontend.Variable) frontend.Variable {
+	header := rlp.NewBlkHeaderArrayCheck(CHUNK_HEADER_MAX_HASH_ROUNDS * 272)
 	rlpArrayCheck := rlp.ArrayCheck{
-		MaxHexLen:            1360,
-		MaxFields:            17,
+		MaxHexLen:            BLOCK_NUMBER_RLP_MAX_HEX_END,
+		MaxFields:            BLOCK_NUMBER_FIELD + 1,
 		ArrayPrefixMaxHexLen: 4,
-		FieldMinHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 0, 0, 0, 0, 0, 0, 64, 16, 0, 0},
-		FieldMaxHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 14, 16, 8, 8, 8, 64, 64, 16, 14, 64},
+		FieldMinHexLen:       header.FieldMinHexLen[:BLOCK_NUMBER_FIELD+1],
+		FieldMaxHexLen:       header.FieldMaxHexLen[:BLOCK_NUMBER_FIELD+1],
+		Leading:              true,
 	}
 	nibs := c.bits2Nibs(hBits)
-	valid, _, fieldHexLens, fields := rlpArrayCheck.RlpArrayCheck(c.api, nibs)
+	valid, _, fieldHexLens, fields := rlpArrayCheck.RlpArrayCheck(c.api, nibs[:BLOCK_NUMBER_RLP_MAX_HEX_END])
 	c.api.AssertIsEqual(valid, 1)
 
 	// block number
-	blockNumLen := fieldHexLens[8]
-	blockNumNibs := fields[8]
+	blockNumLen := fieldHexLens[BLOCK_NUMBER_FIELD]
+	blockNumNibs := fields[BLOCK_NUMBER_FIELD]
 	shiftCnt := c.api.Sub(16, blockNumLen)
 	shifted := rlp.ShiftRight(c.api, 16, 5, blockNumNibs, shiftCnt)
 	var blockNumber frontend.Variable = 0
@@ -142,7 +249,3 @@ func (c *Circuit) byte2Nibs(bits []frontend.Variable) []frontend.Variable {
 	nib1 := c.api.FromBinary(bits[4:]...)
 	return []frontend.Variable{nib1, nib0}
 }
-
-func isPowerOfTwo(a int) bool {
-	return (a != 0) && ((a & (a - 1)) == 0)
-}
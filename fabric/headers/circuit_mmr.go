@@ -0,0 +1,39 @@
+package headers
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/conv"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// MMRCircuit is the chunk circuit that also appends the hashes of the HeaderCount headers of the chunk to the merkle
+// mountain range of the synced blocks, see merkle.Keccak.MMRAppend. The peaks are given as the uint128 pairs of
+// headerutil.Hash2FV, the mountain range of LeafCount leaves before the chunk and the one of NewLeafCount leaves
+// after it.
+type MMRCircuit struct {
+	Circuit
+
+	Peaks        [][2]frontend.Variable `gnark:",public"`
+	LeafCount    frontend.Variable      `gnark:",public"`
+	NewPeaks     [][2]frontend.Variable `gnark:",public"`
+	NewLeafCount frontend.Variable      `gnark:",public"`
+}
+
+func (c *MMRCircuit) Define(api frontend.API) error {
+	blockHashes := c.define(api)
+	peaks := make([][256]frontend.Variable, len(c.Peaks))
+	for h := range peaks {
+		peaks[h] = conv.Uint128s2Bits(api, c.Peaks[h])
+	}
+	mmr := merkle.Keccak{API: api, Permuter: c.hasher.Permuter}
+	newPeaks, newLeafCount := mmr.MMRAppend(peaks, c.LeafCount, blockHashes, c.HeaderCount)
+	for h := range newPeaks {
+		newPeak := conv.Bits2Uint128s(api, newPeaks[h])
+		for i := range newPeak {
+			api.AssertIsEqual(newPeak[i], c.NewPeaks[h][i])
+		}
+	}
+	api.AssertIsEqual(newLeafCount, c.NewLeafCount)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/celer-network/brevis-circuits/gadgets/conv"
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
 	"github.com/celer-network/brevis-circuits/gadgets/merkle"
 	"github.com/celer-network/brevis-circuits/gadgets/rlp"
 
@@ -18,53 +19,106 @@ type PolygonCircuit struct {
 	StartBlockNum frontend.Variable    `gnark:",public"`
 	EndBlockNum   frontend.Variable    `gnark:",public"`
 
-	// RLP encoded header bits, pre-padded with 10..1 for keccak, additional zeros are padded in the end
-	// to fill up to maxRounds * 1088
-	Headers       [][]frontend.Variable
-	HashRoundIdxs []frontend.Variable
-
-	api frontend.API
+	// RLP encoded header bits, least significant bit of each byte first, zero padded to maxRounds * 1088. The keccak
+	// padding is applied in circuit after the rlp length, see hashHeader.
+	Headers [][]frontend.Variable
+	// HeaderCount is the number of headers of the chunk, 1 <= HeaderCount <= len(Headers). The headers after it are
+	// not checked, they may be zero.
+	HeaderCount frontend.Variable
+
+	// LookupKeccak computes the keccak permutations with lookup tables, see Circuit.LookupKeccak
+	LookupKeccak bool `gnark:"-"`
+
+	api    frontend.API
+	hasher keccak.Hasher
+	// isLast[i] is 1 for the last header of the chunk, isActive[i] for the headers of the chunk
+	isLast   []frontend.Variable
+	isActive []frontend.Variable
 }
 
 func (c *PolygonCircuit) Define(api frontend.API) error {
 	c.api = api
-	if !isPowerOfTwo(len(c.Headers)) {
-		panic("headers len must be power of two")
+	c.hasher = keccak.NewHasher(api)
+	if c.LookupKeccak {
+		c.hasher.Permuter = keccakf.NewLookupPermuter(api)
+	}
+	if len(c.Headers) == 0 {
+		panic("no headers")
 	}
+	c.decodeHeaderCount()
 	blockHashes := c.computeBlockHashes()
 	parentHashes := c.decodeParentHashes()
 	c.checkConnectivity(blockHashes, parentHashes)
-	c.checkBoundary(parentHashes[0])
+	c.checkBoundary(parentHashes[0], blockHashes)
 	c.checkMerkleRoot(blockHashes)
 	return nil
 }
 
+func (c *PolygonCircuit) decodeHeaderCount() {
+	c.isLast = make([]frontend.Variable, len(c.Headers))
+	c.isActive = make([]frontend.Variable, len(c.Headers))
+	var active frontend.Variable = 1
+	for i := range c.Headers {
+		c.isActive[i] = active
+		c.isLast[i] = c.api.IsZero(c.api.Sub(c.HeaderCount, i+1))
+		active = c.api.Sub(active, c.isLast[i])
+	}
+	// 1 <= HeaderCount <= len(c.Headers)
+	c.api.AssertIsEqual(active, 0)
+}
+
+// last returns the item of the last header of the chunk
+func (c *PolygonCircuit) last(items []frontend.Variable) frontend.Variable {
+	var item frontend.Variable = 0
+	for i := range items {
+		item = c.api.Add(item, c.api.Mul(c.isLast[i], items[i]))
+	}
+	return item
+}
+
 func (c *PolygonCircuit) computeBlockHashes() [][256]frontend.Variable {
 	blockHashes := [][256]frontend.Variable{}
 	for i := 0; i < len(c.Headers); i++ {
-		header := c.Headers[i]
-		roundIdx := c.HashRoundIdxs[i]
-		hash := keccak.Keccak256Bits(c.api, CHUNK_HEADER_MAX_HASH_ROUNDS, roundIdx, header)
+		hash := hashHeader(c.api, c.hasher, c.Headers[i], c.isActive[i])
 		blockHashes = append(blockHashes, hash)
 	}
 	return blockHashes
 }
 
 func (c *PolygonCircuit) checkMerkleRoot(blockHashes [][256]frontend.Variable) {
-	root := merkle.KeccakMerkleRoot(c.api, blockHashes)
+	root := chunkRoot(merkle.Keccak{API: c.api, Permuter: c.hasher.Permuter}, blockHashes, c.isActive)
 	rootHash := conv.Bits2Uint128s(c.api, root)
 	for i := 0; i < 2; i++ {
 		c.api.AssertIsEqual(c.ChunkRoot[i], rootHash[i])
 	}
 }
 
-func (c *PolygonCircuit) checkBoundary(prevHash [256]frontend.Variable) {
+func (c *PolygonCircuit) checkBoundary(prevHash [256]frontend.Variable, blockHashes [][256]frontend.Variable) {
+	var endHash [256]frontend.Variable
+	for j := range endHash {
+		bits := make([]frontend.Variable, len(blockHashes))
+		for i := range blockHashes {
+			bits[i] = blockHashes[i][j]
+		}
+		endHash[j] = c.last(bits)
+	}
 	prev := conv.Bits2Uint128s(c.api, prevHash)
+	end := conv.Bits2Uint128s(c.api, endHash)
 	for i := 0; i < 2; i++ {
 		c.api.AssertIsEqual(prev[i], c.PrevHash[i])
+		c.api.AssertIsEqual(end[i], c.EndHash[i])
 	}
 	startBlockNum := c.decodeBlockNumber(c.Headers[0])
-	endBlockNum := c.decodeBlockNumber(c.Headers[len(c.Headers)-1])
+	// the block number of the last header, decoded from its selected bits
+	lastHeader := make([]frontend.Variable, len(c.Headers[0]))
+	for j := range lastHeader {
+		bits := make([]frontend.Variable, len(c.Headers))
+		for i, header := range c.Headers {
+			bits[i] = header[j]
+		}
+		lastHeader[j] = c.last(bits)
+	}
+	endBlockNum := c.decodeBlockNumber(lastHeader)
 	c.api.AssertIsEqual(startBlockNum, c.StartBlockNum)
 	c.api.AssertIsEqual(endBlockNum, c.EndBlockNum)
 }
@@ -73,7 +127,7 @@ func (c *PolygonCircuit) checkConnectivity(blockHashes [][256]frontend.Variable,
 	for i := 1; i < len(blockHashes); i++ {
 		// TODO perf opt: merge hash bits before adding the equality constraint
 		for j := 0; j < 256; j++ {
-			c.api.AssertIsEqual(blockHashes[i-1][j], parentHashes[i][j])
+			c.api.AssertIsEqual(c.api.Mul(c.isActive[i], c.api.Sub(blockHashes[i-1][j], parentHashes[i][j])), 0)
 		}
 	}
 }
@@ -96,11 +150,11 @@ func (c *PolygonCircuit) decodeParentHash(header []frontend.Variable) [256]front
 
 func (c *PolygonCircuit) decodeBlockNumber(hBits []frontend.Variable) frontend.Variable {
 	rlpArrayCheck := rlp.ArrayCheck{
-		MaxHexLen:            1360,
+		MaxHexLen:            CHUNK_HEADER_MAX_HASH_ROUNDS * 272,
 		MaxFields:            16,
 		ArrayPrefixMaxHexLen: 4,
 		FieldMinHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 0, 0, 0, 0, 0, 0, 64, 16, 0},
-		FieldMaxHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 14, 16, 8, 8, 8, 194, 64, 16, 14},
+		FieldMaxHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 64, 16, 16, 16, 16, 194, 64, 16, 64},
 	}
 	nibs := c.bits2Nibs(hBits)
 	valid, _, fieldHexLens, fields := rlpArrayCheck.RlpArrayCheck(c.api, nibs)
@@ -27,15 +27,25 @@ func TestPolygonCircuit(t *testing.T) {
 	fmt.Println("constraints", cs.GetNbConstraints())
 }
 
+func TestPolygonCircuitWrongEndHash(t *testing.T) {
+	w := NewPolygonChunkProofCircuit()
+	circuit := NewPolygonChunkProofCircuit()
+	w.EndHash = w.PrevHash
+	err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+	if err == nil {
+		t.Fatal("wrong end hash accepted")
+	}
+}
+
 func NewPolygonChunkProofCircuit() *PolygonCircuit {
 	hs := polygonHeaders()
 
-	headersEncoded, roundIdxs, err := util.EncodeHeaders(hs, false)
+	headersEncoded, err := util.EncodeHeaders(util.NewHeaders(hs), false)
 	if err != nil {
 		fmt.Printf("failed to encode headers: %s\n", err.Error())
 		return nil
 	}
-	root, err := util.ComputeChunkRoot(hs)
+	root, err := util.ComputeChunkRoot(util.NewHeaders(hs), len(hs))
 	fmt.Printf("chunk root %x\n", root)
 	if err != nil {
 		log.Errorf("Failed to compute chunk root: %s\n", err.Error())
@@ -55,7 +65,7 @@ func NewPolygonChunkProofCircuit() *PolygonCircuit {
 		EndHash:       endHash,
 		StartBlockNum: 44121528,
 		EndBlockNum:   44121531,
-		HashRoundIdxs: roundIdxs,
+		HeaderCount:   len(hs),
 	}
 }
 
@@ -2,12 +2,21 @@ package headers
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 
+	espcore "github.com/celer-network/brevis-circuits/fabric/eth-storage-proof/core"
+	util "github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestCircuit(t *testing.T) {
@@ -21,6 +30,169 @@ func TestCircuit(t *testing.T) {
 	fmt.Println("constraints", cs.GetNbConstraints())
 }
 
+func TestCircuitNonPowerOfTwo(t *testing.T) {
+	w := NewChunkProofCircuit(3)
+	circuit := NewChunkProofCircuit(3)
+	err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+	check(err)
+}
+
+// TestCircuitHeaderCount proves a chunk of 3 headers in a circuit of 4 headers
+func TestCircuitHeaderCount(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewChunkProofCircuit(4)
+	w := newChunkProofCircuit(util.NewHeaders(genHeaders(3)), 4, true)
+	assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+
+	// the end hash and block number are the ones of the header at HeaderCount - 1
+	for _, count := range []int{0, 2, 5} {
+		w.HeaderCount = count
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "header count %d", count)
+	}
+}
+
+func TestCircuitWrongEndHash(t *testing.T) {
+	w := NewChunkProofCircuit(4)
+	circuit := NewChunkProofCircuit(4)
+	// the hash of the header before the last one
+	h := genHeaders(4)[2].Hash()
+	w.EndHash = util.Hash2FV(h[:])
+	err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+	if err == nil {
+		t.Fatal("wrong end hash accepted")
+	}
+}
+
+// TestCircuitForks proves a chunk of the header fixture of each fork
+func TestCircuitForks(t *testing.T) {
+	for _, f := range util.HeaderFixtures {
+		h, err := util.DecodeHeader(hexutil.MustDecode(f.Rlp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := newChunkProofCircuit([]util.Header{*h}, 1, false)
+		circuit := newChunkProofCircuit([]util.Header{*h}, 1, false)
+		if err := test.IsSolved(circuit, w, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%s: %v", f.Fork, err)
+		}
+	}
+}
+
+// TestCircuitForkTransition proves a chunk of Shanghai, Cancun and Prague headers
+func TestCircuitForkTransition(t *testing.T) {
+	hs := util.NewHeaders(genHeaders(4))
+	blobGasUsed, excessBlobGas := uint64(0x20000), uint64(0x100000)
+	for i := 2; i < len(hs); i++ {
+		hs[i].BlobGasUsed = &blobGasUsed
+		hs[i].ExcessBlobGas = &excessBlobGas
+		hs[i].ParentBeaconRoot = &common.Hash{1}
+		hs[i].ParentHash = hs[i-1].Hash()
+	}
+	hs[3].RequestsHash = &common.Hash{2}
+	hs[3].ParentHash = hs[2].Hash()
+	w := newChunkProofCircuit(hs, len(hs), false)
+	circuit := newChunkProofCircuit(hs, len(hs), false)
+	err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+	check(err)
+}
+
+// TestCircuitChunkStorage proves the storage of a mainnet block against the ChunkRoot of the headers circuit of 8
+// headers over a chunk of 3 headers starting at this block, the chunk root and the branch of the block being the ones
+// of headerutil for the depth 3 of the circuit
+func TestCircuitChunkStorage(t *testing.T) {
+	const maxHeaders = 8
+	assert := test.NewAssert(t)
+	h, err := util.DecodeHeader(hexutil.MustDecode(espcore.EthAddressProofTestBlockRlp))
+	assert.NoError(err)
+	next := genHeader(h.Hash(), new(big.Int).Add(h.Number, big.NewInt(1)))
+	last := genHeader(next.Hash(), new(big.Int).Add(next.Number, big.NewInt(1)))
+	hs := append([]util.Header{*h}, util.NewHeaders([]types.Header{next, last})...)
+
+	root, err := util.ComputeChunkRoot(hs, maxHeaders)
+	assert.NoError(err)
+	proof, err := util.ComputeChunkProof(hs, maxHeaders, 0)
+	assert.NoError(err)
+	assert.Equal(3, len(proof))
+	chunkWitness := espcore.NewEthAddressStorageChunkWitness(espcore.GetEthAddressProofTestWitness(), root, 0, proof)
+	assert.NoError(test.IsSolved(espcore.NewEthAddressStorageChunkProof(util.ChunkDepth(maxHeaders)), chunkWitness, ecc.BN254.ScalarField()))
+
+	// the headers circuit accepts the ChunkRoot assigned to the storage circuit
+	w := newChunkProofCircuit(hs, maxHeaders, false)
+	w.ChunkRoot = chunkWitness.ChunkRoot
+	assert.NoError(test.IsSolved(NewChunkProofCircuit(maxHeaders), w, ecc.BN254.ScalarField()))
+
+	w.ChunkRoot = [2]frontend.Variable{chunkWitness.ChunkRoot[1], chunkWitness.ChunkRoot[0]}
+	assert.Error(test.IsSolved(NewChunkProofCircuit(maxHeaders), w, ecc.BN254.ScalarField()))
+}
+
+// TestCircuitHeaderPadding checks that the headers are padded in circuit after the length of their rlp prefix, the
+// bits after it being ignored
+func TestCircuitHeaderPadding(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewChunkProofCircuit(2)
+	w := NewChunkProofCircuit(2)
+	headerRlp, err := rlp.EncodeToBytes(&genHeaders(2)[1])
+	assert.NoError(err)
+	w.Headers[1][len(headerRlp)*8] = 1
+	assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+
+	w.Headers[1][len(headerRlp)*8-1] = 1 - w.Headers[1][len(headerRlp)*8-1].(uint8)
+	assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+}
+
+// TestMMRCircuit appends a chunk of 3 headers in a circuit of 4 headers to the mountain range of the 2 blocks before
+func TestMMRCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	hs := util.NewHeaders(genHeaders(5))
+	mmr, err := util.NewBlockHashMMR(hs[:2])
+	assert.NoError(err)
+	w, err := newMMRChunkCircuit(hs[2:], 4, mmr)
+	assert.NoError(err)
+	circuit := &MMRCircuit{Circuit: *NewChunkProofCircuit(4), Peaks: w.Peaks, NewPeaks: w.NewPeaks}
+	assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+
+	// the peaks of the mountain range of 5 blocks
+	expected, err := util.NewBlockHashMMR(hs)
+	assert.NoError(err)
+	for h, peak := range expected.Peaks() {
+		assert.Equal(util.Hash2FV(peak), w.NewPeaks[h])
+	}
+
+	// the chunk appended to other peaks
+	w.Peaks[0], w.Peaks[1] = w.Peaks[1], w.Peaks[0]
+	assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+}
+
+// TestCircuitLookupKeccak proves a chunk computing the keccak permutations with lookups
+func TestCircuitLookupKeccak(t *testing.T) {
+	assert := test.NewAssert(t)
+	w := NewChunkProofCircuit(2)
+	circuit := NewChunkProofCircuit(2)
+	circuit.LookupKeccak = true
+	assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+
+	w.EndHash = w.PrevHash
+	assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+}
+
+// BenchmarkChunkPlonkConstraints reports the PLONK constraints of a chunk of 4 headers, with the bitwise and the
+// lookup permutation
+func BenchmarkChunkPlonkConstraints(b *testing.B) {
+	for _, lookup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lookup=%t", lookup), func(b *testing.B) {
+			circuit := NewChunkProofCircuit(4)
+			circuit.LookupKeccak = lookup
+			for i := 0; i < b.N; i++ {
+				cs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(cs.GetNbConstraints()), "constraints")
+			}
+		})
+	}
+}
+
 func check(err error) {
 	if err != nil {
 		panic(err)
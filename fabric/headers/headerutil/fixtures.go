@@ -0,0 +1,40 @@
+package headerutil
+
+// HeaderFixture is the rlp encoding of a block header of a fork with its hash
+type HeaderFixture struct {
+	Fork     string
+	FieldNum int
+	Rlp      string
+	Hash     string
+}
+
+// HeaderFixtures holds a header of each fork supported by the header gadgets. The London and Shanghai headers are
+// the mainnet blocks 14194126 and 17086605. The Cancun and Prague headers are not mainnet blocks: they extend the
+// Shanghai header with the fields of these forks, set to values in the mainnet ranges, and are to be replaced by
+// mainnet headers.
+var HeaderFixtures = []HeaderFixture{
+	{
+		Fork:     "london",
+		FieldNum: LondonFieldNum,
+		Rlp:      "0xf90219a01315c9495925503b99fe391c5190b03c928b0afa5eba257f093e825604bd4d56a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347941ad91ee08f21be3de0ba2ba6918e714da6b45836a0d78d4f182ebd7f0dc86c5b328b73f9ea3dfe17ee56fbb490d9b67edac48e2b04a0ffadf2a50d8aa20e19c37ea385864998231e6524d200425f076479629cb20d9ca0bcb477e406f547e79f0bcc5406f60fbba70cf12b874fbdd646cb6c1e81d64db1b90100c320000000021204803045c1800512044102d0080e00110001852a204cd001970800e0028080805000084100004201040381848008008b88824a88000230260d34083880002201400b84040a140006280090620000620420101a3580804438400b09300503aa0a0220001e8110000a92200a4238083a44006200411006000281300014000eae10c03058a4104793100c150088010084048a0e080242001000000a90980806180800000400d00001440440801000440009108100110758851b404050042a483408016710020042020310041000010009049000190546046229001911a20c004080881026002664222ac500810058b40000460002d88008400000872e2632952e320283d895ce8401c9c38083924c6f8462083b4791486976656f6e2065752d68656176792d32a0b1ee7558e23d1014b70be22081b602661492bef5b5211b42d7fe84dbf34cde3688331f32cd8d3536b1850a22bc31c8",
+		Hash:     "0xbe8aa5945d3377e65ed06757555d0d4babe269097574c210133e59cf6bc17d18",
+	},
+	{
+		Fork:     "shanghai",
+		FieldNum: ShanghaiFieldNum,
+		Rlp:      "0xf90232a0e4fe56dbd9524d926dcad94a9822d55117ec2e7bbd8ef422b6f73bb577744d04a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347944675c7e5baafbffbca748158becba61ef3b0a263a0ad49c89b24dcab9c78b764498b5b03bd38ff58da63cc498857d9b01ac8803b34a0e50d3bfc93e56bdd7bf37bc1f5a867cbcc6a302cce3f154284650d95eda84e7aa0a3b2a40fcccf0c76cf04c72d926218d8433ba48d4ecca22f9fca9d17f28da553b9010045a107017a0008803025742081140723706255031219284a09898000149319220c1033c109211a27cbb053d490331f0f8aa183089803bb8e06884236107a5180b340c4fadf9588ad681a462fca3208a8d384201045c8b880351a4e6e80c03205131a042813024aa083d01828a2103f55462201050c108c09f240975640d89185383b87503a1090403448210a809004095480249189400008492761626810102cab0e01021802a6873d8760e707f07c8b1c9c020261ee001680072d0701400422c59800122101803064048c1a04a14230c3c103c29408327730d559020100b02202bab3ac8b0004aa8204048481b5083097740e88091c0bc540501623d0003e5380840104b88d8401c9c380838b8786846440fb138f6265617665726275696c642e6f7267a036048306130c4dbd88c44a8312b9a647d3795ab9adc6066dfe1dd438c1fea411880000000000000000850bff0c9cb8a033a1ad772c352e8d7bf81bdd3fb803d3535b34f11ade0031789963dd0b6b109f",
+		Hash:     "0x88bd78528ea4fd5c232978ce51e43f41f0d76ce56e331147c1c9611282308799",
+	},
+	{
+		Fork:     "cancun",
+		FieldNum: CancunFieldNum,
+		Rlp:      "0xf9025ca0e4fe56dbd9524d926dcad94a9822d55117ec2e7bbd8ef422b6f73bb577744d04a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347944675c7e5baafbffbca748158becba61ef3b0a263a0ad49c89b24dcab9c78b764498b5b03bd38ff58da63cc498857d9b01ac8803b34a0e50d3bfc93e56bdd7bf37bc1f5a867cbcc6a302cce3f154284650d95eda84e7aa0a3b2a40fcccf0c76cf04c72d926218d8433ba48d4ecca22f9fca9d17f28da553b9010045a107017a0008803025742081140723706255031219284a09898000149319220c1033c109211a27cbb053d490331f0f8aa183089803bb8e06884236107a5180b340c4fadf9588ad681a462fca3208a8d384201045c8b880351a4e6e80c03205131a042813024aa083d01828a2103f55462201050c108c09f240975640d89185383b87503a1090403448210a809004095480249189400008492761626810102cab0e01021802a6873d8760e707f07c8b1c9c020261ee001680072d0701400422c59800122101803064048c1a04a14230c3c103c29408327730d559020100b02202bab3ac8b0004aa8204048481b5083097740e88091c0bc540501623d0003e5380840104b88d8401c9c380838b8786846440fb138f6265617665726275696c642e6f7267a036048306130c4dbd88c44a8312b9a647d3795ab9adc6066dfe1dd438c1fea411880000000000000000850bff0c9cb8a033a1ad772c352e8d7bf81bdd3fb803d3535b34f11ade0031789963dd0b6b109f830600008404b80000a05d6a8b1f3c2e4d7a9b0c1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b",
+		Hash:     "0xb53ce7b7d33e447b53e2c1ca164b2f13cbe995cc49a8831678214f4df00d70bd",
+	},
+	{
+		Fork:     "prague",
+		FieldNum: PragueFieldNum,
+		Rlp:      "0xf9027da0e4fe56dbd9524d926dcad94a9822d55117ec2e7bbd8ef422b6f73bb577744d04a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347944675c7e5baafbffbca748158becba61ef3b0a263a0ad49c89b24dcab9c78b764498b5b03bd38ff58da63cc498857d9b01ac8803b34a0e50d3bfc93e56bdd7bf37bc1f5a867cbcc6a302cce3f154284650d95eda84e7aa0a3b2a40fcccf0c76cf04c72d926218d8433ba48d4ecca22f9fca9d17f28da553b9010045a107017a0008803025742081140723706255031219284a09898000149319220c1033c109211a27cbb053d490331f0f8aa183089803bb8e06884236107a5180b340c4fadf9588ad681a462fca3208a8d384201045c8b880351a4e6e80c03205131a042813024aa083d01828a2103f55462201050c108c09f240975640d89185383b87503a1090403448210a809004095480249189400008492761626810102cab0e01021802a6873d8760e707f07c8b1c9c020261ee001680072d0701400422c59800122101803064048c1a04a14230c3c103c29408327730d559020100b02202bab3ac8b0004aa8204048481b5083097740e88091c0bc540501623d0003e5380840104b88d8401c9c380838b8786846440fb138f6265617665726275696c642e6f7267a036048306130c4dbd88c44a8312b9a647d3795ab9adc6066dfe1dd438c1fea411880000000000000000850bff0c9cb8a033a1ad772c352e8d7bf81bdd3fb803d3535b34f11ade0031789963dd0b6b109f830600008404b80000a05d6a8b1f3c2e4d7a9b0c1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2ba0e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		Hash:     "0x8037bf025075f00668ef9be30c6f0db11af8da318f054fc49d12c80e3b2e1621",
+	},
+}
@@ -0,0 +1,186 @@
+package headerutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Number of header fields by fork, each fork appends its fields to the ones of the previous fork
+const (
+	LondonFieldNum   = 16 // baseFeePerGas
+	ShanghaiFieldNum = 17 // withdrawalsRoot
+	CancunFieldNum   = 20 // blobGasUsed, excessBlobGas, parentBeaconBlockRoot
+	PragueFieldNum   = 21 // requestsHash
+)
+
+// Header is a block header of any fork from London to Prague. The geth header only knows the fields up to
+// Shanghai, the ones of the later forks are set when the header is of these forks.
+type Header struct {
+	types.Header
+	BlobGasUsed      *uint64
+	ExcessBlobGas    *uint64
+	ParentBeaconRoot *common.Hash
+	RequestsHash     *common.Hash
+}
+
+// NewHeaders wraps geth headers
+func NewHeaders(headers []types.Header) []Header {
+	var hs []Header
+	for _, h := range headers {
+		hs = append(hs, Header{Header: h})
+	}
+	return hs
+}
+
+// DecodeHeader decodes the rlp encoding of a header
+func DecodeHeader(headerRlp []byte) (*Header, error) {
+	h := &Header{}
+	if err := rlp.DecodeBytes(headerRlp, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// fields returns the header fields in rlp order. The optional fields must be set up to the last field of the fork
+// of the header.
+func (h *Header) fields() ([]interface{}, error) {
+	fields := []interface{}{
+		h.ParentHash, h.UncleHash, h.Coinbase, h.Root, h.TxHash, h.ReceiptHash, h.Bloom, h.Difficulty, h.Number,
+		h.GasLimit, h.GasUsed, h.Time, h.Extra, h.MixDigest, h.Nonce,
+	}
+	optional := []struct {
+		set   bool
+		value interface{}
+	}{
+		{h.BaseFee != nil, h.BaseFee},
+		{h.WithdrawalsHash != nil, h.WithdrawalsHash},
+		{h.BlobGasUsed != nil, h.BlobGasUsed},
+		{h.ExcessBlobGas != nil, h.ExcessBlobGas},
+		{h.ParentBeaconRoot != nil, h.ParentBeaconRoot},
+		{h.RequestsHash != nil, h.RequestsHash},
+	}
+	n := 0
+	for n < len(optional) && optional[n].set {
+		fields = append(fields, optional[n].value)
+		n++
+	}
+	for _, field := range optional[n:] {
+		if field.set {
+			return nil, fmt.Errorf("header field %d is not set while later fields are", len(fields))
+		}
+	}
+	switch len(fields) {
+	case LondonFieldNum, ShanghaiFieldNum, CancunFieldNum, PragueFieldNum:
+		return fields, nil
+	}
+	return nil, fmt.Errorf("header of %d fields is of no fork", len(fields))
+}
+
+// FieldNum returns the number of fields of the header, 0 if they are not those of a fork
+func (h *Header) FieldNum() int {
+	fields, err := h.fields()
+	if err != nil {
+		return 0
+	}
+	return len(fields)
+}
+
+// EncodeRLP encodes the fields of the fork of the header, see FieldNum
+func (h *Header) EncodeRLP(w io.Writer) error {
+	fields, err := h.fields()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, fields)
+}
+
+// DecodeRLP decodes a header of any fork from London to Prague
+func (h *Header) DecodeRLP(s *rlp.Stream) error {
+	var fields []rlp.RawValue
+	if err := s.Decode(&fields); err != nil {
+		return err
+	}
+	switch len(fields) {
+	case LondonFieldNum, ShanghaiFieldNum, CancunFieldNum, PragueFieldNum:
+	default:
+		return fmt.Errorf("header of %d fields is of no fork", len(fields))
+	}
+	// the geth header decodes the fields up to Shanghai
+	gethFields := fields
+	if len(gethFields) > ShanghaiFieldNum {
+		gethFields = fields[:ShanghaiFieldNum]
+	}
+	gethRlp, err := rlp.EncodeToBytes(gethFields)
+	if err != nil {
+		return err
+	}
+	*h = Header{}
+	if err := rlp.DecodeBytes(gethRlp, &h.Header); err != nil {
+		return err
+	}
+	later := []interface{}{&h.BlobGasUsed, &h.ExcessBlobGas, &h.ParentBeaconRoot, &h.RequestsHash}
+	for i, field := range fields[len(gethFields):] {
+		if err := rlp.DecodeBytes(field, later[i]); err != nil {
+			return fmt.Errorf("header field %d: %w", ShanghaiFieldNum+i, err)
+		}
+	}
+	return nil
+}
+
+// Hash returns the keccak256 hash of the rlp encoding of the header, the block hash. It is the zero hash for a
+// header whose fields are not those of a fork.
+func (h *Header) Hash() common.Hash {
+	headerRlp, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(headerRlp)
+}
+
+// UnmarshalJSON decodes a header as returned by eth_getBlockByNumber
+func (h *Header) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		BlobGasUsed      *hexutil.Uint64 `json:"blobGasUsed"`
+		ExcessBlobGas    *hexutil.Uint64 `json:"excessBlobGas"`
+		ParentBeaconRoot *common.Hash    `json:"parentBeaconBlockRoot"`
+		RequestsHash     *common.Hash    `json:"requestsHash"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*h = Header{
+		BlobGasUsed:      (*uint64)(dec.BlobGasUsed),
+		ExcessBlobGas:    (*uint64)(dec.ExcessBlobGas),
+		ParentBeaconRoot: dec.ParentBeaconRoot,
+		RequestsHash:     dec.RequestsHash,
+	}
+	return h.Header.UnmarshalJSON(input)
+}
+
+// HeaderByNumber fetches the header of a block of any fork from London to Prague, which the geth client of this
+// module would truncate to the fields up to Shanghai
+func HeaderByNumber(ctx context.Context, rpcUrl string, number *big.Int) (*Header, error) {
+	client, err := rpc.DialContext(ctx, rpcUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	var h *Header
+	if err := client.CallContext(ctx, &h, "eth_getBlockByNumber", hexutil.EncodeBig(number), false); err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("block %s not found", number)
+	}
+	return h, nil
+}
@@ -0,0 +1,182 @@
+package headerutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestHeaderFixtures(t *testing.T) {
+	for _, f := range HeaderFixtures {
+		headerRlp := hexutil.MustDecode(f.Rlp)
+		h, err := DecodeHeader(headerRlp)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Fork, err)
+		}
+		if h.FieldNum() != f.FieldNum {
+			t.Errorf("%s: %d fields, expected %d", f.Fork, h.FieldNum(), f.FieldNum)
+		}
+		if h.Hash().Hex() != f.Hash {
+			t.Errorf("%s: hash %s, expected %s", f.Fork, h.Hash().Hex(), f.Hash)
+		}
+		encoded, err := rlp.EncodeToBytes(h)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Fork, err)
+		}
+		if hexutil.Encode(encoded) != f.Rlp {
+			t.Errorf("%s: rlp %x does not match the fixture", f.Fork, encoded)
+		}
+		// up to Shanghai, the geth header is enough
+		if f.FieldNum <= ShanghaiFieldNum && h.Header.Hash().Hex() != f.Hash {
+			t.Errorf("%s: geth hash %s, expected %s", f.Fork, h.Header.Hash().Hex(), f.Hash)
+		}
+
+		encodedHeaders, err := EncodeHeaders([]Header{*h}, false)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Fork, err)
+		}
+		if len(encodedHeaders[0]) != keccak.BLOCK_HEADER_ROUNDS*1088 || encodedHeaders[0][len(encoded)*8] != uint8(0) {
+			t.Errorf("%s: encoded as %d bits", f.Fork, len(encodedHeaders[0]))
+		}
+	}
+}
+
+func TestHeaderForks(t *testing.T) {
+	h, err := DecodeHeader(hexutil.MustDecode(HeaderFixtures[1].Rlp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cancun fields come together
+	blobGasUsed := uint64(0x20000)
+	h.BlobGasUsed = &blobGasUsed
+	if h.FieldNum() != 0 {
+		t.Errorf("header of %d fields", h.FieldNum())
+	}
+	if _, err := rlp.EncodeToBytes(h); err == nil {
+		t.Error("header of no fork encoded")
+	}
+	// a later field without the previous ones
+	h.BlobGasUsed = nil
+	h.RequestsHash = &common.Hash{}
+	if _, err := rlp.EncodeToBytes(h); err == nil {
+		t.Error("requests hash encoded without the cancun fields")
+	}
+
+	// 18 fields are of no fork
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(hexutil.MustDecode(HeaderFixtures[2].Rlp), &fields); err != nil {
+		t.Fatal(err)
+	}
+	headerRlp, err := rlp.EncodeToBytes(fields[:ShanghaiFieldNum+1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeHeader(headerRlp); err == nil {
+		t.Error("header of 18 fields decoded")
+	}
+}
+
+func TestHeaderUnmarshalJSON(t *testing.T) {
+	expected, err := DecodeHeader(hexutil.MustDecode(HeaderFixtures[3].Rlp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the json of eth_getBlockByNumber has all the fields of the geth header with the ones of the later forks
+	enc, err := json.Marshal(&expected.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		t.Fatal(err)
+	}
+	fields["blobGasUsed"] = hexutil.Uint64(*expected.BlobGasUsed)
+	fields["excessBlobGas"] = hexutil.Uint64(*expected.ExcessBlobGas)
+	fields["parentBeaconBlockRoot"] = expected.ParentBeaconRoot
+	fields["requestsHash"] = expected.RequestsHash
+	enc, err = json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h Header
+	if err := json.Unmarshal(enc, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.FieldNum() != PragueFieldNum || h.Hash() != expected.Hash() {
+		t.Errorf("header of %d fields and hash %s, expected %s", h.FieldNum(), h.Hash(), expected.Hash())
+	}
+}
+
+func TestEncodeHeadersFieldLen(t *testing.T) {
+	h, err := DecodeHeader(hexutil.MustDecode(HeaderFixtures[1].Rlp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// uint256 difficulty and base fee
+	max256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	h.Difficulty, h.BaseFee = max256, max256
+	if _, err := EncodeHeaders([]Header{*h}, false); err != nil {
+		t.Error(err)
+	}
+	h.BaseFee = new(big.Int).Lsh(big.NewInt(1), 256)
+	if _, err := EncodeHeaders([]Header{*h}, false); err == nil {
+		t.Error("base fee of 33 bytes encoded")
+	}
+
+	// the geth headers are wrapped as they are
+	gethHeader := types.Header{Difficulty: big.NewInt(1), Number: big.NewInt(1), BaseFee: big.NewInt(1)}
+	hs := NewHeaders([]types.Header{gethHeader})
+	if hs[0].Hash() != gethHeader.Hash() || hs[0].FieldNum() != LondonFieldNum {
+		t.Errorf("wrapped header of %d fields and hash %s, expected %s", hs[0].FieldNum(), hs[0].Hash(), gethHeader.Hash())
+	}
+}
+
+func TestComputeChunkProof(t *testing.T) {
+	var hs []Header
+	for _, f := range HeaderFixtures {
+		h, err := DecodeHeader(hexutil.MustDecode(f.Rlp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs = append(hs, *h)
+	}
+	// 3 headers are padded to 4 leaves
+	root, err := ComputeChunkRoot(hs[:3], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := ComputeChunkProof(hs[:3], 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := hs[2].Hash()
+	branchRoot := crypto.Keccak256(proof[1], crypto.Keccak256(hash[:], proof[0]))
+	if len(proof) != 2 || !bytes.Equal(proof[0], make([]byte, 32)) || !bytes.Equal(branchRoot, root) {
+		t.Errorf("proof %x of root %x, expected %x", proof, branchRoot, root)
+	}
+	if _, err := ComputeChunkProof(hs[:3], 3, 3); err == nil {
+		t.Error("proof of a padding leaf")
+	}
+
+	// the depth of the tree is the one of the circuit, 2 headers in a circuit of 8 headers
+	proof, err = ComputeChunkProof(hs[:2], 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof) != ChunkDepth(8) || ChunkDepth(8) != 3 || ChunkDepth(5) != 3 || ChunkDepth(1) != 0 {
+		t.Errorf("proof of %d siblings", len(proof))
+	}
+	if _, err := ComputeChunkRoot(hs[:3], 2); err == nil {
+		t.Error("chunk of more headers than the circuit")
+	}
+}
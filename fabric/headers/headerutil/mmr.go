@@ -0,0 +1,47 @@
+package headerutil
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// MMRHeight bounds the merkle mountain range of the block hashes to 2^32-1 blocks
+const MMRHeight = 32
+
+// NewBlockHashMMR returns the merkle mountain range of the hashes of the headers, see merkle.MMRAppend
+func NewBlockHashMMR(headers []Header) (*native.MMR, error) {
+	mmr := native.NewMMR(MMRHeight)
+	return mmr, AppendHeaders(mmr, headers)
+}
+
+// AppendHeaders appends the hashes of the headers to the mountain range
+func AppendHeaders(mmr *native.MMR, headers []Header) error {
+	var hashes [][]byte
+	for _, h := range headers {
+		hash := h.Hash()
+		hashes = append(hashes, hash[:])
+	}
+	return mmr.Append(hashes...)
+}
+
+// Hash2Bits returns the bits of a hash in the order of the keccak gadgets, the bits of each byte from the least
+// significant one
+func Hash2Bits(h []byte) [256]frontend.Variable {
+	var bits [256]frontend.Variable
+	for i, b := range h {
+		for j := 0; j < 8; j++ {
+			bits[i*8+j] = (b >> j) & 1
+		}
+	}
+	return bits
+}
+
+// Hashes2Bits is Hash2Bits on the peaks or siblings of a mountain range
+func Hashes2Bits(hs [][]byte) [][256]frontend.Variable {
+	var bits [][256]frontend.Variable
+	for _, h := range hs {
+		bits = append(bits, Hash2Bits(h))
+	}
+	return bits
+}
@@ -0,0 +1,44 @@
+package headerutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBlockHashMMR(t *testing.T) {
+	var hs []Header
+	for _, f := range HeaderFixtures[:3] {
+		h, err := DecodeHeader(hexutil.MustDecode(f.Rlp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs = append(hs, *h)
+	}
+	mmr, err := NewBlockHashMMR(hs[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendHeaders(mmr, hs[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 blocks make the peaks of heights 1 and 0
+	h0, h1, h2 := hs[0].Hash(), hs[1].Hash(), hs[2].Hash()
+	peaks := mmr.Peaks()
+	if len(peaks) != MMRHeight || mmr.LeafCount() != 3 {
+		t.Fatalf("%d peaks of %d leaves", len(peaks), mmr.LeafCount())
+	}
+	if !bytes.Equal(peaks[0], h2[:]) || !bytes.Equal(peaks[1], crypto.Keccak256(h0[:], h1[:])) || !bytes.Equal(peaks[2], make([]byte, 32)) {
+		t.Errorf("peaks %x", peaks[:3])
+	}
+	siblings, err := mmr.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siblings) != MMRHeight-1 || !bytes.Equal(siblings[0], h1[:]) || !bytes.Equal(siblings[1], make([]byte, 32)) {
+		t.Errorf("siblings %x", siblings[:2])
+	}
+}
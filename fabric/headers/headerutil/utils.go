@@ -1,24 +1,23 @@
 package headerutil
 
 import (
-	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
 
-	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark/frontend"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
-	"golang.org/x/crypto/sha3"
 )
 
-func EncodeHeaders(headers []types.Header, dummyHeaders bool) (encoded [][]frontend.Variable, idxs []frontend.Variable, err error) {
+// EncodeHeaders encodes the headers of any fork from London to Prague as the witness of the headers circuits, the
+// rlp bits of each header zero padded to the keccak rounds of a header, the keccak padding being applied in circuit
+func EncodeHeaders(headers []Header, dummyHeaders bool) (encoded [][]frontend.Variable, err error) {
 	for i, header := range headers {
 		ok := checkDynamicFieldLen(header)
 		if !ok {
-			return nil, nil, fmt.Errorf("dynamic field len check failed %+v", header)
+			return nil, fmt.Errorf("dynamic field len check failed %+v", header)
 		}
 		if i > 0 && dummyHeaders {
 			header.ParentHash = headers[i-1].Hash()
@@ -29,29 +28,82 @@ func EncodeHeaders(headers []types.Header, dummyHeaders bool) (encoded [][]front
 			err = encodeError
 			return
 		}
-		padded := keccak.Pad101Bytes(headerRLP)
-		paddedBits := keccak.Bytes2BlockBits(padded)
+		// the keccak padding takes at least 1 byte
+		padded := make([]byte, keccak.BLOCK_HEADER_ROUNDS*136)
+		if len(headerRLP) >= len(padded) {
+			return nil, fmt.Errorf("header rlp of %d bytes exceeds %d keccak rounds", len(headerRLP), keccak.BLOCK_HEADER_ROUNDS)
+		}
+		copy(padded, headerRLP)
 		fv := []frontend.Variable{}
-		for _, b := range paddedBits {
+		for _, b := range keccak.Bytes2BlockBits(padded) {
 			fv = append(fv, b)
 		}
-		idxs = append(idxs, len(fv)/1088-1)
-		zerosToPad := 5*1088 - len(paddedBits)
-		for i := 0; i < zerosToPad; i++ {
-			fv = append(fv, 0)
-		}
 		encoded = append(encoded, fv)
 	}
 	return
 }
 
-func ComputeChunkRoot(headers []types.Header) ([]byte, error) {
+// PadEncodedHeaders pads the headers encoded by EncodeHeaders with zero headers up to the maxHeaders headers of the
+// headers circuits, the headers after the HeaderCount of the circuits are not checked
+func PadEncodedHeaders(encoded [][]frontend.Variable, maxHeaders int) [][]frontend.Variable {
+	for len(encoded) < maxHeaders {
+		zeros := make([]frontend.Variable, keccak.BLOCK_HEADER_ROUNDS*1088)
+		for i := range zeros {
+			zeros[i] = 0
+		}
+		encoded = append(encoded, zeros)
+	}
+	return encoded
+}
+
+// ChunkDepth returns the depth of the chunk trees of the headers circuits of maxHeaders headers. The chunk root of
+// a chunk of any number of headers up to maxHeaders is the root of a tree of 2^ChunkDepth(maxHeaders) leaves, the
+// header hashes followed by zero leaves, so the chunk proofs of a circuit all have ChunkDepth siblings.
+func ChunkDepth(maxHeaders int) int {
+	depth := 0
+	for 1<<depth < maxHeaders {
+		depth++
+	}
+	return depth
+}
+
+// ComputeChunkRoot returns the ChunkRoot of the headers in the headers circuits of maxHeaders headers, see ChunkDepth
+func ComputeChunkRoot(headers []Header, maxHeaders int) ([]byte, error) {
+	hashes, err := chunkLeaves(headers, maxHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return native.PaddedKeccakMerkleRoot(hashes)
+}
+
+// ComputeChunkProof returns the siblings of the hash of the header of index in the tree of ComputeChunkRoot, from the
+// leaf up, see merkle.Keccak.BranchRoot
+func ComputeChunkProof(headers []Header, maxHeaders int, index int) ([][]byte, error) {
+	if index >= len(headers) {
+		return nil, fmt.Errorf("header %d of %d headers", index, len(headers))
+	}
+	hashes, err := chunkLeaves(headers, maxHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return native.PaddedKeccakMerkleProof(hashes, index)
+}
+
+// chunkLeaves returns the header hashes padded with zero leaves to maxHeaders leaves, native.PaddedKeccakMerkleRoot
+// padding them to the next power of two
+func chunkLeaves(headers []Header, maxHeaders int) ([][]byte, error) {
+	if len(headers) == 0 || len(headers) > maxHeaders {
+		return nil, fmt.Errorf("chunk of %d headers in a circuit of %d headers", len(headers), maxHeaders)
+	}
 	var hashes [][]byte
 	for _, h := range headers {
 		hash := h.Hash()
 		hashes = append(hashes, hash[:])
 	}
-	return keccakMerkleRoot(hashes)
+	for len(hashes) < maxHeaders {
+		hashes = append(hashes, make([]byte, 32))
+	}
+	return hashes, nil
 }
 
 func Hash2FV(h []byte) [2]frontend.Variable {
@@ -61,24 +113,16 @@ func Hash2FV(h []byte) [2]frontend.Variable {
 	}
 }
 
-func checkDynamicFieldLen(h types.Header) bool {
+// checkDynamicFieldLen checks the lengths of the big numbers of the header against the max lengths of
+// rlp.NewBlkHeaderArrayCheck, the other numbers are uint64
+func checkDynamicFieldLen(h Header) bool {
 	hasErr := 0
-	hasErr += checkLen("Difficulty", len(h.Difficulty.Bytes()), 7)
+	hasErr += checkLen("Difficulty", len(h.Difficulty.Bytes()), 32)
 	hasErr += checkLen("Number", len(h.Number.Bytes()), 8)
-	hasErr += checkLen("GasLimit", getUintByteLen(h.GasLimit), 4)
-	hasErr += checkLen("GasUsed", getUintByteLen(h.GasUsed), 4)
-	hasErr += checkLen("Time", getUintByteLen(h.Time), 4)
-	hasErr += checkLen("BaseFee", len(h.BaseFee.Bytes()), 7)
-	return hasErr == 0
-}
-
-func getUintByteLen(x uint64) int {
-	i := 0
-	for x > 0 {
-		x /= 256
-		i++
+	if h.BaseFee != nil {
+		hasErr += checkLen("BaseFee", len(h.BaseFee.Bytes()), 32)
 	}
-	return i
+	return hasErr == 0
 }
 
 func checkLen(field string, actual, max int) int {
@@ -88,29 +132,3 @@ func checkLen(field string, actual, max int) int {
 	}
 	return 0
 }
-
-func keccakMerkleRoot(leaves [][]byte) ([]byte, error) {
-	var hashes [][]byte
-	if len(leaves) == 1 {
-		return leaves[0], nil
-	}
-
-	if len(leaves)%2 == 1 {
-		log.Error("Leaves length is odd to get keccak merkle root", len(leaves))
-		errorMessage := fmt.Sprintf("Leaves length should be even: %d", len(leaves))
-		err := errors.New(errorMessage)
-		return []byte{}, err
-	}
-	for i := 0; i < len(leaves); i += 2 {
-		data := append([]byte{}, leaves[i]...)
-		data = append(data, leaves[i+1]...)
-		hash := sha3.NewLegacyKeccak256()
-		_, err := hash.Write(data)
-		if err != nil {
-			log.Errorf("Failed to write hash %s\n", err.Error())
-			return []byte{}, err
-		}
-		hashes = append(hashes, hash.Sum(nil))
-	}
-	return keccakMerkleRoot(hashes)
-}
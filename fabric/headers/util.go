@@ -5,6 +5,7 @@ import (
 	"math/big"
 
 	util "github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
 
 	"github.com/celer-network/goutils/log"
 	"github.com/ethereum/go-ethereum/common"
@@ -12,14 +13,19 @@ import (
 )
 
 func NewChunkProofCircuit(count int) *Circuit {
-	hs := genHeaders(count)
+	return newChunkProofCircuit(util.NewHeaders(genHeaders(count)), count, true)
+}
 
-	headersEncoded, roundIdxs, err := util.EncodeHeaders(hs, true)
+// newChunkProofCircuit returns the witness of a chunk of headers, which may be of different forks, in a circuit of
+// maxHeaders headers
+func newChunkProofCircuit(hs []util.Header, maxHeaders int, dummyHeaders bool) *Circuit {
+	headersEncoded, err := util.EncodeHeaders(hs, dummyHeaders)
 	if err != nil {
 		fmt.Printf("failed to encode headers: %s\n", err.Error())
 		return nil
 	}
-	root, err := util.ComputeChunkRoot(hs)
+	headersEncoded = util.PadEncodedHeaders(headersEncoded, maxHeaders)
+	root, err := util.ComputeChunkRoot(hs, maxHeaders)
 	fmt.Printf("chunk root %x\n", root)
 	if err != nil {
 		log.Errorf("Failed to compute chunk root: %s\n", err.Error())
@@ -37,10 +43,27 @@ func NewChunkProofCircuit(count int) *Circuit {
 		ChunkRoot:     chunkRoot,
 		PrevHash:      prevHash,
 		EndHash:       endHash,
-		StartBlockNum: 1,
-		EndBlockNum:   count,
-		HashRoundIdxs: roundIdxs,
+		StartBlockNum: hs[0].Number,
+		EndBlockNum:   hs[len(hs)-1].Number,
+		HeaderCount:   len(hs),
+	}
+}
+
+// newMMRChunkCircuit returns the witness of a chunk of headers in a circuit of maxHeaders headers appending them to
+// the mountain range mmr, which is updated
+func newMMRChunkCircuit(hs []util.Header, maxHeaders int, mmr *native.MMR) (*MMRCircuit, error) {
+	c := &MMRCircuit{Circuit: *newChunkProofCircuit(hs, maxHeaders, false), LeafCount: mmr.LeafCount()}
+	for _, peak := range mmr.Peaks() {
+		c.Peaks = append(c.Peaks, util.Hash2FV(peak))
+	}
+	if err := util.AppendHeaders(mmr, hs); err != nil {
+		return nil, err
+	}
+	for _, peak := range mmr.Peaks() {
+		c.NewPeaks = append(c.NewPeaks, util.Hash2FV(peak))
 	}
+	c.NewLeafCount = mmr.LeafCount()
+	return c, nil
 }
 
 func genHeaders(count int) []types.Header {
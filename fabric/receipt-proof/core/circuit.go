@@ -22,20 +22,23 @@ type ReceiptProofCircuit struct {
 	BlockHash   [2]frontend.Variable `gnark:",public"`
 	BlockNumber frontend.Variable    `gnark:",public"`
 	BlockTime   frontend.Variable    `gnark:",public"`
+	ReceiptBlockProof
+}
+
+// ReceiptBlockProof is the receipt trie and block header witness of ReceiptProofCircuit and ReceiptProofChunkCircuit
+type ReceiptBlockProof struct {
 	// mpt
 	Key                  [ReceiptMPTProofKeyMaxLength]frontend.Variable
 	KeyLength            frontend.Variable
 	RootHash             [ReceiptMPTRootHashLength]frontend.Variable
 	KeyFragmentStarts    [ReceiptMPTProofMaxDepth]frontend.Variable
 	NodeRlp              [ReceiptMPTProofMaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [ReceiptMPTProofMaxDepth - 1]frontend.Variable
 	NodePathPrefixLength [ReceiptMPTProofMaxDepth - 1]frontend.Variable
 	NodeTypes            [ReceiptMPTProofMaxDepth - 1]frontend.Variable
 	Depth                frontend.Variable
 
-	BlockHashRlp    [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
-	BlockFieldsNum  frontend.Variable // block heard fields number
-	BlockRoundIndex frontend.Variable
+	BlockHashRlp   [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
+	BlockFieldsNum frontend.Variable // block heard fields number
 }
 
 func (c *ReceiptProofCircuit) Define(api frontend.API) error {
@@ -47,15 +50,13 @@ func (c *ReceiptProofCircuit) Define(api frontend.API) error {
 
 	result := mpt.CheckMPTInclusionNoBranchTermination(
 		api,
-		ReceiptMPTProofMaxDepth,
-		ReceiptMPTProofKeyMaxLength,
+		mpt.NewMPTConfig(ReceiptMPTProofMaxDepth, ReceiptMPTProofKeyMaxLength, 0),
 		c.Key[:],
 		c.KeyLength,
 		c.RootHash,
 		c.KeyFragmentStarts[:],
 		c.LeafHash,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength[:],
 		c.NodeTypes[:],
 		c.Depth,
@@ -63,7 +64,7 @@ func (c *ReceiptProofCircuit) Define(api frontend.API) error {
 
 	api.AssertIsEqual(result.Output, 1)
 
-	rlpBlockHashResult := mpt.CheckEthBlockHash(api, c.BlockHashRlp, c.BlockFieldsNum, c.BlockRoundIndex)
+	rlpBlockHashResult := mpt.CheckEthBlockHash(api, c.BlockHashRlp, c.BlockFieldsNum)
 	blockHashNibbles := espcore.Recompose32ByteToNibbles(api, c.BlockHash)
 	blockHashEqual := rlp.ArrayEqual(api, blockHashNibbles[:], rlpBlockHashResult.BlockHash[:], 64, 64)
 	api.AssertIsEqual(blockHashEqual, 1)
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// ReceiptProofChunkCircuit is ReceiptProofCircuit against the ChunkRoot of a chunk of headers instead of a block
+// hash. The block hash is private, it is the leaf of index BlockIndex in the tree of the chunk with the siblings
+// ChunkProof. The depth of the chunk is fixed when the circuit is compiled, use NewReceiptProofChunkCircuit to
+// allocate both the circuit and its assignment.
+type ReceiptProofChunkCircuit struct {
+	LeafHash    [2]frontend.Variable `gnark:",public"`
+	ChunkRoot   [2]frontend.Variable `gnark:",public"`
+	BlockNumber frontend.Variable    `gnark:",public"`
+	BlockTime   frontend.Variable    `gnark:",public"`
+	BlockHash   [2]frontend.Variable
+	BlockIndex  frontend.Variable
+	ChunkProof  [][2]frontend.Variable
+	ReceiptBlockProof
+}
+
+// NewReceiptProofChunkCircuit allocates the circuit for chunk proofs of chunkDepth siblings, see
+// mpt.CheckEthBlockHashInChunk
+func NewReceiptProofChunkCircuit(chunkDepth int) *ReceiptProofChunkCircuit {
+	return &ReceiptProofChunkCircuit{ChunkProof: make([][2]frontend.Variable, chunkDepth)}
+}
+
+// NewReceiptProofChunkWitness returns the assignment of the chunk circuit for the assignment w of ReceiptProofCircuit,
+// with the block of w at index blockIndex in the chunk of chunkRoot, see headerutil.ComputeChunkProof
+func NewReceiptProofChunkWitness(w *ReceiptProofCircuit, chunkRoot []byte, blockIndex int, chunkProof [][]byte) *ReceiptProofChunkCircuit {
+	c := &ReceiptProofChunkCircuit{
+		LeafHash:          w.LeafHash,
+		ChunkRoot:         [2]frontend.Variable{chunkRoot[:16], chunkRoot[16:]},
+		BlockNumber:       w.BlockNumber,
+		BlockTime:         w.BlockTime,
+		BlockHash:         w.BlockHash,
+		BlockIndex:        blockIndex,
+		ReceiptBlockProof: w.ReceiptBlockProof,
+	}
+	for _, sibling := range chunkProof {
+		c.ChunkProof = append(c.ChunkProof, [2]frontend.Variable{sibling[:16], sibling[16:]})
+	}
+	return c
+}
+
+func (c *ReceiptProofChunkCircuit) Define(api frontend.API) error {
+	mpt.CheckEthBlockHashInChunk(api, c.ChunkRoot, c.BlockHash, c.BlockIndex, c.ChunkProof)
+
+	// the receipt against the block hash
+	block := &ReceiptProofCircuit{
+		LeafHash:          c.LeafHash,
+		BlockHash:         c.BlockHash,
+		BlockNumber:       c.BlockNumber,
+		BlockTime:         c.BlockTime,
+		ReceiptBlockProof: c.ReceiptBlockProof,
+	}
+	return block.Define(api)
+}
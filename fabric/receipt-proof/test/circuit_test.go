@@ -7,10 +7,12 @@ import (
 
 	"github.com/celer-network/brevis-circuits/fabric/receipt-proof/core"
 	"github.com/celer-network/brevis-circuits/fabric/receipt-proof/util"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
 	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/test"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/iden3/go-iden3-crypto/keccak256"
 )
@@ -65,3 +67,24 @@ func TestReceiptCircuit(t *testing.T) {
 	err = test.IsSolved(&core.ReceiptProofCircuit{}, witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
+
+func TestReceiptChunkCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	transactionHash := "0xcde39c41dce4a10417e5a268ae5cc608816547650b413a1ac182971f02399443"
+
+	witness, err := util.GenerateReceiptCircuitProofWitness("https://ethereum.blockpi.network/v1/rpc/public", transactionHash)
+	assert.NoError(err)
+
+	// the block is the last of a chunk of 3 blocks
+	blockHash := append(append([]byte{}, witness.BlockHash[0].([]byte)...), witness.BlockHash[1].([]byte)...)
+	leaves := [][]byte{crypto.Keccak256([]byte("first")), crypto.Keccak256([]byte("second")), blockHash}
+	root, err := native.PaddedKeccakMerkleRoot(leaves)
+	assert.NoError(err)
+	proof, err := native.PaddedKeccakMerkleProof(leaves, 2)
+	assert.NoError(err)
+
+	chunkWitness := core.NewReceiptProofChunkWitness(witness, root, 2, proof)
+	err = test.IsSolved(core.NewReceiptProofChunkCircuit(len(proof)), chunkWitness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
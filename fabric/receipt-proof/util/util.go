@@ -8,9 +8,10 @@ import (
 	"strings"
 
 	bccommon "github.com/celer-network/brevis-circuits/common"
+	"github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
 	"github.com/celer-network/brevis-circuits/fabric/receipt-proof/core"
-	"github.com/celer-network/brevis-circuits/gadgets/keccak"
 	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
 	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark/frontend"
 	"github.com/ethereum/go-ethereum/common"
@@ -45,7 +46,8 @@ func GenerateReceiptMPTProof(rpcUrl, transactionHash string) (*ReceiptProofData,
 		return nil, err
 	}
 
-	header, err := ec.HeaderByNumber(context.Background(), receipt.BlockNumber)
+	// the geth client drops the header fields after Shanghai, which changes the header rlp
+	header, err := headerutil.HeaderByNumber(context.Background(), rpcUrl, receipt.BlockNumber)
 	if err != nil {
 		log.Errorf("Failed to retrieve block header: %+v\n", err.Error())
 		return nil, err
@@ -95,7 +97,7 @@ func GenerateReceiptMPTProof(rpcUrl, transactionHash string) (*ReceiptProofData,
 
 	return &ReceiptProofData{
 		TransactionHash: transactionHash,
-		BlockHash:       bk.Hash().String(),
+		BlockHash:       header.Hash().String(),
 		BlockNumber:     bk.NumberU64(),
 		BlockTime:       bk.Time(),
 		MPTKey:          hex.EncodeToString(keyIndex),
@@ -135,13 +137,6 @@ func GenerateReceiptCircuitProofWitness(rpcUrl, transactionHash string) (*core.R
 		intValue, _ := strconv.ParseInt(string(rootHashHexString[i]), 16, 64)
 		rootHash[i] = intValue
 	}
-	var keyFragmentStarts [core.ReceiptMPTProofMaxDepth]frontend.Variable
-	for i := 0; i < core.ReceiptMPTProofMaxDepth; i++ {
-		keyFragmentStarts[i] = i
-		if i > depth-1 {
-			keyFragmentStarts[i] = 6
-		}
-	}
 
 	mptKey := receiptProofData.MPTKey
 
@@ -183,48 +178,33 @@ func GenerateReceiptCircuitProofWitness(rpcUrl, transactionHash string) (*core.R
 	leafHashFV[0] = leafHashBytes[0:16]
 	leafHashFV[1] = leafHashBytes[16:32]
 
+	var proofNodes [][]byte
+	for _, rlpHexString := range receiptProofData.MPTProofs {
+		node, err := hexutil.Decode(rlpHexString)
+		if err != nil {
+			log.Errorf("Failed to decode mpt proof %s: %s", transactionHash, err.Error())
+			return nil, err
+		}
+		proofNodes = append(proofNodes, node)
+	}
+	keyBytes, _ := hex.DecodeString(receiptProofData.MPTKey)
+	proofWitness, err := witness.NewProof(proofNodes, witness.BytesToNibbles(keyBytes), core.ReceiptMPTProofKeyMaxLength, core.ReceiptMPTProofMaxDepth)
+	if err != nil {
+		log.Errorf("Failed to prepare mpt proof witness %s: %s", transactionHash, err.Error())
+		return nil, err
+	}
+
+	var keyFragmentStarts [core.ReceiptMPTProofMaxDepth]frontend.Variable
+	copy(keyFragmentStarts[:], proofWitness.KeyFragmentStarts)
+
 	var nodeRlp [core.ReceiptMPTProofMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [core.ReceiptMPTProofMaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [core.ReceiptMPTProofMaxDepth - 1]frontend.Variable
 	var nodeTypes [core.ReceiptMPTProofMaxDepth - 1]frontend.Variable
-
 	for i := 0; i < core.ReceiptMPTProofMaxDepth-1; i++ {
-		if i < depth-1 {
-			// Feed actual data
-			rlpHexString := receiptProofData.MPTProofs[i]
-			bytes, _ := hexutil.Decode(rlpHexString)
-			paddedBytes := keccak.Pad101Bytes(bytes)
-			var rlp [mpt.BranchNodeMaxBlockSize]frontend.Variable
-			for i, b := range paddedBytes {
-				n1 := b >> 4
-				n2 := b & 0x0F
-				rlp[i*2] = n1
-				rlp[i*2+1] = n2
-			}
-
-			for j := len(paddedBytes) * 2; j < 272*4; j++ {
-				rlp[j] = 0
-			}
-
-			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
-
-			nodeType, pathPrefixLength := GetRlpPathPrefixLength(rlpHexString)
-			nodePathPrefixLength[i] = pathPrefixLength
-			nodeTypes[i] = nodeType
-		} else {
-			// Add placeholder data
-			var empty [1088]frontend.Variable
-			for j := 0; j < 1088; j++ {
-				empty[j] = 0
-			}
-			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
-			nodePathPrefixLength[i] = 0
-			nodeTypes[i] = 0
-		}
+		copy(nodeRlp[i][:], proofWitness.NodeRlp[i])
 	}
+	copy(nodePathPrefixLength[:], proofWitness.NodePathPrefixLength)
+	copy(nodeTypes[:], proofWitness.NodeTypes)
 
 	blockHashBytes, _ := hexutil.Decode(receiptProofData.BlockHash)
 	var blockHashFV [2]frontend.Variable
@@ -233,71 +213,34 @@ func GenerateReceiptCircuitProofWitness(rpcUrl, transactionHash string) (*core.R
 
 	blockRlpHex := receiptProofData.BlockRlp
 	rlpBytes, _ := hexutil.Decode(blockRlpHex)
-	blockRoundIndex := keccak.GetRoundIndex(len(rlpBytes) * 8)
-	paddedRlpBytes := keccak.Pad101Bytes(rlpBytes)
 
 	var blockHashRlpFV [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
-	for i, b := range paddedRlpBytes {
+	for i, b := range rlpBytes {
 		blockHashRlpFV[i*2] = b >> 4
 		blockHashRlpFV[i*2+1] = b & 0x0F
 	}
 
-	for i := len(paddedRlpBytes) * 2; i < mpt.EthBlockHeadMaxBlockHexSize; i++ {
+	for i := len(rlpBytes) * 2; i < mpt.EthBlockHeadMaxBlockHexSize; i++ {
 		blockHashRlpFV[i] = 0
 	}
 
 	return &core.ReceiptProofCircuit{
-		LeafHash:             leafHashFV,
-		BlockHash:            blockHashFV,
-		BlockNumber:          receiptProofData.BlockNumber,
-		BlockTime:            receiptProofData.BlockTime,
-		Key:                  keyRlpHex,
-		KeyLength:            keyHexLen,
-		RootHash:             rootHash,
-		KeyFragmentStarts:    keyFragmentStarts,
-		NodeRlp:              nodeRlp,
-		NodePathPrefixLength: nodePathPrefixLength,
-		NodeTypes:            nodeTypes,
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
-		Depth:                depth,
-
-		BlockHashRlp:    blockHashRlpFV,
-		BlockFieldsNum:  receiptProofData.BlockFieldsNum,
-		BlockRoundIndex: blockRoundIndex,
+		LeafHash:    leafHashFV,
+		BlockHash:   blockHashFV,
+		BlockNumber: receiptProofData.BlockNumber,
+		BlockTime:   receiptProofData.BlockTime,
+		ReceiptBlockProof: core.ReceiptBlockProof{
+			Key:                  keyRlpHex,
+			KeyLength:            keyHexLen,
+			RootHash:             rootHash,
+			KeyFragmentStarts:    keyFragmentStarts,
+			NodeRlp:              nodeRlp,
+			NodePathPrefixLength: nodePathPrefixLength,
+			NodeTypes:            nodeTypes,
+			Depth:                depth,
+
+			BlockHashRlp:   blockHashRlpFV,
+			BlockFieldsNum: receiptProofData.BlockFieldsNum,
+		},
 	}, nil
 }
-
-func GetRlpPathPrefixLength(nodeRlp string) (nodeType int, pathPrefixLength int) {
-	input, err := hexutil.Decode(nodeRlp)
-
-	if err != nil {
-		log.Error("Failed to decode node rlp", nodeRlp, err.Error())
-	}
-
-	var decodeValue [][]byte
-	err = rlp.Decode(bytes.NewReader(input), &decodeValue)
-
-	if err != nil {
-		log.Error("Failed to decode", err)
-	}
-
-	if len(decodeValue) == 17 {
-		nodeType = 0
-		pathPrefixLength = 0
-		return
-	} else if len(decodeValue) == 2 {
-		nodeType = 1
-		if decodeValue[0][0] == 0 {
-			pathPrefixLength = 2
-		} else {
-			pathPrefixLength = 1
-		}
-		return
-	}
-
-	log.Error("Failed to decide node type", nodeRlp, decodeValue)
-
-	nodeType = 0
-	pathPrefixLength = 0
-	return
-}
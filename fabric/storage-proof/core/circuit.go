@@ -11,10 +11,8 @@ type StorageProofCircuit struct {
 	Value                [mpt.StorageMaxValueLength]frontend.Variable `gnark:",public"`
 	KeyFragmentStarts    [mpt.StorageMPTMaxDepth]frontend.Variable
 	LeafRlp              [mpt.StorageLeafMaxBlockHexLen]frontend.Variable
-	LeafRlpRoundIndex    frontend.Variable
 	LeafPathPrefixLength frontend.Variable
 	NodeRlp              [mpt.StorageMPTMaxDepth - 1][272 * 4]frontend.Variable
-	NodeRoundIndexes     [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	NodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	NodeTypes            [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	Depth                frontend.Variable
@@ -38,10 +36,8 @@ func (c *StorageProofCircuit) Define(api frontend.API) error {
 		c.Value,
 		c.KeyFragmentStarts[:],
 		c.LeafRlp[:],
-		c.LeafRlpRoundIndex,
 		c.LeafPathPrefixLength,
 		nodeRlp,
-		c.NodeRoundIndexes[:],
 		c.NodePathPrefixLength[:],
 		c.NodeTypes[:],
 		c.Depth,
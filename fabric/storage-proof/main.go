@@ -9,8 +9,8 @@ import (
 
 	"github.com/celer-network/brevis-circuits/fabric/storage-proof/core"
 
-	"github.com/celer-network/brevis-circuits/gadgets/keccak"
 	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
 	"github.com/celer-network/goutils/log"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/groth16"
@@ -21,8 +21,6 @@ import (
 )
 
 func main() {
-	depth := 3
-
 	keyRlpHexString := "290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563"
 	keyHexLen := len(keyRlpHexString)
 	var keyRlpHex [mpt.AccountKeyLength]frontend.Variable
@@ -54,35 +52,7 @@ func main() {
 		rootHashHex[i] = intValue
 	}
 
-	var keyFragmentStarts [mpt.StorageMPTMaxDepth]frontend.Variable
-	for i := 0; i < mpt.StorageMPTMaxDepth; i++ {
-		keyFragmentStarts[i] = i
-		if i > depth-1 {
-			keyFragmentStarts[i] = 64
-		}
-	}
-
 	leafRlpHexString := "0xf7a0200decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e5639594bc50cbd395314a43302e3bf56677755e5a543a8c"
-	leafRlpBytes, _ := hexutil.Decode(leafRlpHexString)
-	paddedLeafRlpBytes := keccak.Pad101Bytes(leafRlpBytes)
-
-	leafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(leafRlpHexString) - 2)
-
-	var paddedLeafRlpHex [272]frontend.Variable
-	for i, b := range paddedLeafRlpBytes {
-		n1 := b >> 4
-		n2 := b & 0x0F
-
-		paddedLeafRlpHex[i*2] = n1
-		paddedLeafRlpHex[i*2+1] = n2
-	}
-
-	var leafPathPrefixLength int // 20 0decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563
-	if (64-depth+1)%2 == 0 {
-		leafPathPrefixLength = 2
-	} else {
-		leafPathPrefixLength = 1
-	}
 
 	/// Proofs without leaf level ---> accountProof[0:len(accountProof) - 1]
 	nodeRlpHexStrings := []string{
@@ -90,48 +60,34 @@ func main() {
 		"0xf8d18080808080a0f7b56be8dd71e675bf18c14afe0936d94d8883b9bbfcaee55e261a0b1dae1ea580a0c81a7ed63fb141b3f0302002ec0d5dcedeab671835adc6bc4d7f17e030717dd980a0d0a95e510d498a8b510ea71f8842528f438fc7e43e996c27e87774a52bee2c1aa0ff1f3593598f45c98daa085532e5051fe09da692b75e03a881cf29b1411fa92480a09d65e3575d4d5b52401675206aac2a225ae72d9ef0044e521fd13af454925d9ea0fa5a015c91c948b3b811f3960cba4a588fad127ca6c1026f5ee5171273074cc4808080",
 	}
 
+	var proofNodes [][]byte
+	for _, rlpHexString := range append(nodeRlpHexStrings, leafRlpHexString) {
+		node, _ := hexutil.Decode(rlpHexString)
+		proofNodes = append(proofNodes, node)
+	}
+	slotHash, _ := hexutil.Decode("0x" + keyRlpHexString)
+	proofWitness, err := witness.NewProof(proofNodes, witness.BytesToNibbles(slotHash), mpt.AccountKeyLength, mpt.StorageMPTMaxDepth)
+	if err != nil {
+		log.Fatal("failed to prepare the storage proof witness", err)
+	}
+	leafRlp, err := proofWitness.LeafRlp(272)
+	if err != nil {
+		log.Fatal("failed to prepare the storage proof witness", err)
+	}
+
+	var keyFragmentStarts [mpt.StorageMPTMaxDepth]frontend.Variable
+	copy(keyFragmentStarts[:], proofWitness.KeyFragmentStarts)
+	var paddedLeafRlpHex [272]frontend.Variable
+	copy(paddedLeafRlpHex[:], leafRlp)
+
 	var nodeRlp [mpt.StorageMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable
 	var nodeTypes [mpt.StorageMPTMaxDepth - 1]frontend.Variable
-
-	realDataLength := len(nodeRlpHexStrings)
-
 	for i := 0; i < mpt.StorageMPTMaxDepth-1; i++ {
-		if i < realDataLength {
-			// Feed actual data
-			rlpHexString := nodeRlpHexStrings[i]
-			bytes, _ := hexutil.Decode(rlpHexString)
-			paddedBytes := keccak.Pad101Bytes(bytes)
-			var rlp [mpt.BranchNodeMaxBlockSize]frontend.Variable
-			for i, b := range paddedBytes {
-				n1 := b >> 4
-				n2 := b & 0x0F
-				rlp[i*2] = n1
-				rlp[i*2+1] = n2
-			}
-
-			for j := len(paddedBytes) * 2; j < 272*4; j++ {
-				rlp[j] = 0
-			}
-
-			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
-		} else {
-			// Add placeholder data
-			var empty [1088]frontend.Variable
-			for j := 0; j < 1088; j++ {
-				empty[j] = 0
-			}
-			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
-		}
-
-		// TODO: calculate prefix length and node types dynamically
-		nodePathPrefixLength[i] = 0
-		nodeTypes[i] = 0
+		copy(nodeRlp[i][:], proofWitness.NodeRlp[i])
 	}
+	copy(nodePathPrefixLength[:], proofWitness.NodePathPrefixLength)
+	copy(nodeTypes[:], proofWitness.NodeTypes)
 
 	output := 1
 
@@ -159,13 +115,11 @@ func main() {
 		Value:                valueRlpHex,
 		KeyFragmentStarts:    keyFragmentStarts,
 		LeafRlp:              paddedLeafRlpHex,
-		LeafPathPrefixLength: leafPathPrefixLength,
-		LeafRlpRoundIndex:    leafRlpRoundIndex,
+		LeafPathPrefixLength: proofWitness.Leaf.PathPrefixLength,
 		NodeRlp:              nodeRlp,
-		NodeRoundIndexes:     nodeRlpRoundIndexes,
 		NodePathPrefixLength: nodePathPrefixLength,
 		NodeTypes:            nodeTypes,
-		Depth:                depth,
+		Depth:                proofWitness.Depth,
 		Output:               output,
 		SlotValue:            valueHex,
 		ValueLength:          valueHexLen,
@@ -182,10 +136,10 @@ func main() {
 		log.Fatal("groth16.Setup")
 	}
 
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, _ := witness.Public()
+	fullWitness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	publicWitness, _ := fullWitness.Public()
 
-	proof, err := groth16.Prove(ccs, pk, witness)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
 	if err != nil {
 		debug.PrintStack()
 		log.Fatal("prove computation failed...", err)
@@ -1,10 +1,14 @@
 package core
 
 import (
+	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/celer-network/brevis-circuits/fabric/common"
+	"github.com/celer-network/brevis-circuits/fabric/sync-committee/core/native"
 	"github.com/celer-network/brevis-circuits/gadgets/pairing_bls12381"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
 	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
 	"github.com/consensys/gnark/frontend"
@@ -56,3 +60,32 @@ func TestCircuitTestSolve(t *testing.T) {
 	err := test.IsSolved(&SyncCommitteeUpdateCircuit{}, &witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
+
+type PoseidonCommitmentCircuit struct {
+	Limbs      [common.LenOfTotalPoseidonNums]frontend.Variable
+	Commitment frontend.Variable
+}
+
+func (c *PoseidonCommitmentCircuit) Define(api frontend.API) error {
+	poseidon := NewPoseidon(api, c.Limbs)
+	api.AssertIsEqual(poseidon.Commitment(), c.Commitment)
+	return nil
+}
+
+func TestPoseidonCommitmentRandom(t *testing.T) {
+	randtest.Check(t, 2, &PoseidonCommitmentCircuit{}, func(rng *rand.Rand) frontend.Circuit {
+		w := &PoseidonCommitmentCircuit{}
+		var limbs []*big.Int
+		for i := range w.Limbs {
+			limb := new(big.Int).SetBytes(randtest.Bytes(rng, common.BytesPerLimb))
+			limbs = append(limbs, limb)
+			w.Limbs[i] = limb
+		}
+		commitment, err := native.PoseidonChain(limbs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Commitment = commitment
+		return w
+	})
+}
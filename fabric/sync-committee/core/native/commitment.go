@@ -0,0 +1,72 @@
+// Package native computes the sync committee commitments out of circuit
+package native
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+// PoseidonChain is core.PoseidonPhase0SyncCommittee.Commitment on any number of elements: the first 15 elements
+// are hashed, then every next 15 elements followed by the previous hash. As in the gadget, appending the previous
+// hash to a chunk in the middle writes it over the first element of the next chunk, so from the third hash on the
+// first element of a chunk is the hash before the previous one. elements is not modified.
+func PoseidonChain(elements []*big.Int) (*big.Int, error) {
+	if len(elements) < 15 {
+		return nil, fmt.Errorf("poseidon chain of %d elements, need at least 15", len(elements))
+	}
+	limbs := append([]*big.Int{}, elements...)
+	total := len(limbs)/15 + 1
+	var lastHash, hash *big.Int
+	var err error
+	for i := 0; i < total; i++ {
+		if i == 0 {
+			hash, err = poseidon.Hash(limbs[i*15 : i*15+15])
+		} else if i == total-1 {
+			hash, err = poseidon.Hash(append(limbs[i*15:], lastHash))
+		} else {
+			hash, err = poseidon.Hash(append(limbs[i*15:i*15+15], lastHash))
+		}
+		if err != nil {
+			return nil, err
+		}
+		lastHash = hash
+	}
+	return hash, nil
+}
+
+// SSZRoot is the sha256 merkle root of the 32 byte chunks of input, computed like core.SSZPhase0SyncCommittee. The
+// input length must be a power of two multiple of 64.
+func SSZRoot(input []byte) []byte {
+	output := make([]byte, len(input)/2)
+	numPairs := len(input) / 64
+	for i := 0; i < numPairs; i++ {
+		h := sha256.Sum256(input[i*64 : (i+1)*64])
+		copy(output[i*32:(i+1)*32], h[:])
+	}
+	if numPairs == 1 {
+		return output
+	}
+	return SSZRoot(output)
+}
+
+// SSZCommitment is core.SSZPhase0SyncCommittee.Commitment, the sha256 of the ssz roots of the pubkeys and of the
+// aggregate pubkey, each pubkey padded to 64 bytes
+func SSZCommitment(pubkeys [][]byte, aggregatePubkey []byte) []byte {
+	var validators []byte
+	for _, pubkey := range pubkeys {
+		validators = append(validators, padTo64(pubkey)...)
+	}
+	h := sha256.New()
+	h.Write(SSZRoot(validators))
+	h.Write(SSZRoot(padTo64(aggregatePubkey)))
+	return h.Sum(nil)
+}
+
+func padTo64(b []byte) []byte {
+	padded := make([]byte, 64)
+	copy(padded, b)
+	return padded
+}
@@ -20,21 +20,23 @@ type TxHashCheckCircuit struct {
 	BlockHash   [2]frontend.Variable `gnark:",public"`
 	BlockNumber frontend.Variable    `gnark:",public"`
 	BlockTime   frontend.Variable    `gnark:",public"`
+	TxBlockProof
+}
 
+// TxBlockProof is the transaction trie and block header witness of TxHashCheckCircuit and TxHashCheckChunkCircuit
+type TxBlockProof struct {
 	// mpt
 	Key                  [TransactionMaxKeyHexLen]frontend.Variable
 	KeyLength            frontend.Variable
 	RootHash             [64]frontend.Variable
 	KeyFragmentStarts    [TransactionMPTMaxDepth]frontend.Variable
 	NodeRlp              [TransactionMPTMaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [TransactionMPTMaxDepth - 1]frontend.Variable
 	NodePathPrefixLength [TransactionMPTMaxDepth - 1]frontend.Variable
 	NodeTypes            [TransactionMPTMaxDepth - 1]frontend.Variable
 	Depth                frontend.Variable
 
-	BlockHashRlp    [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
-	BlockFieldsNum  frontend.Variable // block header fields number
-	BlockRoundIndex frontend.Variable
+	BlockHashRlp   [mpt.EthBlockHeadMaxBlockHexSize]frontend.Variable
+	BlockFieldsNum frontend.Variable // block header fields number
 }
 
 func (c *TxHashCheckCircuit) Define(api frontend.API) error {
@@ -47,15 +49,13 @@ func (c *TxHashCheckCircuit) Define(api frontend.API) error {
 
 	result := mpt.CheckMPTInclusionNoBranchTermination(
 		api,
-		TransactionMPTMaxDepth,
-		TransactionMaxKeyHexLen,
+		mpt.NewMPTConfig(TransactionMPTMaxDepth, TransactionMaxKeyHexLen, 0),
 		c.Key[:],
 		c.KeyLength,
 		c.RootHash,
 		c.KeyFragmentStarts[:],
 		c.LeafHash,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength[:],
 		c.NodeTypes[:],
 		c.Depth,
@@ -63,7 +63,7 @@ func (c *TxHashCheckCircuit) Define(api frontend.API) error {
 
 	api.AssertIsEqual(result.Output, 1)
 
-	rlpBlockHashResult := mpt.CheckEthBlockHash(api, c.BlockHashRlp, c.BlockFieldsNum, c.BlockRoundIndex)
+	rlpBlockHashResult := mpt.CheckEthBlockHash(api, c.BlockHashRlp, c.BlockFieldsNum)
 
 	// rlpBlockHashResult.
 	blockHashNibbles := espcore.Recompose32ByteToNibbles(api, c.BlockHash)
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// TxHashCheckChunkCircuit is TxHashCheckCircuit against the ChunkRoot of a chunk of headers instead of a block
+// hash. The block hash is private, it is the leaf of index BlockIndex in the tree of the chunk with the siblings
+// ChunkProof. The depth of the chunk is fixed when the circuit is compiled, use NewTxHashCheckChunkCircuit to
+// allocate both the circuit and its assignment.
+type TxHashCheckChunkCircuit struct {
+	LeafHash    [2]frontend.Variable `gnark:",public"`
+	ChunkRoot   [2]frontend.Variable `gnark:",public"`
+	BlockNumber frontend.Variable    `gnark:",public"`
+	BlockTime   frontend.Variable    `gnark:",public"`
+	BlockHash   [2]frontend.Variable
+	BlockIndex  frontend.Variable
+	ChunkProof  [][2]frontend.Variable
+	TxBlockProof
+}
+
+// NewTxHashCheckChunkCircuit allocates the circuit for chunk proofs of chunkDepth siblings, see
+// mpt.CheckEthBlockHashInChunk
+func NewTxHashCheckChunkCircuit(chunkDepth int) *TxHashCheckChunkCircuit {
+	return &TxHashCheckChunkCircuit{ChunkProof: make([][2]frontend.Variable, chunkDepth)}
+}
+
+// NewTxHashCheckChunkWitness returns the assignment of the chunk circuit for the assignment w of TxHashCheckCircuit,
+// with the block of w at index blockIndex in the chunk of chunkRoot, see headerutil.ComputeChunkProof
+func NewTxHashCheckChunkWitness(w *TxHashCheckCircuit, chunkRoot []byte, blockIndex int, chunkProof [][]byte) *TxHashCheckChunkCircuit {
+	c := &TxHashCheckChunkCircuit{
+		LeafHash:     w.LeafHash,
+		ChunkRoot:    [2]frontend.Variable{chunkRoot[:16], chunkRoot[16:]},
+		BlockNumber:  w.BlockNumber,
+		BlockTime:    w.BlockTime,
+		BlockHash:    w.BlockHash,
+		BlockIndex:   blockIndex,
+		TxBlockProof: w.TxBlockProof,
+	}
+	for _, sibling := range chunkProof {
+		c.ChunkProof = append(c.ChunkProof, [2]frontend.Variable{sibling[:16], sibling[16:]})
+	}
+	return c
+}
+
+func (c *TxHashCheckChunkCircuit) Define(api frontend.API) error {
+	mpt.CheckEthBlockHashInChunk(api, c.ChunkRoot, c.BlockHash, c.BlockIndex, c.ChunkProof)
+
+	// the transaction against the block hash
+	block := &TxHashCheckCircuit{
+		LeafHash:     c.LeafHash,
+		BlockHash:    c.BlockHash,
+		BlockNumber:  c.BlockNumber,
+		BlockTime:    c.BlockTime,
+		TxBlockProof: c.TxBlockProof,
+	}
+	return block.Define(api)
+}
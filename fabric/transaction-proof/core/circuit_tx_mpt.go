@@ -22,7 +22,6 @@ type TransactionMptCircuit struct {
 	LeafHash             [2]frontend.Variable
 	LeafPathPrefixLength frontend.Variable
 	NodeRlp              [TransactionMPTMaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [TransactionMPTMaxDepth - 1]frontend.Variable
 	NodePathPrefixLength [TransactionMPTMaxDepth - 1]frontend.Variable
 	NodeTypes            [TransactionMPTMaxDepth - 1]frontend.Variable
 	Depth                frontend.Variable
@@ -37,15 +36,13 @@ func (c *TransactionMptCircuit) Define(api frontend.API) error {
 
 	result := mpt.CheckMPTInclusionNoBranchTermination(
 		api,
-		TransactionMPTMaxDepth,
-		TransactionMaxKeyHexLen,
+		mpt.NewMPTConfig(TransactionMPTMaxDepth, TransactionMaxKeyHexLen, 0),
 		c.Key[:],
 		c.KeyLength,
 		c.RootHash,
 		c.KeyFragmentStarts[:],
 		c.LeafHash,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength[:],
 		c.NodeTypes[:],
 		c.Depth,
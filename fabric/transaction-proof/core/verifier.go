@@ -83,6 +83,23 @@ func Verify(api frontend.API, vk VerifyingKey, proof Proof, publicInputs []front
 
 }
 
+// AssertIsEqual asserts that the "in-circuit" VerifyingKey is other, e.g. to fix a VerifyingKey of the witness to
+// the one of the inner circuit
+func (vk *VerifyingKey) AssertIsEqual(api frontend.API, other VerifyingKey) {
+	if len(vk.G1.K) != len(other.G1.K) {
+		panic("verifying keys of different numbers of public inputs")
+
+	}
+	vk.E.AssertIsEqual(api, other.E)
+	vk.G2.GammaNeg.AssertIsEqual(api, other.G2.GammaNeg)
+	vk.G2.DeltaNeg.AssertIsEqual(api, other.G2.DeltaNeg)
+	for i := range vk.G1.K {
+		vk.G1.K[i].AssertIsEqual(api, other.G1.K[i])
+
+	}
+
+}
+
 // Assign values to the "in-circuit" VerifyingKey from a "out-of-circuit" VerifyingKey
 func (vk *VerifyingKey) Assign(_ovk groth16.VerifyingKey) {
 	ovk, ok := _ovk.(*groth16_bls12377.VerifyingKey)
@@ -5,9 +5,11 @@ import (
 
 	"github.com/celer-network/brevis-circuits/fabric/transaction-proof/core"
 	"github.com/celer-network/brevis-circuits/fabric/transaction-proof/mock"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func Test_TRANSACTIONS_MPT_LEAF_CHECK(t *testing.T) {
@@ -16,3 +18,20 @@ func Test_TRANSACTIONS_MPT_LEAF_CHECK(t *testing.T) {
 	err := test.IsSolved(&core.TxHashCheckCircuit{}, &witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
+
+func Test_TRANSACTIONS_MPT_LEAF_CHECK_CHUNK(t *testing.T) {
+	assert := test.NewAssert(t)
+	witness := mock.GetTransactionProofWitness()
+
+	// the block is the first of a chunk of 2 blocks
+	blockHash := append(append([]byte{}, witness.BlockHash[0].([]byte)...), witness.BlockHash[1].([]byte)...)
+	leaves := [][]byte{blockHash, crypto.Keccak256([]byte("next"))}
+	root, err := native.PaddedKeccakMerkleRoot(leaves)
+	assert.NoError(err)
+	proof, err := native.PaddedKeccakMerkleProof(leaves, 0)
+	assert.NoError(err)
+
+	chunkWitness := core.NewTxHashCheckChunkWitness(&witness, root, 0, proof)
+	err = test.IsSolved(core.NewTxHashCheckChunkCircuit(len(proof)), chunkWitness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
@@ -91,7 +91,6 @@ func TestTransactionRoot(t *testing.T) {
 	realDataLength := len(nodeRlpHexStrings)
 
 	var nodeRlp [core.TransactionMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [core.TransactionMPTMaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [core.TransactionMPTMaxDepth - 1]frontend.Variable
 	var nodeTypes [core.TransactionMPTMaxDepth - 1]frontend.Variable
 
@@ -114,8 +113,6 @@ func TestTransactionRoot(t *testing.T) {
 			}
 
 			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
 		} else {
 			// Add placeholder data
 			var empty [1088]frontend.Variable
@@ -123,7 +120,6 @@ func TestTransactionRoot(t *testing.T) {
 				empty[j] = 0
 			}
 			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
 		}
 
 		// TODO: add support for extension node
@@ -149,7 +145,6 @@ func TestTransactionRoot(t *testing.T) {
 		NodeRlp:              nodeRlp,
 		NodePathPrefixLength: nodePathPrefixLength,
 		NodeTypes:            nodeTypes,
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
 		Depth:                depth,
 		Output:               output,
 	}
@@ -38,3 +38,11 @@ func Bits2Nibbles(api frontend.API, bits []frontend.Variable) []frontend.Variabl
 	}
 	return bytes
 }
+
+// Uint128s2Bits is the inverse of Bits2Uint128s, it converts two uint128s back to the 256 bits of a hash
+func Uint128s2Bits(api frontend.API, u [2]frontend.Variable) [256]frontend.Variable {
+	h := append(utils.Flip(api.ToBinary(u[0], 128)), utils.Flip(api.ToBinary(u[1], 128))...)
+	var bits [256]frontend.Variable
+	copy(bits[:], utils.FlipSubSlice(h, 8))
+	return bits
+}
@@ -0,0 +1,179 @@
+package gadgets
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/sha256"
+	sha512 "github.com/celer-network/brevis-circuits/gadgets/sha512"
+
+	"github.com/consensys/gnark/frontend"
+	poseidon "github.com/liyue201/gnark-circomlib/circuits"
+)
+
+// Hasher hashes a variable number of bytes, so that gadgets built on a hash, e.g. merkle trees, can be written once
+// for all the hash functions below
+type Hasher interface {
+	// Hash returns the digest of the length first bytes of data, len(data) being the max number of bytes hashed.
+	// The data bytes are expected to be range checked by the caller.
+	Hash(data []frontend.Variable, length frontend.Variable) Digest
+}
+
+// Digest is the output of a Hasher as big endian bytes, converted to the representation wanted by the caller
+type Digest struct {
+	api   frontend.API
+	bytes []frontend.Variable
+}
+
+// NewDigest returns the digest made of bytes, e.g. a hash computed by a gadget outside of a Hasher
+func NewDigest(api frontend.API, bytes []frontend.Variable) Digest {
+	return Digest{api: api, bytes: bytes}
+}
+
+// Bytes returns the digest bytes
+func (d Digest) Bytes() []frontend.Variable {
+	return d.bytes
+}
+
+// Bits returns the digest bits, least significant bit of each byte first, as returned by keccak.Hasher.Keccak256Bits
+func (d Digest) Bits() []frontend.Variable {
+	var bits []frontend.Variable
+	for _, b := range d.bytes {
+		bits = append(bits, d.api.ToBinary(b, 8)...)
+	}
+	return bits
+}
+
+// Uint128s returns the digest as big endian 16 byte limbs, two limbs for a 32 byte digest, matching
+// conv.Bits2Uint128s and the public inputs of the circuits
+func (d Digest) Uint128s() []frontend.Variable {
+	if len(d.bytes)%16 != 0 {
+		panic(fmt.Sprintf("Uint128s: digest of %d bytes", len(d.bytes)))
+	}
+	var limbs []frontend.Variable
+	for i := 0; i < len(d.bytes); i += 16 {
+		limb := frontend.Variable(0)
+		for _, b := range d.bytes[i : i+16] {
+			limb = d.api.Add(d.api.Mul(limb, 256), b)
+		}
+		limbs = append(limbs, limb)
+	}
+	return limbs
+}
+
+type keccak256Hasher struct {
+	api frontend.API
+}
+
+// NewKeccak256Hasher returns the keccak256 Hasher, see keccak.Keccak256
+func NewKeccak256Hasher(api frontend.API) Hasher {
+	return &keccak256Hasher{api: api}
+}
+
+func (h *keccak256Hasher) Hash(data []frontend.Variable, length frontend.Variable) Digest {
+	out := keccak.Keccak256(h.api, len(data), data, length)
+	return NewDigest(h.api, out[:])
+}
+
+type sha256Hasher struct {
+	api frontend.API
+}
+
+// NewSha256Hasher returns the sha256 Hasher, see sha256.Sha256
+func NewSha256Hasher(api frontend.API) Hasher {
+	return &sha256Hasher{api: api}
+}
+
+func (h *sha256Hasher) Hash(data []frontend.Variable, length frontend.Variable) Digest {
+	out := sha256.Sha256(h.api, len(data), data, length)
+	return NewDigest(h.api, out[:])
+}
+
+type sha512Hasher struct {
+	api frontend.API
+}
+
+// NewSha512Hasher returns the sha512 Hasher with a 64 byte digest, see sha512.Sha512
+func NewSha512Hasher(api frontend.API) Hasher {
+	return &sha512Hasher{api: api}
+}
+
+func (h *sha512Hasher) Hash(data []frontend.Variable, length frontend.Variable) Digest {
+	out := sha512.Sha512(h.api, len(data), data, length)
+	return NewDigest(h.api, out[:])
+}
+
+// PoseidonChunkBytes is the number of bytes packed into a field element by the poseidon Hasher
+const PoseidonChunkBytes = 31
+
+type poseidonHasher struct {
+	api frontend.API
+}
+
+// NewPoseidonHasher returns a Hasher on the circomlib poseidon. The data is packed into big endian field elements
+// of PoseidonChunkBytes bytes, zero after length, and the inputs [length, elements...] are hashed 16 at a time,
+// each following call hashing the next 15 inputs followed by the previous hash. The digest is the 32 big endian
+// bytes of the last hash. The number of calls depends on len(data) only.
+func NewPoseidonHasher(api frontend.API) Hasher {
+	return &poseidonHasher{api: api}
+}
+
+func (h *poseidonHasher) Hash(data []frontend.Variable, length frontend.Variable) Digest {
+	api := h.api
+	api.AssertIsLessOrEqual(length, len(data))
+
+	inputs := []frontend.Variable{length}
+	inData := frontend.Variable(1)
+	for i := 0; i < len(data); i += PoseidonChunkBytes {
+		element := frontend.Variable(0)
+		for j := i; j < i+PoseidonChunkBytes; j++ {
+			b := frontend.Variable(0)
+			if j < len(data) {
+				inData = api.Sub(inData, api.IsZero(api.Sub(length, j)))
+				b = api.Mul(inData, data[j])
+			}
+			element = api.Add(api.Mul(element, 256), b)
+		}
+		inputs = append(inputs, element)
+	}
+
+	n := len(inputs)
+	if n > 16 {
+		n = 16
+	}
+	hash := poseidon.Poseidon(api, inputs[:n])
+	for i := n; i < len(inputs); i += 15 {
+		end := i + 15
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		hash = poseidon.Poseidon(api, append(append([]frontend.Variable{}, inputs[i:end]...), hash))
+	}
+
+	bits := canonicalBits(api, hash)
+	for len(bits) < 256 {
+		bits = append(bits, 0)
+	}
+	out := make([]frontend.Variable, 32)
+	for i := range out {
+		out[i] = api.FromBinary(bits[8*(31-i) : 8*(32-i)]...)
+	}
+	return NewDigest(api, out)
+}
+
+// canonicalBits returns the bits of v, least significant first, asserting that they are the bits of v and not of
+// v plus the modulus, which fits into the same number of bits
+func canonicalBits(api frontend.API, v frontend.Variable) []frontend.Variable {
+	bits := api.ToBinary(v)
+	q := api.Compiler().Field()
+	qHi := new(big.Int).Rsh(q, 128)
+	qLo := new(big.Int).Sub(q, new(big.Int).Lsh(qHi, 128))
+	hi := api.FromBinary(bits[128:]...)
+	lo := api.FromBinary(bits[:128]...)
+	// Cmp is -1 for less
+	hiLess := api.IsZero(api.Add(api.Cmp(hi, qHi), 1))
+	loLess := api.IsZero(api.Add(api.Cmp(lo, qLo), 1))
+	api.AssertIsEqual(api.Add(hiLess, api.Mul(api.IsZero(api.Sub(hi, qHi)), loLess)), 1)
+	return bits
+}
@@ -0,0 +1,142 @@
+package gadgets
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+const hasherMaxBytes = 70
+
+type HasherCircuit struct {
+	Data   [hasherMaxBytes]frontend.Variable
+	Length frontend.Variable
+	Bytes  []frontend.Variable
+	Bits   []frontend.Variable
+	Limbs  []frontend.Variable
+
+	hasher string
+}
+
+var newHashers = map[string]func(api frontend.API) Hasher{
+	"keccak256": NewKeccak256Hasher,
+	"sha256":    NewSha256Hasher,
+	"sha512":    NewSha512Hasher,
+	"poseidon":  NewPoseidonHasher,
+}
+
+func (c *HasherCircuit) Define(api frontend.API) error {
+	digest := newHashers[c.hasher](api).Hash(c.Data[:], c.Length)
+	for i, b := range digest.Bytes() {
+		api.AssertIsEqual(b, c.Bytes[i])
+	}
+	for i, b := range digest.Bits() {
+		api.AssertIsEqual(b, c.Bits[i])
+	}
+	for i, limb := range digest.Uint128s() {
+		api.AssertIsEqual(limb, c.Limbs[i])
+	}
+	return nil
+}
+
+// poseidonBytes is the poseidon Hasher out of circuit
+func poseidonBytes(data []byte, maxBytes int) []byte {
+	padded := make([]byte, (maxBytes+PoseidonChunkBytes-1)/PoseidonChunkBytes*PoseidonChunkBytes)
+	copy(padded, data)
+	inputs := []*big.Int{big.NewInt(int64(len(data)))}
+	for i := 0; i < len(padded); i += PoseidonChunkBytes {
+		inputs = append(inputs, new(big.Int).SetBytes(padded[i:i+PoseidonChunkBytes]))
+	}
+	n := len(inputs)
+	if n > 16 {
+		n = 16
+	}
+	hash, err := poseidon.Hash(inputs[:n])
+	if err != nil {
+		panic(err)
+	}
+	for i := n; i < len(inputs); i += 15 {
+		end := i + 15
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		hash, err = poseidon.Hash(append(append([]*big.Int{}, inputs[i:end]...), hash))
+		if err != nil {
+			panic(err)
+		}
+	}
+	return hash.FillBytes(make([]byte, 32))
+}
+
+func newHasherWitness(data []byte, digest []byte) *HasherCircuit {
+	w := &HasherCircuit{Length: len(data)}
+	for i := range w.Data {
+		w.Data[i] = 0
+		if i < len(data) {
+			w.Data[i] = data[i]
+		}
+	}
+	for _, b := range digest {
+		w.Bytes = append(w.Bytes, b)
+	}
+	for _, b := range keccak.Bytes2Bits(digest) {
+		w.Bits = append(w.Bits, b)
+	}
+	for i := 0; i < len(digest); i += 16 {
+		w.Limbs = append(w.Limbs, new(big.Int).SetBytes(digest[i:i+16]))
+	}
+	return w
+}
+
+func TestHasher(t *testing.T) {
+	assert := test.NewAssert(t)
+	data := make([]byte, hasherMaxBytes)
+	for i := range data {
+		data[i] = byte(i*13 + 5)
+	}
+
+	hashers := []struct {
+		name   string
+		native func(data []byte) []byte
+	}{
+		{"keccak256", func(data []byte) []byte { return crypto.Keccak256(data) }},
+		{"sha256", func(data []byte) []byte { h := sha256.Sum256(data); return h[:] }},
+		{"sha512", func(data []byte) []byte { h := sha512.Sum512(data); return h[:] }},
+		{"poseidon", func(data []byte) []byte { return poseidonBytes(data, hasherMaxBytes) }},
+	}
+	for _, h := range hashers {
+		// lengths around the sha256 padding boundaries and the poseidon chunks
+		for _, length := range []int{0, 1, 31, 55, 56, 64, hasherMaxBytes} {
+			digest := h.native(data[:length])
+			w := newHasherWitness(data[:length], digest)
+			circuit := &HasherCircuit{
+				Bytes:  make([]frontend.Variable, len(digest)),
+				Bits:   make([]frontend.Variable, 8*len(digest)),
+				Limbs:  make([]frontend.Variable, len(digest)/16),
+				hasher: h.name,
+			}
+			err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+			assert.NoError(err, "%s length %d", h.name, length)
+		}
+
+		// the digest of a different length does not match
+		w := newHasherWitness(data[:10], h.native(data[:11]))
+		digestLen := len(w.Bytes)
+		circuit := &HasherCircuit{
+			Bytes:  make([]frontend.Variable, digestLen),
+			Bits:   make([]frontend.Variable, 8*digestLen),
+			Limbs:  make([]frontend.Variable, digestLen/16),
+			hasher: h.name,
+		}
+		err := test.IsSolved(circuit, w, ecc.BN254.ScalarField())
+		assert.Error(err, h.name)
+	}
+}
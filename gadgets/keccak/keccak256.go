@@ -1,61 +1,157 @@
 package keccak
 
 import (
+	"fmt"
+
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
 	"github.com/celer-network/brevis-circuits/gadgets/mux"
 
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/std/permutation/keccakf"
 )
 
 const MAX_ROUNDS = 5
-const NORMAL_TRANSACTION_LEAF_ROUNDS = 13
-const MAX_TRANSACTION_LEAF_ROUNDS = 13
 
-func Keccak256(api frontend.API, blocks [MAX_ROUNDS][17]frontend.Variable, roundIndex frontend.Variable) (out [4]frontend.Variable) {
-	var allStates [MAX_ROUNDS + 1][25]frontend.Variable
-	var outputStates [][]frontend.Variable
-	// initial state
-	allStates[0] = [25]frontend.Variable{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-	for i := 0; i < MAX_ROUNDS; i++ {
-		newS := absorb(api, allStates[i], blocks[i])
-		allStates[i+1] = newS
-		outputStates = append(outputStates, newS[:])
+// BLOCK_HEADER_ROUNDS bounds block headers to 815 bytes, enough for the 21 fields of a Prague header
+const BLOCK_HEADER_ROUNDS = 6
+
+// Hasher computes keccak256, computing the keccak-f permutations with Permuter, e.g. a keccakf.LookupPermuter
+// instead of the bitwise permutation of NewHasher
+type Hasher struct {
+	API      frontend.API
+	Permuter keccakf.Permuter
+}
+
+// NewHasher returns the Hasher computing the permutations bitwise
+func NewHasher(api frontend.API) Hasher {
+	return Hasher{API: api, Permuter: keccakf.NewPermuter(api)}
+}
+
+// Keccak256 returns the keccak256 hash of the length first bytes of data as 32 bytes, see Hasher.Keccak256
+func Keccak256(api frontend.API, maxBytes int, data []frontend.Variable, length frontend.Variable) (out [32]frontend.Variable) {
+	return NewHasher(api).Keccak256(maxBytes, data, length)
+}
+
+// Keccak256Nibbles returns the keccak256 hash of data given as nibbles, see Hasher.Keccak256Nibbles
+func Keccak256Nibbles(api frontend.API, data []frontend.Variable, length frontend.Variable) (out [64]frontend.Variable) {
+	return NewHasher(api).Keccak256Nibbles(data, length)
+}
+
+// Keccak256 returns the keccak256 hash of the length first bytes of data as 32 bytes. The 10*1 padding is applied
+// in circuit, so data is passed unpadded and length may be anything up to maxBytes = len(data). The permutation
+// runs on maxBytes/136 + 1 blocks, the number of blocks needed for maxBytes bytes. The data bytes are expected to be
+// range checked by the caller.
+func (h Hasher) Keccak256(maxBytes int, data []frontend.Variable, length frontend.Variable) (out [32]frontend.Variable) {
+	api := h.API
+	if len(data) != maxBytes {
+		panic(fmt.Sprintf("Keccak256: data length %d does not match max bytes %d", len(data), maxBytes))
 	}
-	selected := mux.Multiplex(api, roundIndex, 25, MAX_ROUNDS, transpose(outputStates))
-	for i := 0; i < 4; i++ {
-		out[i] = selected[i]
+	api.AssertIsLessOrEqual(length, maxBytes)
+	lanes := h.keccak256(data, func(i int) frontend.Variable {
+		return api.IsZero(api.Sub(length, i))
+	})
+	for i, lane := range lanes {
+		bits := api.ToBinary(lane, 64)
+		for b := 0; b < 8; b++ {
+			out[i*8+b] = api.FromBinary(bits[b*8 : b*8+8]...)
+		}
 	}
 	return
 }
 
-func Keccak256ForNormalTransaction(api frontend.API, blocks [NORMAL_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable, roundIndex frontend.Variable) (out [4]frontend.Variable) {
-	var allStates [NORMAL_TRANSACTION_LEAF_ROUNDS + 1][25]frontend.Variable
-	var outputStates [][]frontend.Variable
-	// initial state
-	allStates[0] = [25]frontend.Variable{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-	for i := 0; i < NORMAL_TRANSACTION_LEAF_ROUNDS; i++ {
-		newS := absorb(api, allStates[i], blocks[i])
-		allStates[i+1] = newS
-		outputStates = append(outputStates, newS[:])
+// Keccak256Nibbles is Keccak256 on data given as big endian nibbles, returning the hash as 64 nibbles. length
+// counts nibbles. data is a buffer of the padded size of its longest input, e.g. an rlp node as padded by Pad101,
+// so it holds at most len(data)/2-1 bytes. The length is not asserted, an invalid length gives the hash of other
+// data instead of failing the circuit, so that gadgets decoding the length from the data they hash can report a
+// failed check.
+func (h Hasher) Keccak256Nibbles(data []frontend.Variable, length frontend.Variable) (out [64]frontend.Variable) {
+	api := h.API
+	if len(data) < 2 || len(data)%2 != 0 {
+		panic(fmt.Sprintf("Keccak256Nibbles: invalid data length %d", len(data)))
 	}
-	selected := mux.Multiplex(api, roundIndex, 25, NORMAL_TRANSACTION_LEAF_ROUNDS, transpose(outputStates))
-	for i := 0; i < 4; i++ {
-		out[i] = selected[i]
+	bytes := make([]frontend.Variable, len(data)/2-1)
+	for i := range bytes {
+		// range check the nibbles, the bytes are packed into lanes without further checks
+		api.ToBinary(data[2*i], 4)
+		api.ToBinary(data[2*i+1], 4)
+		bytes[i] = api.Add(api.Mul(data[2*i], 16), data[2*i+1])
+	}
+	lanes := h.keccak256(bytes, func(i int) frontend.Variable {
+		return api.IsZero(api.Sub(length, 2*i))
+	})
+	for i, lane := range lanes {
+		bits := api.ToBinary(lane, 64)
+		for b := 0; b < 8; b++ {
+			out[i*16+b*2] = api.FromBinary(bits[b*8+4 : b*8+8]...)
+			out[i*16+b*2+1] = api.FromBinary(bits[b*8 : b*8+4]...)
+		}
 	}
 	return
 }
 
-func Keccak256ForMaxTransaction(api frontend.API, blocks [MAX_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable, roundIndex frontend.Variable) (out [4]frontend.Variable) {
-	var allStates [MAX_TRANSACTION_LEAF_ROUNDS + 1][25]frontend.Variable
+// keccak256 pads data in circuit and returns its hash as 4 little endian lanes. isEnd(i) is 1 for the index of the
+// first padding byte, the length of the data, and 0 for all other indexes.
+func (h Hasher) keccak256(data []frontend.Variable, isEnd func(i int) frontend.Variable) [4]frontend.Variable {
+	api := h.API
+	maxBytes := len(data)
+	maxRounds := maxBytes/136 + 1
+	paddedLen := maxRounds * 136
+
+	// ends[i] is 1 at the first padding byte, the padding ends with the last byte of the block holding it.
+	// inData[i] is 1 before it.
+	ends := make([]frontend.Variable, paddedLen)
+	inData := make([]frontend.Variable, paddedLen)
+	seenEnd := frontend.Variable(0)
+	for i := range ends {
+		ends[i] = isEnd(i)
+		seenEnd = api.Add(seenEnd, ends[i])
+		inData[i] = api.Sub(1, seenEnd)
+	}
+	roundIndex := frontend.Variable(0)
+	var blocks [][17]frontend.Variable
+	for r := 0; r < maxRounds; r++ {
+		isLastRound := frontend.Variable(0)
+		for i := r * 136; i < (r+1)*136; i++ {
+			isLastRound = api.Add(isLastRound, ends[i])
+		}
+		roundIndex = api.Add(roundIndex, api.Mul(r, isLastRound))
+
+		var block [17]frontend.Variable
+		for l := 0; l < 17; l++ {
+			// lanes are little endian
+			lane := frontend.Variable(0)
+			for b := 7; b >= 0; b-- {
+				i := r*136 + l*8 + b
+				padded := ends[i]
+				if i < maxBytes {
+					padded = api.Add(padded, api.Mul(inData[i], data[i]))
+				}
+				if i%136 == 135 {
+					padded = api.Add(padded, api.Mul(0x80, isLastRound))
+				}
+				lane = api.Add(api.Mul(lane, 256), padded)
+			}
+			block[l] = lane
+		}
+		blocks = append(blocks, block)
+	}
+	return h.keccak256Blocks(blocks, roundIndex)
+}
+
+// keccak256Blocks returns the keccak256 hash of the padded data of keccak256 as 4 little endian lanes. blocks holds
+// the padded data as 17 lanes per block and roundIndex is the index of the last block.
+func (h Hasher) keccak256Blocks(blocks [][17]frontend.Variable, roundIndex frontend.Variable) (out [4]frontend.Variable) {
+	api := h.API
+	maxRounds := len(blocks)
+	allStates := make([][25]frontend.Variable, maxRounds+1)
 	var outputStates [][]frontend.Variable
 	// initial state
 	allStates[0] = [25]frontend.Variable{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-	for i := 0; i < MAX_TRANSACTION_LEAF_ROUNDS; i++ {
-		newS := absorb(api, allStates[i], blocks[i])
+	for i := 0; i < maxRounds; i++ {
+		newS := absorb(api, h.Permuter, allStates[i], blocks[i])
 		allStates[i+1] = newS
-		outputStates = append(outputStates, newS[:])
+		outputStates = append(outputStates, allStates[i+1][:])
 	}
-	selected := mux.Multiplex(api, roundIndex, 25, MAX_TRANSACTION_LEAF_ROUNDS, transpose(outputStates))
+	selected := mux.Multiplex(api, roundIndex, 25, maxRounds, transpose(outputStates))
 	for i := 0; i < 4; i++ {
 		out[i] = selected[i]
 	}
@@ -76,16 +172,22 @@ func transpose(input [][]frontend.Variable) [][]frontend.Variable {
 	return output
 }
 
-func absorb(api frontend.API, s [25]frontend.Variable, block [17]frontend.Variable) [25]frontend.Variable {
-	// xor block with current state's r bits
-	for i := 0; i < 17; i++ {
+func absorb(api frontend.API, permuter keccakf.Permuter, s [25]frontend.Variable, block [17]frontend.Variable) [25]frontend.Variable {
+	var bits [1600]frontend.Variable
+	for i := range s {
 		rbits := api.ToBinary(s[i], 64)
-		blockBits := api.ToBinary(block[i], 64) // TODO maybe pass in blocks as bits so that we save this op
-		var xored []frontend.Variable
-		for j := 0; j < 64; j++ {
-			xored = append(xored, api.Xor(rbits[j], blockBits[j]))
+		if i < 17 {
+			// xor block with current state's r bits
+			blockBits := api.ToBinary(block[i], 64) // TODO maybe pass in blocks as bits so that we save this op
+			for j := range rbits {
+				rbits[j] = api.Xor(rbits[j], blockBits[j])
+			}
 		}
-		s[i] = api.FromBinary(xored...)
+		copy(bits[i*64:], rbits)
+	}
+	bits = permuter.Permute(bits)
+	for i := range s {
+		s[i] = api.FromBinary(bits[i*64 : (i+1)*64]...)
 	}
-	return keccakf.Permute(api, s)
+	return s
 }
@@ -9,7 +9,10 @@ import (
 	"github.com/consensys/gnark/frontend"
 )
 
-func Keccak256Bits(api frontend.API, maxRounds int, roundIndex frontend.Variable, data []frontend.Variable) (out [256]frontend.Variable) {
+// Keccak256Bits returns the keccak256 hash of data given as bits with the 101 padding applied, see Pad101Bits.
+// roundIndex is the index of the last block.
+func (h Hasher) Keccak256Bits(maxRounds int, roundIndex frontend.Variable, data []frontend.Variable) (out [256]frontend.Variable) {
+	api := h.API
 	if len(data) > maxRounds*1088 {
 		panic("len(data) > maxRounds * 1088")
 	}
@@ -21,7 +24,7 @@ func Keccak256Bits(api frontend.API, maxRounds int, roundIndex frontend.Variable
 	states = append(states, newEmptyState())
 	for i := 0; i < maxRounds; i++ {
 		r := getRoundBits(data, i)
-		s := absorbBits(api, states[i], r)
+		s := absorbBits(api, h.Permuter, states[i], r)
 		states = append(states, s)
 	}
 	// TODO skip mux if maxRound == 1
@@ -60,7 +63,7 @@ func transpose2(input [][1600]frontend.Variable) [][]frontend.Variable {
 	return ret
 }
 
-func absorbBits(api frontend.API, s [1600]frontend.Variable, block [1088]frontend.Variable) [1600]frontend.Variable {
+func absorbBits(api frontend.API, permuter keccakf.Permuter, s [1600]frontend.Variable, block [1088]frontend.Variable) [1600]frontend.Variable {
 	var r [1088]frontend.Variable
 	copy(r[:], s[:1088])
 	var xored [1600]frontend.Variable
@@ -68,5 +71,5 @@ func absorbBits(api frontend.API, s [1600]frontend.Variable, block [1088]fronten
 	for i := 0; i < 1088; i++ {
 		xored[i] = api.Xor(r[i], block[i])
 	}
-	return keccakf.Permute(api, xored)
+	return permuter.Permute(xored)
 }
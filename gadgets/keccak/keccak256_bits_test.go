@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/test"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func TestKeccakBits(t *testing.T) {
@@ -71,9 +75,66 @@ type Keccak256BitsCircuit struct {
 }
 
 func (c *Keccak256BitsCircuit) Define(api frontend.API) error {
-	out := Keccak256Bits(api, c.MaxRounds, c.RoundIndex, c.Data)
+	out := NewHasher(api).Keccak256Bits(c.MaxRounds, c.RoundIndex, c.Data)
 	for i := 0; i < 256; i++ {
 		api.AssertIsEqual(out[i], c.Out[i])
 	}
 	return nil
 }
+
+type Keccak256BitsLookupCircuit struct {
+	Data       [2 * 1088]frontend.Variable
+	RoundIndex frontend.Variable      `gnark:",public"`
+	Out        [256]frontend.Variable `gnark:",public"`
+	lookup     bool
+}
+
+func (c *Keccak256BitsLookupCircuit) Define(api frontend.API) error {
+	hasher := NewHasher(api)
+	if c.lookup {
+		hasher.Permuter = keccakf.NewLookupPermuter(api)
+	}
+	out := hasher.Keccak256Bits(2, c.RoundIndex, c.Data[:])
+	for i := 0; i < 256; i++ {
+		api.AssertIsEqual(out[i], c.Out[i])
+	}
+	return nil
+}
+
+func TestKeccakBitsLookup(t *testing.T) {
+	assert := test.NewAssert(t)
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, length := range []int{0, 32, 200} {
+		w := &Keccak256BitsLookupCircuit{RoundIndex: GetRoundIndex(length * 8)}
+		paddedBits := Bytes2BlockBits(Pad101Bytes(append([]byte{}, data[:length]...)))
+		for i := range w.Data {
+			w.Data[i] = 0
+			if i < len(paddedBits) {
+				w.Data[i] = paddedBits[i]
+			}
+		}
+		for i, b := range Bytes2Bits(crypto.Keccak256(data[:length])) {
+			w.Out[i] = b
+		}
+		err := test.IsSolved(&Keccak256BitsLookupCircuit{lookup: true}, w, ecc.BN254.ScalarField())
+		assert.NoError(err, "length %d", length)
+	}
+}
+
+// BenchmarkKeccakBitsLookup reports the PLONK constraints of 2 permutations, bitwise and with lookups
+func BenchmarkKeccakBitsLookup(b *testing.B) {
+	for _, lookup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lookup=%t", lookup), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &Keccak256BitsLookupCircuit{lookup: lookup})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(cs.GetNbConstraints()), "constraints")
+			}
+		})
+	}
+}
@@ -2,13 +2,17 @@ package keccak
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/test"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func TestKeccak(t *testing.T) {
@@ -24,7 +28,14 @@ func TestKeccak(t *testing.T) {
 		RoundIndex: 1,
 		Out:        [4]frontend.Variable{out[0], out[1], out[2], out[3]},
 	}
-	w.Blocks = Uint64s2Blocks(padded)
+	for i := range w.Blocks {
+		for j := range w.Blocks[i] {
+			w.Blocks[i][j] = 0
+			if i*17+j < len(padded) {
+				w.Blocks[i][j] = padded[i*17+j]
+			}
+		}
+	}
 	for i, el := range out {
 		w.Out[i] = el
 	}
@@ -44,7 +55,7 @@ type Keccak256Circuit struct {
 }
 
 func (c *Keccak256Circuit) Define(api frontend.API) error {
-	out := Keccak256(api, c.Blocks, c.RoundIndex)
+	out := NewHasher(api).keccak256Blocks(c.Blocks[:], c.RoundIndex)
 	for i := 0; i < 4; i++ {
 		api.AssertIsEqual(out[i], c.Out[i])
 	}
@@ -56,3 +67,116 @@ func check(err error) {
 		panic(err)
 	}
 }
+
+const keccakMaxBytes = 300
+
+type Keccak256BytesCircuit struct {
+	Data   [keccakMaxBytes]frontend.Variable
+	Length frontend.Variable
+	Out    [32]frontend.Variable `gnark:",public"`
+	lookup bool
+}
+
+func (c *Keccak256BytesCircuit) Define(api frontend.API) error {
+	hasher := NewHasher(api)
+	if c.lookup {
+		hasher.Permuter = keccakf.NewLookupPermuter(api)
+	}
+	out := hasher.Keccak256(keccakMaxBytes, c.Data[:], c.Length)
+	for i := range out {
+		api.AssertIsEqual(out[i], c.Out[i])
+	}
+	return nil
+}
+
+func TestKeccak256Bytes(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, keccakMaxBytes)
+	rng.Read(data)
+
+	newWitness := func(length int) *Keccak256BytesCircuit {
+		w := &Keccak256BytesCircuit{Length: length}
+		for i := range w.Data {
+			// the bytes past the length are ignored
+			w.Data[i] = data[i]
+		}
+		for i, b := range crypto.Keccak256(data[:length]) {
+			w.Out[i] = b
+		}
+		return w
+	}
+
+	// empty, the block boundaries and the max length
+	for _, length := range []int{0, 1, 32, 135, 136, 137, 271, 272, keccakMaxBytes} {
+		err := test.IsSolved(&Keccak256BytesCircuit{}, newWitness(length), ecc.BN254.ScalarField())
+		assert.NoError(err, "length %d", length)
+	}
+
+	// with the lookup permutation
+	for _, length := range []int{0, 137} {
+		err := test.IsSolved(&Keccak256BytesCircuit{lookup: true}, newWitness(length), ecc.BN254.ScalarField())
+		assert.NoError(err, "length %d", length)
+	}
+
+	// the hash of a different length
+	w := newWitness(100)
+	w.Length = 101
+	assert.Error(test.IsSolved(&Keccak256BytesCircuit{}, w, ecc.BN254.ScalarField()))
+
+	// longer than max bytes
+	w = newWitness(keccakMaxBytes)
+	w.Length = keccakMaxBytes + 1
+	assert.Error(test.IsSolved(&Keccak256BytesCircuit{}, w, ecc.BN254.ScalarField()))
+}
+
+type Keccak256NibblesCircuit struct {
+	Data   [272 * 2]frontend.Variable
+	Length frontend.Variable
+	Out    [64]frontend.Variable `gnark:",public"`
+}
+
+func (c *Keccak256NibblesCircuit) Define(api frontend.API) error {
+	out := Keccak256Nibbles(api, c.Data[:], c.Length)
+	for i := range out {
+		api.AssertIsEqual(out[i], c.Out[i])
+	}
+	return nil
+}
+
+func TestKeccak256Nibbles(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 272-1)
+	rng.Read(data)
+
+	newWitness := func(length int) *Keccak256NibblesCircuit {
+		w := &Keccak256NibblesCircuit{Length: 2 * length}
+		padded := Pad101Bytes(append([]byte{}, data[:length]...))
+		for i := range w.Data {
+			w.Data[i] = 0
+			if i/2 < len(padded) {
+				w.Data[i] = padded[i/2] >> (4 * (1 - i%2)) & 0xf
+			}
+		}
+		for i, b := range crypto.Keccak256(data[:length]) {
+			w.Out[2*i], w.Out[2*i+1] = b>>4, b&0xf
+		}
+		return w
+	}
+
+	for _, length := range []int{0, 31, 135, 136, 271} {
+		err := test.IsSolved(&Keccak256NibblesCircuit{}, newWitness(length), ecc.BN254.ScalarField())
+		assert.NoError(err, "length %d", length)
+	}
+
+	// the hash of a different length
+	w := newWitness(100)
+	w.Length = 2 * 101
+	assert.Error(test.IsSolved(&Keccak256NibblesCircuit{}, w, ecc.BN254.ScalarField()))
+
+	// nibbles are range checked
+	w = newWitness(100)
+	w.Data[0], w.Data[1] = 0, 16*w.Data[0].(byte)+w.Data[1].(byte)
+	assert.Error(test.IsSolved(&Keccak256NibblesCircuit{}, w, ecc.BN254.ScalarField()))
+}
@@ -15,32 +15,39 @@ import (
 	"github.com/consensys/gnark/frontend"
 )
 
-var rc = [24]xuint64{
-	constUint64(0x0000000000000001),
-	constUint64(0x0000000000008082),
-	constUint64(0x800000000000808A),
-	constUint64(0x8000000080008000),
-	constUint64(0x000000000000808B),
-	constUint64(0x0000000080000001),
-	constUint64(0x8000000080008081),
-	constUint64(0x8000000000008009),
-	constUint64(0x000000000000008A),
-	constUint64(0x0000000000000088),
-	constUint64(0x0000000080008009),
-	constUint64(0x000000008000000A),
-	constUint64(0x000000008000808B),
-	constUint64(0x800000000000008B),
-	constUint64(0x8000000000008089),
-	constUint64(0x8000000000008003),
-	constUint64(0x8000000000008002),
-	constUint64(0x8000000000000080),
-	constUint64(0x000000000000800A),
-	constUint64(0x800000008000000A),
-	constUint64(0x8000000080008081),
-	constUint64(0x8000000000008080),
-	constUint64(0x0000000080000001),
-	constUint64(0x8000000080008008),
+var rcValues = [24]uint64{
+	0x0000000000000001,
+	0x0000000000008082,
+	0x800000000000808A,
+	0x8000000080008000,
+	0x000000000000808B,
+	0x0000000080000001,
+	0x8000000080008081,
+	0x8000000000008009,
+	0x000000000000008A,
+	0x0000000000000088,
+	0x0000000080008009,
+	0x000000008000000A,
+	0x000000008000808B,
+	0x800000000000008B,
+	0x8000000000008089,
+	0x8000000000008003,
+	0x8000000000008002,
+	0x8000000000000080,
+	0x000000000000800A,
+	0x800000008000000A,
+	0x8000000080008081,
+	0x8000000000008080,
+	0x0000000080000001,
+	0x8000000080008008,
 }
+
+var rc = func() (rc [24]xuint64) {
+	for i, v := range rcValues {
+		rc[i] = constUint64(v)
+	}
+	return
+}()
 var rotc = [24]int{
 	1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14,
 	27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44,
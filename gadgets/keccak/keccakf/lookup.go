@@ -0,0 +1,292 @@
+package keccakf
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/lookup/logderivlookup"
+)
+
+// Permuter applies the keccak-f permutation on a state of 1600 bits, see Permute
+type Permuter interface {
+	Permute(a [1600]frontend.Variable) [1600]frontend.Variable
+}
+
+type bitPermuter struct {
+	api frontend.API
+}
+
+// NewPermuter returns the Permuter computing the permutation bit by bit, see Permute
+func NewPermuter(api frontend.API) Permuter {
+	return &bitPermuter{api: api}
+}
+
+func (p *bitPermuter) Permute(a [1600]frontend.Variable) [1600]frontend.Variable {
+	return Permute(p.api, a)
+}
+
+func init() {
+	solver.RegisterHint(splitHint, digitsHint)
+}
+
+// sparseBase is the base of the sparse lanes of the lookup permutation. A lane holds its bits as the digits
+// sum(b_i * 7^i), so xors are computed as additions of up to 6 bits per digit and normalized by lookup.
+const sparseBase = 7
+
+// chunkDigits is the number of digits looked up at once, the tables have 7^4 = 2401 entries
+const chunkDigits = 4
+
+var pow7 = func() (pow [65]*big.Int) {
+	for i := range pow {
+		pow[i] = new(big.Int).Exp(big.NewInt(sparseBase), big.NewInt(int64(i)), nil)
+	}
+	return
+}()
+
+// sparse returns the constant lane v in the sparse form
+func sparse(v uint64) *big.Int {
+	res := new(big.Int)
+	for i := 0; i < 64; i++ {
+		if v>>i&1 == 1 {
+			res.Add(res, pow7[i])
+		}
+	}
+	return res
+}
+
+var rcSparse = func() (rc [24]*big.Int) {
+	for i, v := range rcValues {
+		rc[i] = sparse(v)
+	}
+	return
+}()
+
+// chiOnes is 3 in every digit, the chi input 3 - 2a + b - c of the digits a, b, c is in [0, 4]
+var chiOnes = new(big.Int).Mul(sparse(^uint64(0)), big.NewInt(3))
+
+// digit functions of the tables, normalizing xors and computing a^(^b&c) from 3 - 2a + b - c
+var (
+	parityDigits = [sparseBase]int{0, 1, 0, 1, 0, 1, 0}
+	chiDigits    = [sparseBase]int{0, 1, 1, 0, 0, 0, 0}
+)
+
+// chunk is a range of digits of a lane
+type chunk struct {
+	start, width int
+}
+
+// chunks splits the digits of a lane into chunks of at most chunkDigits digits, none of them crossing the digit
+// 64-rot, so that the lane rotated left by rot is a combination of the chunks
+func chunks(rot int) []chunk {
+	var cs []chunk
+	for _, seg := range [][2]int{{0, 64 - rot}, {64 - rot, 64}} {
+		for s := seg[0]; s < seg[1]; s += chunkDigits {
+			w := chunkDigits
+			if seg[1]-s < w {
+				w = seg[1] - s
+			}
+			cs = append(cs, chunk{start: s, width: w})
+		}
+	}
+	return cs
+}
+
+// LookupPermuter computes the permutation on sparse lanes of base 7 digits. The xors of theta are additions of
+// lanes and the rotations of rho and theta are free recombinations of the chunks the lanes are normalized in. Only
+// the normalizations and chi are looked up, in log-derivative lookup tables of 4 digits indexed by the chunks. The
+// tables are shared by all permutations of a circuit, so one LookupPermuter should be created per circuit. A
+// permutation costs about 223k PLONK constraints against 292k bitwise, and 68k R1CS constraints against 194k, plus
+// the fixed cost of the tables, about 36k constraints, see BenchmarkKeccakBitsLookup.
+type LookupPermuter struct {
+	api frontend.API
+	// tables of a digit function by chunk width, created on first use since the lookup argument fails on a table
+	// without queries
+	parity, chi [chunkDigits + 1]*logderivlookup.Table
+}
+
+// NewLookupPermuter returns a Permuter using lookup tables
+func NewLookupPermuter(api frontend.API) *LookupPermuter {
+	return &LookupPermuter{api: api}
+}
+
+// table returns the table of the digit function f on chunks of width digits, the entry at index i being f applied to
+// each digit of i plus 1. The PLONK builder fails to compile a table whose first entry is the constant 0, the
+// offset is removed by combine at no cost.
+func (p *LookupPermuter) table(tables *[chunkDigits + 1]*logderivlookup.Table, f [sparseBase]int, width int) *logderivlookup.Table {
+	if tables[width] != nil {
+		return tables[width]
+	}
+	t := logderivlookup.New(p.api)
+	for i := 0; i < int(pow7[width].Int64()); i++ {
+		v := 0
+		for d, m := 0, 1; d < width; d, m = d+1, m*sparseBase {
+			v += f[i/m%sparseBase] * m
+		}
+		t.Insert(v + 1)
+	}
+	tables[width] = t
+	return t
+}
+
+// normalize applies the digit function f to the digits of the sparse lanes ins, ins[i] being split by chunks(rots[i]),
+// and returns the chunks of the results. The chunks are range checked by the lookups in the tables of their widths,
+// so a lane has a single decomposition. The lookups of all lanes are done in one call per table, which shares the
+// lookup hint among them.
+func (p *LookupPermuter) normalize(tables *[chunkDigits + 1]*logderivlookup.Table, f [sparseBase]int, ins []frontend.Variable, rots []int) [][]frontend.Variable {
+	api := p.api
+	var byWidth [chunkDigits + 1][]frontend.Variable
+	for i, in := range ins {
+		cs := chunks(rots[i])
+		hintIn := []frontend.Variable{in}
+		for _, c := range cs {
+			hintIn = append(hintIn, c.start, c.width)
+		}
+		l, err := api.Compiler().NewHint(splitHint, len(cs), hintIn...)
+		if err != nil {
+			panic(err)
+		}
+		api.AssertIsEqual(combine(api, l, rots[i], 0, false), in)
+		for k, c := range cs {
+			byWidth[c.width] = append(byWidth[c.width], l[k])
+		}
+	}
+	var outs [chunkDigits + 1][]frontend.Variable
+	for w := range byWidth {
+		if len(byWidth[w]) > 0 {
+			outs[w] = p.table(tables, f, w).Lookup(byWidth[w]...)
+		}
+	}
+	res := make([][]frontend.Variable, len(ins))
+	for i := range ins {
+		for _, c := range chunks(rots[i]) {
+			res[i] = append(res[i], outs[c.width][0])
+			outs[c.width] = outs[c.width][1:]
+		}
+	}
+	return res
+}
+
+// combine returns the lane of the chunks vs split by chunks(rot), rotated left by shift, shift being 0 or rot.
+// looked removes the offset of the table entries from chunks returned by normalize.
+func combine(api frontend.API, vs []frontend.Variable, rot, shift int, looked bool) frontend.Variable {
+	terms := make([]frontend.Variable, len(vs))
+	offset := new(big.Int)
+	for k, c := range chunks(rot) {
+		terms[k] = api.Mul(vs[k], pow7[(c.start+shift)%64])
+		offset.Add(offset, pow7[(c.start+shift)%64])
+	}
+	if looked {
+		terms = append(terms, offset.Neg(offset))
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return api.Add(terms[0], terms[1], terms[2:]...)
+}
+
+// Permute applies the keccakf permutation on the input state of 1600 bits, the range check [0, 1] for each
+// element in 'a' must be done outside already
+func (p *LookupPermuter) Permute(a [1600]frontend.Variable) [1600]frontend.Variable {
+	api := p.api
+	var st [25]frontend.Variable
+	for i := range st {
+		terms := make([]frontend.Variable, 64)
+		for j := range terms {
+			terms[j] = api.Mul(a[i*64+j], pow7[j])
+		}
+		st[i] = api.Add(terms[0], terms[1], terms[2:]...)
+	}
+
+	st = p.permute(st)
+
+	var out [1600]frontend.Variable
+	for i := range st {
+		bits, err := api.Compiler().NewHint(digitsHint, 64, st[i])
+		if err != nil {
+			panic(err)
+		}
+		terms := make([]frontend.Variable, 64)
+		for j, b := range bits {
+			api.AssertIsBoolean(b)
+			terms[j] = api.Mul(b, pow7[j])
+		}
+		api.AssertIsEqual(api.Add(terms[0], terms[1], terms[2:]...), st[i])
+		copy(out[i*64:], bits)
+	}
+	return out
+}
+
+// permute applies the permutation on sparse lanes of bits. The lanes are normalized before each lookup so that no
+// digit exceeds 6: a theta column sums 5 lanes, lane 0 holding up to 2 after iota, and a lane after theta adds the
+// theta effect of up to 2 to a lane of up to 2.
+func (p *LookupPermuter) permute(st [25]frontend.Variable) [25]frontend.Variable {
+	api := p.api
+	// rho pi moves lane src[i] rotated left by rot[i] to lane dst[i], lane 0 is only normalized
+	var src, dst, rot [25]int
+	src[1] = 1
+	for i := 0; i < 24; i++ {
+		dst[i+1], rot[i+1] = piln[i], rotc[i]
+		if i < 23 {
+			src[i+2] = piln[i]
+		}
+	}
+	var c [5]frontend.Variable
+	for r := 0; r < 24; r++ {
+		// theta, the column parities are normalized once and recombined plain and rotated by 1
+		for i := 0; i < 5; i++ {
+			c[i] = api.Add(st[i], st[i+5], st[i+10], st[i+15], st[i+20])
+		}
+		cs := p.normalize(&p.parity, parityDigits, c[:], []int{1, 1, 1, 1, 1})
+		for i := 0; i < 5; i++ {
+			t := api.Add(combine(api, cs[(i+4)%5], 1, 0, true), combine(api, cs[(i+1)%5], 1, 1, true))
+			for j := 0; j < 25; j += 5 {
+				st[j+i] = api.Add(st[j+i], t)
+			}
+		}
+		// rho pi
+		var ins [25]frontend.Variable
+		for i := range ins {
+			ins[i] = st[src[i]]
+		}
+		bs := p.normalize(&p.parity, parityDigits, ins[:], rot[:])
+		for i := range bs {
+			st[dst[i]] = combine(api, bs[i], rot[i], rot[i], true)
+		}
+		// chi
+		for j := 0; j < 25; j += 5 {
+			for i := 0; i < 5; i++ {
+				ins[j+i] = api.Add(chiOnes, api.Mul(st[j+i], -2), st[j+(i+1)%5], api.Neg(st[j+(i+2)%5]))
+			}
+		}
+		chis := p.normalize(&p.chi, chiDigits, ins[:], make([]int, 25))
+		for i := range chis {
+			st[i] = combine(api, chis[i], 0, 0, true)
+		}
+		// iota, lane 0 is normalized by the next theta
+		st[0] = api.Add(st[0], rcSparse[r])
+	}
+	l0 := p.normalize(&p.parity, parityDigits, st[:1], []int{0})
+	st[0] = combine(api, l0[0], 0, 0, true)
+	return st
+}
+
+// splitHint returns the chunks of the sparse lane inputs[0], the chunk i starting at the digit inputs[2i+1] and
+// holding inputs[2i+2] digits
+func splitHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	for i := range outputs {
+		start, width := inputs[2*i+1].Int64(), inputs[2*i+2].Int64()
+		outputs[i].Div(inputs[0], pow7[start])
+		outputs[i].Mod(outputs[i], pow7[width])
+	}
+	return nil
+}
+
+// digitsHint returns the 64 digits of the sparse lane inputs[0]
+func digitsHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	v := new(big.Int).Set(inputs[0])
+	for i := range outputs {
+		v.QuoRem(v, big.NewInt(sparseBase), outputs[i])
+	}
+	return nil
+}
@@ -1,20 +1,5 @@
 package keccak
 
-import (
-	"github.com/consensys/gnark/frontend"
-)
-
-func Uint64s2Blocks(padded []uint64) [MAX_ROUNDS][17]frontend.Variable {
-	ret := [MAX_ROUNDS][17]frontend.Variable{}
-	for i, el := range padded {
-		ret[i/17][i%17] = el
-	}
-	for i := len(padded); i < MAX_ROUNDS*17; i++ {
-		ret[i/17][i%17] = 0
-	}
-	return ret
-}
-
 func Pad101(data []byte) []uint64 {
 	return Bytes2Uint64s(Pad101Bytes(data))
 }
@@ -79,121 +64,6 @@ func Bytes2Uint64s(bytes []byte) []uint64 {
 	return ret
 }
 
-// NibblesToU64Array get Keccak256 blocks, each round with a size-17 uint64
-func NibblesToU64Array(api frontend.API, nibbles []frontend.Variable) [MAX_ROUNDS][17]frontend.Variable {
-	const (
-		nibblesPerU64 = 16
-		u64sPerRound  = 17
-	)
-	var ret [MAX_ROUNDS][u64sPerRound]frontend.Variable
-
-	var u64s []frontend.Variable
-	start := len(nibbles) * 4 / 64
-	for i := 0; i < start; i++ {
-		var u64bits []frontend.Variable
-
-		// Every 16 nibbles forms an uint64
-		for j := 0; j < 8; j++ {
-			var perBytes []frontend.Variable
-			perBytePart1 := api.ToBinary(nibbles[i*nibblesPerU64+j*2], 4)
-			perBytePart2 := api.ToBinary(nibbles[i*nibblesPerU64+j*2+1], 4)
-
-			perBytes = append(perBytes, perBytePart2...)
-			perBytes = append(perBytes, perBytePart1...)
-
-			u64bits = append(u64bits, perBytes...)
-		}
-
-		u64s = append(u64s, api.FromBinary(u64bits...))
-	}
-
-	for i, el := range u64s {
-		ret[i/17][i%17] = el
-	}
-
-	for i := len(u64s); i < MAX_ROUNDS*17; i++ {
-		ret[i/17][i%17] = 0
-	}
-
-	return ret
-}
-
-func NibblesToU64ArrayForNormalTransactionLeafValue(api frontend.API, nibbles []frontend.Variable) [NORMAL_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable {
-	const (
-		nibblesPerU64 = 16
-		u64sPerRound  = 17
-	)
-	var ret [NORMAL_TRANSACTION_LEAF_ROUNDS][u64sPerRound]frontend.Variable
-
-	var u64s []frontend.Variable
-	start := len(nibbles) * 4 / 64
-	for i := 0; i < start; i++ {
-		var u64bits []frontend.Variable
-
-		// Every 16 nibbles forms an uint64
-		for j := 0; j < 8; j++ {
-			var perBytes []frontend.Variable
-			perBytePart1 := api.ToBinary(nibbles[i*nibblesPerU64+j*2], 4)
-			perBytePart2 := api.ToBinary(nibbles[i*nibblesPerU64+j*2+1], 4)
-
-			perBytes = append(perBytes, perBytePart2...)
-			perBytes = append(perBytes, perBytePart1...)
-
-			u64bits = append(u64bits, perBytes...)
-		}
-
-		u64s = append(u64s, api.FromBinary(u64bits...))
-	}
-
-	for i, el := range u64s {
-		ret[i/17][i%17] = el
-	}
-
-	for i := len(u64s); i < NORMAL_TRANSACTION_LEAF_ROUNDS*17; i++ {
-		ret[i/17][i%17] = 0
-	}
-
-	return ret
-}
-
-func NibblesToU64ArrayForMaxTransactionLeafValue(api frontend.API, nibbles []frontend.Variable) [MAX_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable {
-	const (
-		nibblesPerU64 = 16
-		u64sPerRound  = 17
-	)
-	var ret [MAX_TRANSACTION_LEAF_ROUNDS][u64sPerRound]frontend.Variable
-
-	var u64s []frontend.Variable
-	start := len(nibbles) * 4 / 64
-	for i := 0; i < start; i++ {
-		var u64bits []frontend.Variable
-
-		// Every 16 nibbles forms an uint64
-		for j := 0; j < 8; j++ {
-			var perBytes []frontend.Variable
-			perBytePart1 := api.ToBinary(nibbles[i*nibblesPerU64+j*2], 4)
-			perBytePart2 := api.ToBinary(nibbles[i*nibblesPerU64+j*2+1], 4)
-
-			perBytes = append(perBytes, perBytePart2...)
-			perBytes = append(perBytes, perBytePart1...)
-
-			u64bits = append(u64bits, perBytes...)
-		}
-
-		u64s = append(u64s, api.FromBinary(u64bits...))
-	}
-
-	for i, el := range u64s {
-		ret[i/17][i%17] = el
-	}
-
-	for i := len(u64s); i < MAX_TRANSACTION_LEAF_ROUNDS*17; i++ {
-		ret[i/17][i%17] = 0
-	}
-
-	return ret
-}
-
 func GetKeccakRoundIndex(dataLenInHex int) int {
 	var chunkSizeInHex = 272
 	chunkNum := (dataLenInHex + chunkSizeInHex - 1) / chunkSizeInHex
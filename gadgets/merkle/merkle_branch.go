@@ -0,0 +1,40 @@
+package merkle
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// BranchRoot computes the root of the merkle tree of Root from the leaf of index leafIndex and its siblings from the
+// leaf up, the tree has 2^len(siblings) leaves. With the siblings of native.PaddedKeccakMerkleProof, it is also the
+// root of RootVarLen.
+func (k Keccak) BranchRoot(leaf [256]frontend.Variable, leafIndex frontend.Variable, siblings [][256]frontend.Variable) [256]frontend.Variable {
+	api := k.API
+	if len(siblings) == 0 {
+		api.AssertIsEqual(leafIndex, 0)
+		return leaf
+	}
+	indexBits := api.ToBinary(leafIndex, len(siblings))
+	node := leaf
+	for h, sibling := range siblings {
+		node = k.hashBranchNode(node, sibling, indexBits[h])
+	}
+	return node
+}
+
+// AssertBranch asserts that leaf is the leaf of index leafIndex in the merkle tree of root, see BranchRoot
+func (k Keccak) AssertBranch(root [256]frontend.Variable, leaf [256]frontend.Variable, leafIndex frontend.Variable, siblings [][256]frontend.Variable) {
+	branchRoot := k.BranchRoot(leaf, leafIndex, siblings)
+	for i := range root {
+		k.API.AssertIsEqual(branchRoot[i], root[i])
+	}
+}
+
+// hashBranchNode returns the parent of node and its sibling, node is the right child if isRight is 1
+func (k Keccak) hashBranchNode(node hash, sibling hash, isRight frontend.Variable) hash {
+	var left, right hash
+	for j := range node {
+		left[j] = k.API.Select(isRight, sibling[j], node[j])
+		right[j] = k.API.Select(isRight, node[j], sibling[j])
+	}
+	return k.hashPairs([]hash{left, right})[0]
+}
@@ -0,0 +1,76 @@
+package merkle
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+func TestKeccakMerkleBranch(t *testing.T) {
+	const leafCount = 5
+	// the 5 leaves are padded to 8
+	circuit := &KeccakMerkleBranchCircuit{Siblings: make([]hash, 3)}
+	randtest.Check(t, 3, circuit, func(rng *rand.Rand) frontend.Circuit {
+		var leaves [][]byte
+		for i := 0; i < leafCount; i++ {
+			leaves = append(leaves, randtest.Bytes(rng, 32))
+		}
+		root, err := native.PaddedKeccakMerkleRoot(leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index := rng.Intn(leafCount)
+		siblings, err := native.PaddedKeccakMerkleProof(leaves, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &KeccakMerkleBranchCircuit{Root: bytes2Hash(root), Leaf: bytes2Hash(leaves[index]), LeafIndex: index, Siblings: encode(siblings)}
+	})
+
+	assert := test.NewAssert(t)
+	var leaves [][]byte
+	for i := 0; i < leafCount; i++ {
+		leaves = append(leaves, randtest.Bytes(rand.New(rand.NewSource(int64(i))), 32))
+	}
+	root, err := native.PaddedKeccakMerkleRoot(leaves)
+	assert.NoError(err)
+	for index := 0; index < leafCount; index++ {
+		siblings, err := native.PaddedKeccakMerkleProof(leaves, index)
+		assert.NoError(err)
+		w := &KeccakMerkleBranchCircuit{Root: bytes2Hash(root), Leaf: bytes2Hash(leaves[index]), LeafIndex: index, Siblings: encode(siblings)}
+		assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf %d", index)
+
+		// the leaf at another index
+		w.LeafIndex = (index + 1) % leafCount
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf %d", index)
+		// an index past the tree
+		w.LeafIndex = index + 8
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf %d", index)
+	}
+	_, err = native.PaddedKeccakMerkleProof(leaves, leafCount)
+	assert.Error(err)
+
+	// a single leaf is the root
+	single := &KeccakMerkleBranchCircuit{Root: bytes2Hash(leaves[0]), Leaf: bytes2Hash(leaves[0]), LeafIndex: 0}
+	assert.NoError(test.IsSolved(&KeccakMerkleBranchCircuit{}, single, ecc.BN254.ScalarField()))
+	single.LeafIndex = 1
+	assert.Error(test.IsSolved(&KeccakMerkleBranchCircuit{}, single, ecc.BN254.ScalarField()))
+}
+
+type KeccakMerkleBranchCircuit struct {
+	Root      hash `gnark:",public"`
+	Leaf      hash
+	LeafIndex frontend.Variable
+	Siblings  []hash
+}
+
+func (c *KeccakMerkleBranchCircuit) Define(api frontend.API) error {
+	NewKeccak(api).AssertBranch(c.Root, c.Leaf, c.LeafIndex, c.Siblings)
+	return nil
+}
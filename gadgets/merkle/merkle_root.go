@@ -2,39 +2,107 @@ package merkle
 
 import (
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/keccak/keccakf"
 
 	"github.com/consensys/gnark/frontend"
 )
 
 type hash = [256]frontend.Variable
 
-// MerkleRoot computes the root hash of the merkle trie of "leaves"
+// Keccak computes keccak merkle trees, the tree of 2 nodes is the keccak256 hash of their 64 bytes, computing the
+// keccak-f permutations with Permuter
+type Keccak struct {
+	API      frontend.API
+	Permuter keccakf.Permuter
+}
+
+// NewKeccak returns the Keccak computing the permutations bitwise
+func NewKeccak(api frontend.API) Keccak {
+	return Keccak{API: api, Permuter: keccakf.NewPermuter(api)}
+}
+
+// Root computes the root hash of the merkle trie of "leaves"
 // leaves must have a length that is power of two
 // leaves should already be hashed (keccak) outside this portion of the circuit
-func KeccakMerkleRoot(api frontend.API, leaves [][256]frontend.Variable) [256]frontend.Variable {
+func (k Keccak) Root(leaves [][256]frontend.Variable) [256]frontend.Variable {
 	leafCount := len(leaves)
 	if !isPowerOfTwo(leafCount) {
 		panic("leaf count is not power of two")
 	}
-	return keccakMerkleRoot(api, leaves)
+	return k.root(leaves)
 }
 
-func keccakMerkleRoot(api frontend.API, leaves []hash) hash {
-	hashes := []hash{}
+// RootVarLen computes the root hash of the merkle trie of the first leafCount leaves, 1 <= leafCount <=
+// len(leaves). The leaves after leafCount are replaced by zero leaves up to the next power of two of leafCount, so
+// that the root only depends on the leaves and leafCount, not on len(leaves), and is the root of Root when leafCount
+// is a power of two. See native.PaddedKeccakMerkleRoot.
+func (k Keccak) RootVarLen(leaves [][256]frontend.Variable, leafCount frontend.Variable) [256]frontend.Variable {
+	api := k.API
+	if len(leaves) == 0 {
+		panic("no leaves")
+	}
+	size := 1
+	for size < len(leaves) {
+		size *= 2
+	}
+
+	// isLeaf[i] is 1 for i < leafCount
+	isLeaf := make([]frontend.Variable, size+1)
+	var inLeaves frontend.Variable = 1
+	for i := range isLeaf {
+		inLeaves = api.Sub(inLeaves, api.IsZero(api.Sub(leafCount, i)))
+		isLeaf[i] = inLeaves
+	}
+	api.AssertIsEqual(isLeaf[0], 1)
+	api.AssertIsEqual(isLeaf[len(leaves)], 0)
+
+	level := make([]hash, size)
+	for i := range level {
+		for j := range level[i] {
+			level[i][j] = 0
+			if i < len(leaves) {
+				level[i][j] = api.Mul(leaves[i][j], isLeaf[i])
+			}
+		}
+	}
+
+	// the root of the padded leaves is the first node of the level of width the next power of two of leafCount
+	var root hash
+	isRoot := api.Sub(1, isLeaf[1])
+	for j := range root {
+		root[j] = api.Mul(isRoot, level[0][j])
+	}
+	for width := 2; width <= size; width *= 2 {
+		level = k.hashPairs(level)
+		isRoot = api.Sub(isLeaf[width/2], isLeaf[width])
+		for j := range root {
+			root[j] = api.Add(root[j], api.Mul(isRoot, level[0][j]))
+		}
+	}
+	return root
+}
+
+func (k Keccak) root(leaves []hash) hash {
 	if len(leaves) == 1 {
 		return leaves[0]
 	}
-	for i := 0; i < len(leaves); i += 2 {
+	return k.root(k.hashPairs(leaves))
+}
+
+// hashPairs returns the parent nodes of a level of the trie
+func (k Keccak) hashPairs(nodes []hash) []hash {
+	hashes := []hash{}
+	for i := 0; i < len(nodes); i += 2 {
 		data := []frontend.Variable{}
-		data = append(data, leaves[i][:]...)
-		data = append(data, leaves[i+1][:]...)
+		data = append(data, nodes[i][:]...)
+		data = append(data, nodes[i+1][:]...)
 		// since the input to the keccak part is always 64 bytes, we can hardwire the padding of 576
 		// bits to make it a full round of 1088 bits
 		data = pad(data)
-		h := keccak.Keccak256Bits(api, 1, 0, data)
+		h := keccak.Hasher{API: k.API, Permuter: k.Permuter}.Keccak256Bits(1, 0, data)
 		hashes = append(hashes, h)
 	}
-	return keccakMerkleRoot(api, hashes)
+	return hashes
 }
 
 func pad(data []frontend.Variable) []frontend.Variable {
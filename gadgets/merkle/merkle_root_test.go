@@ -2,8 +2,12 @@ package merkle
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
@@ -33,14 +37,91 @@ func TestKeccakMerkleRoot(t *testing.T) {
 	fmt.Println("constraints", cs.GetNbConstraints())
 }
 
+func TestKeccakMerkleRootRandom(t *testing.T) {
+	const leafCount = 4
+	circuit := &KeccakMerkleRootCircuit{Leaves: make([]hash, leafCount)}
+	randtest.Check(t, 3, circuit, func(rng *rand.Rand) frontend.Circuit {
+		var leaves [][]byte
+		for i := 0; i < leafCount; i++ {
+			leaves = append(leaves, randtest.Bytes(rng, 32))
+		}
+		root, err := native.KeccakMerkleRoot(leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &KeccakMerkleRootCircuit{Root: bytes2Hash(root), Leaves: encode(leaves)}
+	})
+}
+
+func TestKeccakMerkleRootVarLen(t *testing.T) {
+	const maxLeaves = 5
+	circuit := &KeccakMerkleRootVarLenCircuit{Leaves: make([]hash, maxLeaves)}
+	randtest.Check(t, 3, circuit, func(rng *rand.Rand) frontend.Circuit {
+		leafCount := 1 + rng.Intn(maxLeaves)
+		var leaves [][]byte
+		for i := 0; i < maxLeaves; i++ {
+			leaves = append(leaves, randtest.Bytes(rng, 32))
+		}
+		root, err := native.PaddedKeccakMerkleRoot(leaves[:leafCount])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the leaves after leafCount are ignored
+		return &KeccakMerkleRootVarLenCircuit{Root: bytes2Hash(root), Leaves: encode(leaves), LeafCount: leafCount}
+	})
+
+	assert := test.NewAssert(t)
+	var leaves [][]byte
+	for i := 0; i < maxLeaves; i++ {
+		leaves = append(leaves, []byte(fmt.Sprintf("leaf %d padded to 32 bytes.......", i)))
+	}
+	for leafCount := 1; leafCount <= maxLeaves; leafCount++ {
+		root, err := native.PaddedKeccakMerkleRoot(leaves[:leafCount])
+		assert.NoError(err)
+		if isPowerOfTwo(leafCount) {
+			unpadded, err := native.KeccakMerkleRoot(leaves[:leafCount])
+			assert.NoError(err)
+			assert.Equal(unpadded, root)
+		}
+		w := &KeccakMerkleRootVarLenCircuit{Root: bytes2Hash(root), Leaves: encode(leaves), LeafCount: leafCount}
+		assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d", leafCount)
+
+		// the root of a different number of leaves does not match
+		w.LeafCount = leafCount%maxLeaves + 1
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d", leafCount)
+	}
+
+	// no leaves or more than len(leaves)
+	root, err := native.PaddedKeccakMerkleRoot(leaves)
+	assert.NoError(err)
+	for _, leafCount := range []int{0, maxLeaves + 1} {
+		w := &KeccakMerkleRootVarLenCircuit{Root: bytes2Hash(root), Leaves: encode(leaves), LeafCount: leafCount}
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d", leafCount)
+	}
+}
+
+type KeccakMerkleRootVarLenCircuit struct {
+	Root      hash `gnark:",public"`
+	Leaves    []hash
+	LeafCount frontend.Variable
+}
+
+func (c *KeccakMerkleRootVarLenCircuit) Define(api frontend.API) error {
+	root := NewKeccak(api).RootVarLen(c.Leaves, c.LeafCount)
+	for i := range root {
+		api.AssertIsEqual(root[i], c.Root[i])
+	}
+	return nil
+}
+
 type KeccakMerkleRootCircuit struct {
 	Root   hash `gnark:",public"`
 	Leaves []hash
 }
 
 func (c *KeccakMerkleRootCircuit) Define(api frontend.API) error {
-	root := KeccakMerkleRoot(api, c.Leaves)
-	for i := 0; i < 32; i++ {
+	root := NewKeccak(api).Root(c.Leaves)
+	for i := range root {
 		api.AssertIsEqual(root[i], c.Root[i])
 	}
 	return nil
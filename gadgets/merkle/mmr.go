@@ -0,0 +1,113 @@
+package merkle
+
+import (
+	"math/bits"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// MMRAppend appends the first count leaves to the merkle mountain range of leafCount leaves and returns the new
+// peaks and leaf count. count <= len(leaves) is a variable, so a circuit may append any number of leaves up to
+// len(leaves) at any leaf count, as native.MMR.Append does. peaks[h] is the merkle root of a perfect tree of 2^h
+// leaves if bit h of leafCount is set and zero otherwise, so the mountain range holds up to 2^len(peaks)-1 leaves.
+// See native.MMR.
+// The appended nodes of each height are merged in pairs after the peak of that height, an odd node left being the
+// new peak, which takes about len(leaves) + len(peaks) hashes.
+func (k Keccak) MMRAppend(peaks [][256]frontend.Variable, leafCount frontend.Variable, leaves [][256]frontend.Variable, count frontend.Variable) ([][256]frontend.Variable, frontend.Variable) {
+	api := k.API
+	if len(leaves) == 0 {
+		panic("no leaves")
+	}
+	height := len(peaks)
+	countBits := api.ToBinary(leafCount, height)
+	assertPeaks(api, peaks, countBits)
+	api.AssertIsLessOrEqual(count, len(leaves))
+
+	newPeaks := make([][256]frontend.Variable, height)
+	// the roots of the appended trees of 2^h leaves, the first n of them being set
+	nodes, n := leaves, count
+	for h := 0; h < height; h++ {
+		// the peak if it is set followed by the nodes, the first l of them being set
+		seq := make([]hash, len(nodes)+1)
+		for i := range seq {
+			for j := range seq[i] {
+				var shifted, node frontend.Variable = peaks[h][j], 0
+				if i > 0 {
+					shifted = nodes[i-1][j]
+				}
+				if i < len(nodes) {
+					node = nodes[i][j]
+				}
+				seq[i][j] = api.Select(countBits[h], shifted, node)
+			}
+		}
+		l := api.Add(countBits[h], n)
+		lBits := api.ToBinary(l, bits.Len(uint(len(seq))))
+		isPeak := make([]frontend.Variable, len(seq))
+		for i := range seq {
+			isPeak[i] = api.Mul(lBits[0], api.IsZero(api.Sub(l, i+1)))
+		}
+		for j := range newPeaks[h] {
+			var peak frontend.Variable = 0
+			for i := range seq {
+				peak = api.Add(peak, api.Mul(isPeak[i], seq[i][j]))
+			}
+			newPeaks[h][j] = peak
+		}
+		n = api.Div(api.Sub(l, lBits[0]), 2)
+		if h == height-1 {
+			// more than 2^height-1 leaves
+			api.AssertIsEqual(n, 0)
+			break
+		}
+		nodes = k.hashPairs(seq[:len(seq)/2*2])
+	}
+	return newPeaks, api.Add(leafCount, count)
+}
+
+// MMRVerifyProof asserts that leaf is the leaf of index leafIndex in the merkle mountain range of leafCount leaves
+// with the peaks of MMRAppend. siblings are the len(peaks)-1 siblings of the branch from the leaf up to its peak, the
+// ones above the peak are ignored.
+func (k Keccak) MMRVerifyProof(peaks [][256]frontend.Variable, leafCount frontend.Variable, leafIndex frontend.Variable, leaf [256]frontend.Variable, siblings [][256]frontend.Variable) {
+	api := k.API
+	height := len(peaks)
+	if len(siblings) != height-1 {
+		panic("sibling count is not the height of the mountain range minus one")
+	}
+	countBits := api.ToBinary(leafCount, height)
+	indexBits := api.ToBinary(leafIndex, height)
+
+	// the leaf is in the tree of the peak at the highest bit where leafCount and leafIndex differ, leafIndex <
+	// leafCount if it is set in leafCount
+	isPeak := make([]frontend.Variable, height)
+	var differAbove frontend.Variable = 0
+	for h := height - 1; h >= 0; h-- {
+		differ := api.Xor(countBits[h], indexBits[h])
+		isPeak[h] = api.Mul(differ, api.Sub(1, differAbove))
+		api.AssertIsEqual(api.Mul(isPeak[h], indexBits[h]), 0)
+		differAbove = api.Or(differAbove, differ)
+	}
+	api.AssertIsEqual(differAbove, 1)
+
+	// the nodes of the branch up to the top, the one at the height of the peak must be the peak
+	node := leaf
+	for h := 0; h < height; h++ {
+		for j := range node {
+			api.AssertIsEqual(api.Mul(isPeak[h], api.Sub(node[j], peaks[h][j])), 0)
+		}
+		if h == height-1 {
+			break
+		}
+		node = k.hashBranchNode(node, siblings[h], indexBits[h])
+	}
+}
+
+// assertPeaks asserts that the peaks of the heights that are not set in the leaf count are zero, so that the peaks
+// and leaf count commit to a single mountain range
+func assertPeaks(api frontend.API, peaks [][256]frontend.Variable, countBits []frontend.Variable) {
+	for h := range peaks {
+		for j := range peaks[h] {
+			api.AssertIsEqual(api.Mul(api.Sub(1, countBits[h]), peaks[h][j]), 0)
+		}
+	}
+}
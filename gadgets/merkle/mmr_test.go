@@ -0,0 +1,162 @@
+package merkle
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/merkle/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+const mmrHeight = 4
+
+func TestMMRAppend(t *testing.T) {
+	const maxLeaves = 3
+	circuit := &MMRAppendCircuit{Peaks: make([]hash, mmrHeight), Leaves: make([]hash, maxLeaves), NewPeaks: make([]hash, mmrHeight)}
+	randtest.Check(t, 3, circuit, func(rng *rand.Rand) frontend.Circuit {
+		mmr := native.NewMMR(mmrHeight)
+		for i := rng.Intn(1<<mmrHeight - maxLeaves); i > 0; i-- {
+			if err := mmr.Append(randtest.Bytes(rng, 32)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := &MMRAppendCircuit{Peaks: encode(mmr.Peaks()), LeafCount: mmr.LeafCount(), Count: 1 + rng.Intn(maxLeaves)}
+		var leaves [][]byte
+		for i := 0; i < maxLeaves; i++ {
+			leaves = append(leaves, randtest.Bytes(rng, 32))
+		}
+		if err := mmr.Append(leaves[:w.Count.(int)]...); err != nil {
+			t.Fatal(err)
+		}
+		w.Leaves, w.NewPeaks, w.NewLeafCount = encode(leaves), encode(mmr.Peaks()), mmr.LeafCount()
+		return w
+	})
+
+	assert := test.NewAssert(t)
+	var leaves [][]byte
+	for i := 0; i < 1<<mmrHeight+maxLeaves; i++ {
+		leaves = append(leaves, randtest.Bytes(rand.New(rand.NewSource(int64(i))), 32))
+	}
+	// any number of leaves at any leaf count, the leaves after count are ignored
+	for leafCount := 0; leafCount < 1<<mmrHeight; leafCount++ {
+		for count := 0; count <= maxLeaves; count++ {
+			mmr := native.NewMMR(mmrHeight)
+			assert.NoError(mmr.Append(leaves[:leafCount]...))
+			w := &MMRAppendCircuit{Peaks: encode(mmr.Peaks()), LeafCount: leafCount, Leaves: encode(leaves[leafCount : leafCount+maxLeaves]), Count: count}
+			if err := mmr.Append(leaves[leafCount : leafCount+count]...); err != nil {
+				// more leaves than the mountain range holds
+				w.NewPeaks, w.NewLeafCount = w.Peaks, leafCount+count
+				assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d, count %d", leafCount, count)
+				continue
+			}
+			w.NewPeaks, w.NewLeafCount = encode(mmr.Peaks()), mmr.LeafCount()
+			assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d, count %d", leafCount, count)
+
+			// the peaks of the leaf count
+			w.LeafCount = (leafCount + 1) % (1<<mmrHeight - 1)
+			assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf count %d, count %d", leafCount, count)
+		}
+	}
+	// more leaves than the circuit appends
+	w := &MMRAppendCircuit{Peaks: encode(native.NewMMR(mmrHeight).Peaks()), LeafCount: 0, Leaves: encode(leaves[:maxLeaves]), Count: maxLeaves + 1}
+	mmr := native.NewMMR(mmrHeight)
+	assert.NoError(mmr.Append(leaves[:maxLeaves+1]...))
+	w.NewPeaks, w.NewLeafCount = encode(mmr.Peaks()), mmr.LeafCount()
+	assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+}
+
+func TestMMRVerifyProof(t *testing.T) {
+	circuit := &MMRVerifyProofCircuit{Peaks: make([]hash, mmrHeight), Siblings: make([]hash, mmrHeight-1)}
+	randtest.Check(t, 3, circuit, func(rng *rand.Rand) frontend.Circuit {
+		mmr := native.NewMMR(mmrHeight)
+		var leaves [][]byte
+		for i := 1 + rng.Intn(1<<mmrHeight-1); i > 0; i-- {
+			leaves = append(leaves, randtest.Bytes(rng, 32))
+		}
+		if err := mmr.Append(leaves...); err != nil {
+			t.Fatal(err)
+		}
+		index := rng.Intn(len(leaves))
+		siblings, err := mmr.Proof(uint64(index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &MMRVerifyProofCircuit{
+			Peaks:     encode(mmr.Peaks()),
+			LeafCount: mmr.LeafCount(),
+			LeafIndex: index,
+			Leaf:      bytes2Hash(leaves[index]),
+			Siblings:  encode(siblings),
+		}
+	})
+
+	assert := test.NewAssert(t)
+	const leafCount = 11
+	mmr := native.NewMMR(mmrHeight)
+	var leaves [][]byte
+	for i := 0; i < leafCount; i++ {
+		leaves = append(leaves, randtest.Bytes(rand.New(rand.NewSource(int64(i))), 32))
+	}
+	assert.NoError(mmr.Append(leaves...))
+	for index := 0; index < leafCount; index++ {
+		siblings, err := mmr.Proof(uint64(index))
+		assert.NoError(err)
+		w := &MMRVerifyProofCircuit{
+			Peaks:     encode(mmr.Peaks()),
+			LeafCount: leafCount,
+			LeafIndex: index,
+			Leaf:      bytes2Hash(leaves[index]),
+			Siblings:  encode(siblings),
+		}
+		assert.NoError(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf %d", index)
+
+		// another leaf
+		w.Leaf = bytes2Hash(leaves[(index+1)%leafCount])
+		assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()), "leaf %d", index)
+	}
+
+	// an index past the leaves, with the proof of the last leaf
+	siblings, err := mmr.Proof(10)
+	assert.NoError(err)
+	w := &MMRVerifyProofCircuit{Peaks: encode(mmr.Peaks()), LeafCount: leafCount, LeafIndex: 11, Leaf: bytes2Hash(make([]byte, 32)), Siblings: encode(siblings)}
+	assert.Error(test.IsSolved(circuit, w, ecc.BN254.ScalarField()))
+	_, err = mmr.Proof(leafCount)
+	assert.Error(err)
+}
+
+type MMRAppendCircuit struct {
+	Peaks        []hash `gnark:",public"`
+	LeafCount    frontend.Variable
+	Leaves       []hash
+	Count        frontend.Variable
+	NewPeaks     []hash `gnark:",public"`
+	NewLeafCount frontend.Variable
+}
+
+func (c *MMRAppendCircuit) Define(api frontend.API) error {
+	peaks, leafCount := NewKeccak(api).MMRAppend(c.Peaks, c.LeafCount, c.Leaves, c.Count)
+	for h := range peaks {
+		for i := range peaks[h] {
+			api.AssertIsEqual(peaks[h][i], c.NewPeaks[h][i])
+		}
+	}
+	api.AssertIsEqual(leafCount, c.NewLeafCount)
+	return nil
+}
+
+type MMRVerifyProofCircuit struct {
+	Peaks     []hash `gnark:",public"`
+	LeafCount frontend.Variable
+	LeafIndex frontend.Variable
+	Leaf      hash
+	Siblings  []hash
+}
+
+func (c *MMRVerifyProofCircuit) Define(api frontend.API) error {
+	NewKeccak(api).MMRVerifyProof(c.Peaks, c.LeafCount, c.LeafIndex, c.Leaf, c.Siblings)
+	return nil
+}
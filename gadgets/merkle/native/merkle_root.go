@@ -0,0 +1,62 @@
+// Package native computes the merkle gadgets out of circuit
+package native
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeccakMerkleRoot is merkle.Keccak.Root on byte leaves, each node is the keccak256 of its two children
+// concatenated. The number of leaves must be a power of two.
+func KeccakMerkleRoot(leaves [][]byte) ([]byte, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("no leaves to get keccak merkle root")
+	}
+	if len(leaves) == 1 {
+		return leaves[0], nil
+	}
+	if len(leaves)%2 == 1 {
+		return nil, fmt.Errorf("leaves length should be even: %d", len(leaves))
+	}
+	var hashes [][]byte
+	for i := 0; i < len(leaves); i += 2 {
+		hashes = append(hashes, crypto.Keccak256(leaves[i], leaves[i+1]))
+	}
+	return KeccakMerkleRoot(hashes)
+}
+
+// PaddedKeccakMerkleRoot is merkle.Keccak.RootVarLen on byte leaves. The leaves are padded with 32 byte zero
+// leaves up to the next power of two, a power of two number of leaves has the root of KeccakMerkleRoot.
+func PaddedKeccakMerkleRoot(leaves [][]byte) ([]byte, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("no leaves to get keccak merkle root")
+	}
+	padded := append([][]byte{}, leaves...)
+	for len(padded)&(len(padded)-1) != 0 {
+		padded = append(padded, make([]byte, 32))
+	}
+	return KeccakMerkleRoot(padded)
+}
+
+// PaddedKeccakMerkleProof returns the siblings of the leaf of index from the leaf up in the tree of
+// PaddedKeccakMerkleRoot, see merkle.Keccak.BranchRoot
+func PaddedKeccakMerkleProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf %d of %d leaves", index, len(leaves))
+	}
+	level := append([][]byte{}, leaves...)
+	for len(level)&(len(level)-1) != 0 {
+		level = append(level, make([]byte, 32))
+	}
+	var siblings [][]byte
+	for ; len(level) > 1; index /= 2 {
+		siblings = append(siblings, level[index^1])
+		var parents [][]byte
+		for i := 0; i < len(level); i += 2 {
+			parents = append(parents, crypto.Keccak256(level[i], level[i+1]))
+		}
+		level = parents
+	}
+	return siblings, nil
+}
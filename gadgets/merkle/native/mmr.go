@@ -0,0 +1,75 @@
+package native
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MMR is the merkle mountain range of merkle.MMRAppend on byte leaves. Peak h is the keccak merkle root of a
+// perfect tree of 2^h leaves if bit h of the leaf count is set, the peaks are ordered from the first leaves to the
+// last ones by decreasing height. It keeps all the nodes to generate the proofs of merkle.MMRVerifyProof.
+type MMR struct {
+	height int
+	// nodes[h] are the roots of the perfect trees of 2^h leaves, in the order of the leaves
+	nodes [][][]byte
+}
+
+// NewMMR returns an empty merkle mountain range of height peaks, it holds up to 2^height-1 leaves
+func NewMMR(height int) *MMR {
+	return &MMR{height: height, nodes: make([][][]byte, height)}
+}
+
+// LeafCount returns the number of leaves of the mountain range
+func (m *MMR) LeafCount() uint64 {
+	return uint64(len(m.nodes[0]))
+}
+
+// Append appends 32 byte leaves to the mountain range
+func (m *MMR) Append(leaves ...[]byte) error {
+	if m.LeafCount()+uint64(len(leaves)) >= 1<<m.height {
+		return fmt.Errorf("%d leaves exceed the mountain range of height %d", m.LeafCount()+uint64(len(leaves)), m.height)
+	}
+	for _, leaf := range leaves {
+		if len(leaf) != 32 {
+			return fmt.Errorf("leaf of %d bytes", len(leaf))
+		}
+	}
+	for _, leaf := range leaves {
+		m.nodes[0] = append(m.nodes[0], leaf)
+		for h := 0; len(m.nodes[h])%2 == 0; h++ {
+			last := len(m.nodes[h]) - 1
+			m.nodes[h+1] = append(m.nodes[h+1], crypto.Keccak256(m.nodes[h][last-1], m.nodes[h][last]))
+		}
+	}
+	return nil
+}
+
+// Peaks returns the peaks by height, the peaks of the heights that are not set in the leaf count are zero
+func (m *MMR) Peaks() [][]byte {
+	peaks := make([][]byte, m.height)
+	for h := range peaks {
+		peaks[h] = make([]byte, 32)
+		if m.LeafCount()>>h&1 == 1 {
+			peaks[h] = m.nodes[h][len(m.nodes[h])-1]
+		}
+	}
+	return peaks
+}
+
+// Proof returns the height-1 siblings of the branch from the leaf of index to its peak, from the leaf up. The
+// siblings above the peak of the leaf are zero.
+func (m *MMR) Proof(index uint64) ([][]byte, error) {
+	if index >= m.LeafCount() {
+		return nil, fmt.Errorf("leaf %d of a mountain range of %d leaves", index, m.LeafCount())
+	}
+	siblings := make([][]byte, m.height-1)
+	for h := range siblings {
+		siblings[h] = make([]byte, 32)
+		// the leaf is in the tree of the peak at the highest bit of the leaf count that the index does not have
+		if (index^m.LeafCount())>>(h+1) != 0 {
+			siblings[h] = m.nodes[h][index>>h^1]
+		}
+	}
+	return siblings, nil
+}
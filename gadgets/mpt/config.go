@@ -0,0 +1,86 @@
+package mpt
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+const (
+	// MaxBranchRlpHexLen is the rlp length of a branch node with 16 hashed children and an empty value
+	MaxBranchRlpHexLen = 1064
+	// maxNodeHexLen bounds the node buffers of the mpt gadgets to 5 keccak blocks
+	maxNodeHexLen = 5 * 272
+)
+
+// MPTConfig bounds the proofs an mpt gadget is compiled for. MaxDepth counts all nodes of a proof including the
+// leaf, all lengths are in nibbles. The config is validated when the circuit is compiled, so deeper tries only
+// need a larger MaxDepth instead of a change to the gadget.
+type MPTConfig struct {
+	MaxDepth           int
+	MaxBranchRlpHexLen int
+	MaxLeafRlpHexLen   int // padded leaf rlp length, only used by gadgets hashing the leaf
+	KeyLength          int // fixed key length, or the max key length for variable length keys
+}
+
+func NewMPTConfig(maxDepth int, keyLength int, maxLeafRlpHexLen int) MPTConfig {
+	return MPTConfig{
+		MaxDepth:           maxDepth,
+		MaxBranchRlpHexLen: MaxBranchRlpHexLen,
+		MaxLeafRlpHexLen:   maxLeafRlpHexLen,
+		KeyLength:          keyLength,
+	}
+}
+
+func (c MPTConfig) Validate() error {
+	if c.MaxDepth < 2 {
+		return fmt.Errorf("mpt config: max depth %d, need at least one node above the leaf", c.MaxDepth)
+	}
+	if c.KeyLength <= 0 || c.KeyLength > 64 {
+		return fmt.Errorf("mpt config: key length %d out of range (0, 64]", c.KeyLength)
+	}
+	if c.MaxBranchRlpHexLen <= 0 || c.MaxBranchRlpHexLen%2 != 0 || c.MaxBranchRlpHexLen > maxNodeHexLen {
+		return fmt.Errorf("mpt config: max branch rlp length %d must be even and in range (0, %d]", c.MaxBranchRlpHexLen, maxNodeHexLen)
+	}
+	return nil
+}
+
+// validateLeaf checks that a leaf with values up to maxValueLength fits into MaxLeafRlpHexLen
+func (c MPTConfig) validateLeaf(maxValueLength int) error {
+	minLeafRlpHexLen := 4 + (c.KeyLength + 2) + 4 + maxValueLength
+	if c.MaxLeafRlpHexLen < minLeafRlpHexLen || c.MaxLeafRlpHexLen > maxNodeHexLen {
+		return fmt.Errorf("mpt config: max leaf rlp length %d out of range [%d, %d]", c.MaxLeafRlpHexLen, minLeafRlpHexLen, maxNodeHexLen)
+	}
+	if c.MaxLeafRlpHexLen%16 != 0 {
+		return fmt.Errorf("mpt config: max leaf rlp length %d is not a multiple of 16", c.MaxLeafRlpHexLen)
+	}
+	return nil
+}
+
+// validateNodes checks the witness shape of nodeCount proof nodes against the config
+func (c MPTConfig) validateNodes(nodeCount int, key []frontend.Variable, keyFragmentStarts []frontend.Variable, nodeRlp [][]frontend.Variable, perNode ...[]frontend.Variable) error {
+	if len(key) < c.KeyLength {
+		return fmt.Errorf("mpt config: key has %d nibbles, need %d", len(key), c.KeyLength)
+	}
+	if len(keyFragmentStarts) != c.MaxDepth {
+		return fmt.Errorf("mpt config: %d key fragment starts for max depth %d", len(keyFragmentStarts), c.MaxDepth)
+	}
+	if len(nodeRlp) != nodeCount {
+		return fmt.Errorf("mpt config: %d node rlps, need %d", len(nodeRlp), nodeCount)
+	}
+	minNodeHexLen := c.MaxBranchRlpHexLen
+	if extensionHexLen := 4 + 2 + c.KeyLength + 2 + 64; extensionHexLen > minNodeHexLen {
+		minNodeHexLen = extensionHexLen
+	}
+	for i, node := range nodeRlp {
+		if len(node) < minNodeHexLen || len(node) > maxNodeHexLen || len(node)%16 != 0 {
+			return fmt.Errorf("mpt config: node rlp %d has length %d, need a multiple of 16 in range [%d, %d]", i, len(node), minNodeHexLen, maxNodeHexLen)
+		}
+	}
+	for _, values := range perNode {
+		if len(values) != nodeCount {
+			return fmt.Errorf("mpt config: %d per node values, need %d", len(values), nodeCount)
+		}
+	}
+	return nil
+}
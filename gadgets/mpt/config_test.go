@@ -0,0 +1,87 @@
+package mpt
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+func TestMPTConfigValidate(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	assert.NoError(NewMPTConfig(StorageMPTMaxDepth, 64, StorageLeafMaxBlockHexLen).Validate())
+	assert.NoError(NewMPTConfig(16, 64, StorageLeafMaxBlockHexLen).Validate())
+
+	assert.Error(NewMPTConfig(1, 64, StorageLeafMaxBlockHexLen).Validate())
+	assert.Error(NewMPTConfig(StorageMPTMaxDepth, 65, StorageLeafMaxBlockHexLen).Validate())
+	assert.Error(MPTConfig{MaxDepth: StorageMPTMaxDepth, MaxBranchRlpHexLen: 1065, KeyLength: 64}.Validate())
+	assert.Error(MPTConfig{MaxDepth: StorageMPTMaxDepth, MaxBranchRlpHexLen: 272 * 6, KeyLength: 64}.Validate())
+
+	assert.NoError(NewMPTConfig(StorageMPTMaxDepth, 64, StorageLeafMaxBlockHexLen).validateLeaf(MaxValueLengthForStorage))
+	assert.Error(NewMPTConfig(StorageMPTMaxDepth, 64, StorageLeafMaxBlockHexLen).validateLeaf(MaxValueLengthForAccount))
+	assert.Error(NewMPTConfig(StorageMPTMaxDepth, 64, 280).validateLeaf(MaxValueLengthForStorage))
+}
+
+type exclusionConfigCircuit struct {
+	Key                  [64]frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    [2]frontend.Variable
+	NodeRlp              [2][BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [2]frontend.Variable
+	NodeTypes            [2]frontend.Variable
+	Depth                frontend.Variable
+	config               MPTConfig
+}
+
+func (c *exclusionConfigCircuit) Define(api frontend.API) error {
+	nodeRlp := [][]frontend.Variable{c.NodeRlp[0][:], c.NodeRlp[1][:]}
+	result := CheckMPTExclusionFixedKeyLength(api, c.config, MaxValueLengthForStorage, c.Key[:], c.RootHash, c.KeyFragmentStarts[:],
+		nodeRlp, c.NodePathPrefixLength[:], c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+func TestMPTConfigCompileError(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the witness has 2 layers, a config for 3 is rejected when compiling
+	circuit := &exclusionConfigCircuit{config: NewMPTConfig(3, 64, 0)}
+	_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	assert.ErrorContains(err, "mpt config: 2 key fragment starts for max depth 3")
+
+	circuit = &exclusionConfigCircuit{config: MPTConfig{MaxDepth: 2, MaxBranchRlpHexLen: 2000, KeyLength: 64}}
+	_, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	assert.ErrorContains(err, "mpt config: max branch rlp length 2000")
+}
+
+type leafConfigCircuit struct {
+	Key                  [64]frontend.Variable
+	Value                [MaxValueLengthForStorage]frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    [2]frontend.Variable
+	LeafRlp              [AccountLeafMaxBlockHexLen]frontend.Variable
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [1][BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [1]frontend.Variable
+	NodeTypes            [1]frontend.Variable
+	Depth                frontend.Variable
+}
+
+func (c *leafConfigCircuit) Define(api frontend.API) error {
+	result := CheckMPTInclusionFixedKeyLength(api, NewMPTConfig(2, 64, StorageLeafMaxBlockHexLen), MaxValueLengthForStorage,
+		c.Key[:], c.Value[:], c.RootHash, c.KeyFragmentStarts[:], c.LeafRlp[:], c.LeafPathPrefixLength,
+		[][]frontend.Variable{c.NodeRlp[0][:]}, c.NodePathPrefixLength[:], c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+func TestMPTConfigLeafLength(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the leaf witness must have the declared max leaf length
+	_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &leafConfigCircuit{})
+	assert.ErrorContains(err, "mpt config: leaf rlp has length 544, need 272")
+}
@@ -1,25 +1,32 @@
 package mpt
 
 import (
+	"math"
+	"math/big"
 	"testing"
 
+	"github.com/celer-network/brevis-circuits/fabric/headers/headerutil"
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	rlpnative "github.com/celer-network/brevis-circuits/gadgets/rlp/native"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type EthBlockHashCircuit struct {
-	HeaderRlp          [EthBlockHeadMaxBlockHexSize]frontend.Variable
-	BlockRlpFieldNum   frontend.Variable
-	BlockRlpRoundIndex frontend.Variable
-	BlockHeaderHash    [64]frontend.Variable
-	BlockTime          [8]frontend.Variable
+	HeaderRlp        [EthBlockHeadMaxBlockHexSize]frontend.Variable
+	BlockRlpFieldNum frontend.Variable
+	BlockHeaderHash  [64]frontend.Variable
+	BlockTime        [8]frontend.Variable
 }
 
 func (c *EthBlockHashCircuit) Define(api frontend.API) error {
-	var result = CheckEthBlockHash(api, c.HeaderRlp, c.BlockRlpFieldNum, c.BlockRlpRoundIndex)
+	var result = CheckEthBlockHash(api, c.HeaderRlp, c.BlockRlpFieldNum)
 	api.AssertIsEqual(result.Output, 1)
 	for i := 0; i < 64; i++ {
 		api.AssertIsEqual(result.BlockHash[i], c.BlockHeaderHash[i])
@@ -39,15 +46,9 @@ func Test_Eth_Block_Hash(t *testing.T) {
 	// 0x67c5d26ae6ef00adcf970d9b1876f0eaec41f94d88b7a0299e9d6109cdd9bcd8
 	rlpBytes, _ := hexutil.Decode(blockRlpHex)
 
-	blockRlpRoundIndex := keccak.GetRoundIndex(len(rlpBytes) * 8)
-
 	paddedRlpBytes := keccak.Pad101Bytes(rlpBytes)
 
-	var nibbles [EthBlockHeadMaxBlockHexSize]frontend.Variable
-	for i, b := range paddedRlpBytes {
-		nibbles[i*2] = b >> 4
-		nibbles[i*2+1] = b & 0x0F
-	}
+	nibbles := headerNibbles(paddedRlpBytes)
 
 	var blockTimeHex = "0x6437ce7f"
 	blockTimeBytes, err := hexutil.Decode(blockTimeHex)
@@ -65,13 +66,94 @@ func Test_Eth_Block_Hash(t *testing.T) {
 	hashRootNibble := getNibbleFromBytes(hashRootBytes)
 
 	witness := &EthBlockHashCircuit{
-		HeaderRlp:          nibbles,
-		BlockRlpRoundIndex: blockRlpRoundIndex,
-		BlockRlpFieldNum:   17,
-		BlockHeaderHash:    hashRootNibble,
-		BlockTime:          blockTimeNibbles,
+		HeaderRlp:        nibbles,
+		BlockRlpFieldNum: 17,
+		BlockHeaderHash:  hashRootNibble,
+		BlockTime:        blockTimeNibbles,
+	}
+
+	err = test.IsSolved(&EthBlockHashCircuit{}, witness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
+
+// headerNibbles returns the nibbles of the padded header rlp, filled with zeros
+func headerNibbles(paddedRlp []byte) [EthBlockHeadMaxBlockHexSize]frontend.Variable {
+	var nibbles [EthBlockHeadMaxBlockHexSize]frontend.Variable
+	for i := range nibbles {
+		nibbles[i] = 0
+	}
+	for i, b := range paddedRlp {
+		nibbles[i*2] = b >> 4
+		nibbles[i*2+1] = b & 0x0F
 	}
+	return nibbles
+}
+
+func newEthBlockHashWitness(headerRlp []byte, fieldNum int) *EthBlockHashCircuit {
+	var fields [][]byte
+	if err := rlp.DecodeBytes(headerRlp, &fields); err != nil {
+		panic(err)
+	}
+	// the first 8 nibbles of the time, all of it for the 4 byte times of the fixtures
+	var blockTime [8]frontend.Variable
+	for i, n := range rlpnative.Nibbles(fields[11])[:8] {
+		blockTime[i] = n
+	}
+	return &EthBlockHashCircuit{
+		HeaderRlp:        headerNibbles(keccak.Pad101Bytes(headerRlp)),
+		BlockRlpFieldNum: fieldNum,
+		BlockHeaderHash:  getNibbleFromBytes(crypto.Keccak256(headerRlp)),
+		BlockTime:        blockTime,
+	}
+}
+
+func Test_Eth_Block_Hash_Forks(t *testing.T) {
+	assert := test.NewAssert(t)
+	for _, f := range headerutil.HeaderFixtures {
+		headerRlp := hexutil.MustDecode(f.Rlp)
+		assert.Equal(f.Hash, crypto.Keccak256Hash(headerRlp).Hex(), f.Fork)
+
+		witness := newEthBlockHashWitness(headerRlp, f.FieldNum)
+		err := test.IsSolved(&EthBlockHashCircuit{}, witness, ecc.BN254.ScalarField())
+		assert.NoError(err, f.Fork)
+
+		// the field count is bound by the rlp list length
+		for _, fieldNum := range []int{f.FieldNum - 1, f.FieldNum + 1} {
+			witness.BlockRlpFieldNum = fieldNum
+			err = test.IsSolved(&EthBlockHashCircuit{}, witness, ecc.BN254.ScalarField())
+			assert.Error(err, "%s with %d fields", f.Fork, fieldNum)
+		}
+	}
+}
+
+// Test_Eth_Block_Hash_MaxSize checks a Prague header with all fields of their max lengths, which needs the sixth
+// keccak round
+func Test_Eth_Block_Hash_MaxSize(t *testing.T) {
+	assert := test.NewAssert(t)
+	max32 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	maxU64 := uint64(math.MaxUint64)
+	hash := common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	h := &headerutil.Header{
+		Header: types.Header{
+			Difficulty:      max32,
+			Number:          new(big.Int).SetUint64(maxU64),
+			GasLimit:        maxU64,
+			GasUsed:         maxU64,
+			Time:            maxU64,
+			Extra:           hash[:],
+			BaseFee:         max32,
+			WithdrawalsHash: &hash,
+		},
+		BlobGasUsed:      &maxU64,
+		ExcessBlobGas:    &maxU64,
+		ParentBeaconRoot: &hash,
+		RequestsHash:     &hash,
+	}
+	headerRlp, err := rlp.EncodeToBytes(h)
+	assert.NoError(err)
+	assert.Greater(len(headerRlp), (keccak.BLOCK_HEADER_ROUNDS-1)*136)
 
+	witness := newEthBlockHashWitness(headerRlp, headerutil.PragueFieldNum)
 	err = test.IsSolved(&EthBlockHashCircuit{}, witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
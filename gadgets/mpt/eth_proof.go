@@ -4,22 +4,25 @@ import (
 	"math"
 	"math/big"
 
+	"github.com/celer-network/brevis-circuits/gadgets/conv"
 	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/merkle"
 	"github.com/celer-network/brevis-circuits/gadgets/rlp"
 	"github.com/consensys/gnark/frontend"
 )
 
 const (
-	ethBlockHeadMaxRound        = 5
+	ethBlockHeadMaxRound        = keccak.BLOCK_HEADER_ROUNDS
 	EthBlockHeadMaxBlockHexSize = ethBlockHeadMaxRound * 272
 
 	StorageMaxValueLength     = 66
 	StorageMPTMaxDepth        = 8
 	StorageLeafMaxBlockHexLen = 272
 
-	AccountMPTMaxDepth       = 9
-	AccountKeyLength         = 64
-	MaxValueLengthForAccount = 228 //  228: Account   66: Storage
+	AccountMPTMaxDepth        = 9
+	AccountKeyLength          = 64
+	AccountLeafMaxBlockHexLen = 272 * 2
+	MaxValueLengthForAccount  = 228 //  228: Account   66: Storage
 
 	MaxDepth                 = 9
 	MaxValueLengthForStorage = 66
@@ -35,6 +38,12 @@ type EthAccountProofResult struct {
 	Balance       [24]frontend.Variable
 	StorageRoot   [64]frontend.Variable
 	CodeHash      [64]frontend.Variable
+
+	// Decoded account fields. The nonce is at most 64 bits (EIP-2681) and the balance at most 96 bits, accounts
+	// exceeding these bounds can not be proven.
+	NonceValue    frontend.Variable
+	BalanceValue  frontend.Variable
+	CodeHashLimbs [2]frontend.Variable // big endian upper and lower 128 bits
 }
 
 func CheckEthAccountProof(
@@ -44,11 +53,9 @@ func CheckEthAccountProof(
 	addressHash [64]frontend.Variable, // padded address hash
 	keyFragmentStarts []frontend.Variable, // [maxDepth]
 	addressRlp [228]frontend.Variable,
-	leafRlp []frontend.Variable, // [maxLeafRlpLength]
-	leafRoundIndex frontend.Variable,
+	leafRlp []frontend.Variable, // [AccountLeafMaxBlockHexLen]
 	leafPathPrefixLength frontend.Variable,
 	nodeRlp [][]frontend.Variable, // [maxDepth - 1][maxBranchRlpLength]
-	nodeRoundIndexs []frontend.Variable,
 	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
 	nodeTypes []frontend.Variable, // [maxDepth - 1]
 	depth frontend.Variable,
@@ -81,18 +88,15 @@ func CheckEthAccountProof(
 
 	mptInclusionResult := CheckMPTInclusionFixedKeyLength(
 		api,
-		maxDepth,
-		64,
+		NewMPTConfig(maxDepth, AccountKeyLength, AccountLeafMaxBlockHexLen),
 		228,
 		addressHash[:],
 		addressRlp[:],
 		stateRoot,
 		keyFragmentStarts,
 		leafRlp,
-		leafRoundIndex,
 		leafPathPrefixLength,
 		nodeRlp,
-		nodeRoundIndexs,
 		nodePathPrefixLength,
 		nodeTypes,
 		depth,
@@ -106,13 +110,33 @@ func CheckEthAccountProof(
 		Balance:       balance,
 		StorageRoot:   storageRoot,
 		CodeHash:      codeHash,
+		NonceValue:    rlpNumber(api, nonce[:16], nonceLength),
+		BalanceValue:  rlpNumber(api, balance[:], balanceLength),
+		CodeHashLimbs: [2]frontend.Variable{nibblesToNumber(api, codeHash[:32]), nibblesToNumber(api, codeHash[32:])},
+	}
+}
+
+// rlpNumber decodes the big endian number in the first length nibbles of field. The nibbles after length belong to
+// the following rlp items and are shifted out, length is constrained to at most len(field).
+func rlpNumber(api frontend.API, field []frontend.Variable, length frontend.Variable) frontend.Variable {
+	maxLength := len(field)
+	shifted := rlp.ShiftRight(api, maxLength, rlp.LogCeil(maxLength+1), field, api.Sub(maxLength, length))
+	return nibblesToNumber(api, shifted)
+}
+
+func nibblesToNumber(api frontend.API, nibbles []frontend.Variable) frontend.Variable {
+	var ret frontend.Variable = 0
+	for _, nibble := range nibbles {
+		ret = api.Add(api.Mul(ret, 16), nibble)
 	}
+	return ret
 }
 
 type EthStorageProofResult struct {
 	Output      frontend.Variable
-	SlotValue   [2]frontend.Variable
+	SlotValue   [2]frontend.Variable // value nibbles left aligned, see SlotUint256 for the decoded number
 	ValueLength frontend.Variable
+	SlotUint256 [2]frontend.Variable // big endian upper and lower 128 bits of the slot value
 }
 
 func CheckEthStorageProof(
@@ -122,35 +146,26 @@ func CheckEthStorageProof(
 	slotHash [64]frontend.Variable, // padded slotHash
 	valueRlp [66]frontend.Variable,
 	keyFragmentStarts []frontend.Variable, // [maxDepth]
-	leafRlp []frontend.Variable, // [maxLeafRlpLength]
-	leafRoundIndex frontend.Variable,
+	leafRlp []frontend.Variable, // [StorageLeafMaxBlockHexLen]
 	leafPathPrefixLength frontend.Variable,
 	nodeRlp [][]frontend.Variable, // [maxDepth - 1][maxBranchRlpLength]
-	nodeRoundIndexs []frontend.Variable,
 	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
 	nodeTypes []frontend.Variable, // [maxDepth - 1]
 	depth frontend.Variable,
 ) EthStorageProofResult {
 	keyLength := 64
 	maxValueLength := 66
-	// maxLeafRlpLength := 4 + (keyLength + 2) + 4 + maxValueLength
-	// maxBranchRlpLength := 1064
-	// maxExtensionRlpLength := 4 + 2 + keyLength + 2 + 64
-
 	mptInclusionResult := CheckMPTInclusionFixedKeyLength(
 		api,
-		maxDepth,
-		keyLength,
+		NewMPTConfig(maxDepth, keyLength, StorageLeafMaxBlockHexLen),
 		maxValueLength,
 		slotHash[:],
 		valueRlp[:],
 		storageRoot,
 		keyFragmentStarts,
 		leafRlp,
-		leafRoundIndex,
 		leafPathPrefixLength,
 		nodeRlp,
-		nodeRoundIndexs,
 		nodePathPrefixLength,
 		nodeTypes,
 		depth,
@@ -185,13 +200,91 @@ func CheckEthStorageProof(
 		slotValue[i] = tmp
 	}
 
+	slotUint256, isSlotValue := DecodeSlotValue(api, valueRlp)
 	return EthStorageProofResult{
-		Output:      mptInclusionResult.Output,
+		Output:      api.Mul(mptInclusionResult.Output, isSlotValue),
 		SlotValue:   slotValue,
 		ValueLength: api.Sub(mptInclusionResult.ValueLength, 2),
+		SlotUint256: slotUint256,
 	}
 }
 
+// DecodeSlotValue decodes the rlp encoded storage slot value into a uint256 as big endian upper and lower 128
+// bits. Values below 0x80 are encoded as a single literal byte, all other values as a string of at most 32 bytes.
+// The nibbles after the value are not part of the leaf and are shifted out, so each limb is fully determined by the
+// proven value and fits into 128 bits. The returned flag is 0 and the value 0 if the prefix is not the one of a
+// value of at most 32 bytes.
+func DecodeSlotValue(api frontend.API, valueRlp [66]frontend.Variable) ([2]frontend.Variable, frontend.Variable) {
+	isBig, isLiteral, prefixOrTotalHexLen, _, _ := rlp.RlpFieldPrefix(api, [2]frontend.Variable{valueRlp[0], valueRlp[1]})
+
+	var value [64]frontend.Variable
+	for i := 0; i < 64; i++ {
+		// skip the prefix byte unless the value is a literal
+		value[i] = api.Select(isLiteral, valueRlp[i], valueRlp[i+2])
+	}
+	valueHexLen := api.Select(isLiteral, 2, prefixOrTotalHexLen)
+	// a 32 byte value never needs a long string prefix, other values shift out to 0
+	isSlotValue := api.Mul(api.Sub(1, isBig), rlp.LessThan(api, valueHexLen, 64+1))
+	valueHexLen = api.Mul(isSlotValue, valueHexLen)
+	shifted := rlp.ShiftRight(api, 64, rlp.LogCeil(64+1), value[:], api.Sub(64, valueHexLen))
+	return [2]frontend.Variable{nibblesToNumber(api, shifted[:32]), nibblesToNumber(api, shifted[32:])}, isSlotValue
+}
+
+// CheckEthAccountExclusionProof checks that no account exists at addressHash in the state trie
+func CheckEthAccountExclusionProof(
+	api frontend.API,
+	maxDepth int,
+	stateRoot [64]frontend.Variable,
+	addressHash [64]frontend.Variable, // padded address hash
+	keyFragmentStarts []frontend.Variable, // [maxDepth]
+	nodeRlp [][]frontend.Variable, // [maxDepth][maxBranchRlpLength], including the terminal node
+	nodePathPrefixLength []frontend.Variable, // [maxDepth]
+	nodeTypes []frontend.Variable, // [maxDepth]
+	depth frontend.Variable,
+) frontend.Variable {
+	mptExclusionResult := CheckMPTExclusionFixedKeyLength(
+		api,
+		NewMPTConfig(maxDepth, AccountKeyLength, 0),
+		MaxValueLengthForAccount,
+		addressHash[:],
+		stateRoot,
+		keyFragmentStarts,
+		nodeRlp,
+		nodePathPrefixLength,
+		nodeTypes,
+		depth,
+	)
+	return mptExclusionResult.Output
+}
+
+// CheckEthStorageExclusionProof checks that slotHash is absent from the storage trie, which is how zero
+// valued slots are stored
+func CheckEthStorageExclusionProof(
+	api frontend.API,
+	maxDepth int,
+	storageRoot [64]frontend.Variable,
+	slotHash [64]frontend.Variable, // padded slotHash
+	keyFragmentStarts []frontend.Variable, // [maxDepth]
+	nodeRlp [][]frontend.Variable, // [maxDepth][maxBranchRlpLength], including the terminal node
+	nodePathPrefixLength []frontend.Variable, // [maxDepth]
+	nodeTypes []frontend.Variable, // [maxDepth]
+	depth frontend.Variable,
+) frontend.Variable {
+	mptExclusionResult := CheckMPTExclusionFixedKeyLength(
+		api,
+		NewMPTConfig(maxDepth, 64, 0),
+		MaxValueLengthForStorage,
+		slotHash[:],
+		storageRoot,
+		keyFragmentStarts,
+		nodeRlp,
+		nodePathPrefixLength,
+		nodeTypes,
+		depth,
+	)
+	return mptExclusionResult.Output
+}
+
 type EthBlockHashResult struct {
 	Output            frontend.Variable
 	BlockHash         [64]frontend.Variable
@@ -203,29 +296,21 @@ type EthBlockHashResult struct {
 	ReceiptsRoot      [64]frontend.Variable
 }
 
+// CheckEthBlockHash decodes the header fields of blockRlp and computes its hash. The header is of any fork from
+// London to Prague, blockFieldsNum is its number of fields.
 func CheckEthBlockHash(
 	api frontend.API,
-	blockRlp [EthBlockHeadMaxBlockHexSize]frontend.Variable, // padded rlp data
+	blockRlp [EthBlockHeadMaxBlockHexSize]frontend.Variable,
 	blockFieldsNum frontend.Variable,
-	blockRoundIndex frontend.Variable,
 ) EthBlockHashResult {
-	blockHashArrayCheck := rlp.ArrayCheck{
-		MaxHexLen:            EthBlockHeadMaxBlockHexSize,
-		MaxFields:            17,
-		ArrayPrefixMaxHexLen: 4,
-		FieldMinHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 0, 0, 0, 0, 0, 0, 64, 16, 0, 64},
-		FieldMaxHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 14, 16, 8, 8, 8, 64, 64, 18, 16, 64},
-	}
+	blockHashArrayCheck := rlp.NewBlkHeaderArrayCheck(EthBlockHeadMaxBlockHexSize)
 	rlpout, totalRlpLength, fieldsLength, fields := blockHashArrayCheck.BlkHeaderRlpCheck(
 		api,
 		blockRlp[:],
 		blockFieldsNum,
 	)
 
-	blocks := keccak.NibblesToU64Array(api, blockRlp[:])
-
-	//keccakRounds := api.Sub(5, rlp.LessThan(api, totalRlpLength, 1089))
-	blockHash := rlp.Keccak256AsNibbles(api, totalRlpLength, blocks, blockRoundIndex).Output
+	blockHash := rlp.Keccak256Nibbles(api, blockRlp[:], totalRlpLength).Output
 
 	var stateRoot [64]frontend.Variable
 	var transactionsRoot [64]frontend.Variable
@@ -259,10 +344,91 @@ func CheckEthBlockHash(
 
 }
 
+// CheckEthBlockHashInChunk checks that blockHash is the leaf of index blockIndex in the keccak merkle tree of
+// chunkRoot, the ChunkRoot of a chunk of headers proven by the headers circuit. The hashes are split in two uint128s,
+// chunkProof holds the siblings of the block hash from the leaf up, see headerutil.ComputeChunkProof. The chunk trees
+// of a headers circuit have a fixed depth, so len(chunkProof) is headerutil.ChunkDepth of its max headers whatever
+// the number of headers of the chunk.
+func CheckEthBlockHashInChunk(
+	api frontend.API,
+	chunkRoot [2]frontend.Variable,
+	blockHash [2]frontend.Variable,
+	blockIndex frontend.Variable,
+	chunkProof [][2]frontend.Variable,
+) {
+	var siblings [][256]frontend.Variable
+	for _, sibling := range chunkProof {
+		siblings = append(siblings, conv.Uint128s2Bits(api, sibling))
+	}
+	leaf := conv.Uint128s2Bits(api, blockHash)
+	root := conv.Bits2Uint128s(api, merkle.NewKeccak(api).BranchRoot(leaf, blockIndex, siblings))
+	for i := 0; i < 2; i++ {
+		api.AssertIsEqual(root[i], chunkRoot[i])
+	}
+}
+
+type EthBlockAccountProofResult struct {
+	Output      frontend.Variable
+	BlockNumber frontend.Variable
+	StorageRoot [64]frontend.Variable
+}
+
+// CheckEthBlockAccountProof checks the block header against blockHash and the account proof against the state
+// root of the header. It is the part shared by all storage proofs of one account at one block.
+func CheckEthBlockAccountProof(
+	api frontend.API,
+	addressMaxDepth int,
+	blockHash [64]frontend.Variable, // big endian 128-bit
+	blockFieldsNum frontend.Variable,
+	addressHash [64]frontend.Variable, // padded address hash
+	blockHashRlp [EthBlockHeadMaxBlockHexSize]frontend.Variable,
+	addressKeyFragmentStarts []frontend.Variable, // [addressMaxDepth]
+	addressRlp [228]frontend.Variable,
+	addressLeafRlp []frontend.Variable, // [addressMaxLeafRlpLength]
+	addressLeafPathPrefixLength frontend.Variable,
+	addressNodeRlp [][]frontend.Variable, // [addressMaxDepth - 1][addressMaxBranchRlpLength]
+	addressNodePathPrefixLength []frontend.Variable, // [addressMaxDepth - 1]
+	addressNodeTypes []frontend.Variable, // [addressMaxDepth - 1]
+	addressDepth frontend.Variable,
+) EthBlockAccountProofResult {
+	rlpBlockHashResult := CheckEthBlockHash(api, blockHashRlp, blockFieldsNum)
+
+	// rlpBlockHashResult.
+	blockHashEqual := rlp.ArrayEqual(api, blockHash[:], rlpBlockHashResult.BlockHash[:], 64, 64)
+
+	addressProofResult := CheckEthAccountProof(
+		api,
+		addressMaxDepth,
+		rlpBlockHashResult.StateRoot,
+		addressHash,
+		addressKeyFragmentStarts,
+		addressRlp,
+		addressLeafRlp,
+		addressLeafPathPrefixLength,
+		addressNodeRlp,
+		addressNodePathPrefixLength,
+		addressNodeTypes,
+		addressDepth,
+	)
+
+	blockNumberShift := rlp.ShiftRight(api, 8, 3, rlpBlockHashResult.BlockNumber[:], api.Sub(8, rlpBlockHashResult.BlockNumberLength))
+	var blockNumber = frontend.Variable(0)
+	for i := 0; i < 8; i++ {
+		blockNumber = api.Add(blockNumber, api.Mul(blockNumberShift[i], big.NewInt(int64(math.Pow(16, float64(7-i))))))
+	}
+
+	return EthBlockAccountProofResult{
+		Output:      rlp.Equal(api, api.Add(blockHashEqual, addressProofResult.Output), 2),
+		BlockNumber: blockNumber,
+		StorageRoot: addressProofResult.StorageRoot,
+	}
+}
+
 type EthAddressStorageProofResult struct {
 	Output      frontend.Variable
 	BlockNumber frontend.Variable
 	SlotValue   [2]frontend.Variable
+	SlotUint256 [2]frontend.Variable
 }
 
 func CheckEthAddressStorageProof(
@@ -271,48 +437,38 @@ func CheckEthAddressStorageProof(
 	storageMaxDepth int,
 	blockHash [64]frontend.Variable, // big endian 128-bit
 	blockFieldsNum frontend.Variable,
-	blockRoundIndex frontend.Variable,
 	addressHash [64]frontend.Variable, // padded address hash
 	slot [64]frontend.Variable, // 128-bit
 	blockHashRlp [EthBlockHeadMaxBlockHexSize]frontend.Variable,
 	addressKeyFragmentStarts []frontend.Variable, // [addressMaxDepth]
 	addressRlp [228]frontend.Variable,
 	addressLeafRlp []frontend.Variable, // [addressMaxLeafRlpLength]
-	addressLeafRoundIndex frontend.Variable,
 	addressLeafPathPrefixLength frontend.Variable,
 	addressNodeRlp [][]frontend.Variable, // [addressMaxDepth - 1][addressMaxBranchRlpLength]
-	addressNodeRlpRoundIndexes [AccountMPTMaxDepth - 1]frontend.Variable,
 	addressNodePathPrefixLength []frontend.Variable, // [addressMaxDepth - 1]
 	addressNodeTypes []frontend.Variable, // [addressMaxDepth - 1]
 	addressDepth frontend.Variable,
 	storageKeyFragmentStarts []frontend.Variable, // [storageMaxDepth]
 	slotValueRlp [66]frontend.Variable,
 	storageLeafRlp []frontend.Variable, // [storageMaxLeafRlpLength]
-	storageLeafRoundIndex frontend.Variable,
 	storageLeafPathPrefixLength frontend.Variable,
 	storageNodeRlp [][]frontend.Variable, // [storageMaxDepth - 1][storageMaxBranchRlpLength]
-	storageNodeRlpRoundIndex []frontend.Variable,
 	storageNodePathPrefixLength []frontend.Variable, // [storageMaxDepth - 1]
 	storageNodeTypes []frontend.Variable, // [storageMaxDepth - 1]
 	storageProofDepth frontend.Variable,
 ) EthAddressStorageProofResult {
-	rlpBlockHashResult := CheckEthBlockHash(api, blockHashRlp, blockFieldsNum, blockRoundIndex)
-
-	// rlpBlockHashResult.
-	blockHashEqual := rlp.ArrayEqual(api, blockHash[:], rlpBlockHashResult.BlockHash[:], 64, 64)
-
-	addressProofResult := CheckEthAccountProof(
+	blockAccountProofResult := CheckEthBlockAccountProof(
 		api,
 		addressMaxDepth,
-		rlpBlockHashResult.StateRoot,
+		blockHash,
+		blockFieldsNum,
 		addressHash,
+		blockHashRlp,
 		addressKeyFragmentStarts,
 		addressRlp,
 		addressLeafRlp,
-		addressLeafRoundIndex,
 		addressLeafPathPrefixLength,
 		addressNodeRlp,
-		addressNodeRlpRoundIndexes[:],
 		addressNodePathPrefixLength,
 		addressNodeTypes,
 		addressDepth,
@@ -321,29 +477,22 @@ func CheckEthAddressStorageProof(
 	storageProofResult := CheckEthStorageProof(
 		api,
 		storageMaxDepth,
-		addressProofResult.StorageRoot,
+		blockAccountProofResult.StorageRoot,
 		slot,
 		slotValueRlp,
 		storageKeyFragmentStarts,
 		storageLeafRlp,
-		storageLeafRoundIndex,
 		storageLeafPathPrefixLength,
 		storageNodeRlp,
-		storageNodeRlpRoundIndex,
 		storageNodePathPrefixLength,
 		storageNodeTypes,
 		storageProofDepth,
 	)
 
-	blockNumberShift := rlp.ShiftRight(api, 8, 3, rlpBlockHashResult.BlockNumber[:], api.Sub(8, rlpBlockHashResult.BlockNumberLength))
-	var blockNumber = frontend.Variable(0)
-	for i := 0; i < 8; i++ {
-		blockNumber = api.Add(blockNumber, api.Mul(blockNumberShift[i], big.NewInt(int64(math.Pow(16, float64(7-i))))))
-	}
-
 	return EthAddressStorageProofResult{
-		BlockNumber: blockNumber,
+		BlockNumber: blockAccountProofResult.BlockNumber,
 		SlotValue:   storageProofResult.SlotValue,
-		Output:      rlp.Equal(api, api.Add(blockHashEqual, addressProofResult.Output, storageProofResult.Output), 3),
+		SlotUint256: storageProofResult.SlotUint256,
+		Output:      rlp.Equal(api, api.Add(blockAccountProofResult.Output, storageProofResult.Output), 2),
 	}
 }
@@ -0,0 +1,71 @@
+package mpt
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+type slotValueCircuit struct {
+	ValueRlp    [66]frontend.Variable
+	Value       [2]frontend.Variable `gnark:",public"`
+	IsSlotValue frontend.Variable
+}
+
+func (c *slotValueCircuit) Define(api frontend.API) error {
+	value, isSlotValue := DecodeSlotValue(api, c.ValueRlp)
+	api.AssertIsEqual(value[0], c.Value[0])
+	api.AssertIsEqual(value[1], c.Value[1])
+	api.AssertIsEqual(isSlotValue, c.IsSlotValue)
+	return nil
+}
+
+func newSlotValueAssignment(value *big.Int, junk byte) *slotValueCircuit {
+	encoded, _ := rlp.EncodeToBytes(value)
+	nibbles := hex.EncodeToString(encoded)
+	w := &slotValueCircuit{IsSlotValue: 1}
+	for i := range w.ValueRlp {
+		if i < len(nibbles) {
+			n, _ := hex.DecodeString("0" + nibbles[i:i+1])
+			w.ValueRlp[i] = n[0]
+		} else {
+			// nibbles after the value are not bound by the leaf
+			w.ValueRlp[i] = junk
+		}
+	}
+	var padded [32]byte
+	value.FillBytes(padded[:])
+	w.Value = [2]frontend.Variable{new(big.Int).SetBytes(padded[:16]), new(big.Int).SetBytes(padded[16:])}
+	return w
+}
+
+func TestDecodeSlotValue(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	max256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	values := []*big.Int{big.NewInt(1), big.NewInt(0x7f), big.NewInt(0x80), big.NewInt(0x06e5), big.NewInt(18e15), max256}
+	for _, value := range values {
+		for _, junk := range []byte{0, 0xf} {
+			w := newSlotValueAssignment(value, junk)
+			assert.NoError(test.IsSolved(&slotValueCircuit{}, w, ecc.BN254.ScalarField()), value.String())
+		}
+	}
+
+	// the decoded value is right aligned, a left aligned reading is rejected
+	w := newSlotValueAssignment(big.NewInt(0x06e5), 0)
+	w.Value = [2]frontend.Variable{new(big.Int).Lsh(big.NewInt(0x06e5), 112), 0}
+	assert.Error(test.IsSolved(&slotValueCircuit{}, w, ecc.BN254.ScalarField()))
+
+	// long strings and strings of more than 32 bytes are flagged instead of failing the circuit
+	for _, prefix := range [][2]frontend.Variable{{0xb, 0x8}, {0xa, 0x1}} {
+		w = newSlotValueAssignment(big.NewInt(0x06e5), 0)
+		w.ValueRlp[0], w.ValueRlp[1] = prefix[0], prefix[1]
+		w.Value, w.IsSlotValue = [2]frontend.Variable{0, 0}, 0
+		assert.NoError(test.IsSolved(&slotValueCircuit{}, w, ecc.BN254.ScalarField()))
+	}
+}
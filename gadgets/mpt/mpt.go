@@ -1,7 +1,8 @@
 package mpt
 
 import (
-	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"fmt"
+
 	"github.com/celer-network/brevis-circuits/gadgets/rlp"
 
 	"github.com/celer-network/goutils/log"
@@ -15,26 +16,66 @@ type CheckMPTInclusionFixedKeyLengthResult struct {
 
 func CheckMPTInclusionFixedKeyLength(
 	api frontend.API,
-	maxDepth int,
-	keyLength int,
+	config MPTConfig,
 	maxValueLength int,
 	key []frontend.Variable, // [keyLength]
 	value []frontend.Variable, // [maxValueLength]
 	rootHash [64]frontend.Variable, // Root hash should be 32-bytes long value. Divide it by 4-bits ===> 0xcf78 will be [c, f, 7, 8]
 	keyFragmentStarts []frontend.Variable, // [maxDepth]
 	leafRlp []frontend.Variable,
-	leafRoundIndex frontend.Variable,
 	leafPathPrefixLength frontend.Variable,
-	nodeRlp [][]frontend.Variable, // [maxDepth - 1][maxBranchRlpHexLen]
-	nodeRoundIndexes []frontend.Variable,
+	nodeRlp [][]frontend.Variable, // [maxDepth - 1][config.MaxBranchRlpHexLen]
 	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
 	nodeTypes []frontend.Variable, // [maxDepth - 1]
 	depth frontend.Variable,
 ) CheckMPTInclusionFixedKeyLengthResult {
-	api.AssertIsLessOrEqual(maxDepth, 10)
+	err := config.Validate()
+	if err == nil {
+		err = config.validateLeaf(maxValueLength)
+	}
+	if err == nil && len(leafRlp) != config.MaxLeafRlpHexLen {
+		err = fmt.Errorf("mpt config: leaf rlp has length %d, need %d", len(leafRlp), config.MaxLeafRlpHexLen)
+	}
+	if err == nil {
+		err = config.validateNodes(config.MaxDepth-1, key, keyFragmentStarts, nodeRlp, nodePathPrefixLength, nodeTypes)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	result, _ := checkMPTInclusionFixedKeyLength(api, config, maxValueLength, key, value, rootHash, keyFragmentStarts, leafRlp,
+		leafPathPrefixLength, nodeRlp, nodePathPrefixLength, nodeTypes, depth)
+	return result
+}
 
-	//maxLeafRLPLength := 4 + (keyLength + 2) + 4 + maxValueLength
-	maxBranchRlpHexLen := 1064
+// inclusionLayers exposes the per layer checks of CheckMPTInclusionFixedKeyLength to gadgets building on an
+// inclusion proof
+type inclusionLayers struct {
+	branchChecks    []MPTCheckResult      // [maxDepth - 1]
+	extensionChecks []MPTCheckResult      // [maxDepth - 1]
+	keyNibbles      []frontend.Variable   // [maxDepth - 1] branch nibble of every layer
+	nodeRefs        [][]frontend.Variable // [maxDepth - 1][64] child reference checked at every layer
+	leafHash        *rlp.KeccakOrLiteralHex
+}
+
+func checkMPTInclusionFixedKeyLength(
+	api frontend.API,
+	config MPTConfig,
+	maxValueLength int,
+	key []frontend.Variable,
+	value []frontend.Variable,
+	rootHash [64]frontend.Variable,
+	keyFragmentStarts []frontend.Variable,
+	leafRlp []frontend.Variable,
+	leafPathPrefixLength frontend.Variable,
+	nodeRlp [][]frontend.Variable,
+	nodePathPrefixLength []frontend.Variable,
+	nodeTypes []frontend.Variable,
+	depth frontend.Variable,
+) (CheckMPTInclusionFixedKeyLengthResult, inclusionLayers) {
+	maxDepth := config.MaxDepth
+	keyLength := config.KeyLength
+	maxBranchRlpHexLen := config.MaxBranchRlpHexLen
 	maxExtensionRlpHexLen := 4 + 2 + keyLength + 2 + 64
 
 	// KEY_BITS := LogCeil(keyLength)
@@ -48,7 +89,10 @@ func CheckMPTInclusionFixedKeyLength(
 	for index := 0; index < maxDepth-1; index++ {
 		isSingleKeyFragment = append(isSingleKeyFragment, rlp.Equal(api, api.Add(keyFragmentStarts[index], 1), keyFragmentStarts[index+1]))
 		isMonotoneStart = append(isMonotoneStart, rlp.LessThan(api, keyFragmentStarts[index], keyFragmentStarts[index+1]))
-		keyFragmentValidBranch = append(keyFragmentValidBranch, api.Or(isSingleKeyFragment[index], api.Sub(1, nodeTypes[index])))
+		// branch nodes consume exactly one key nibble, extension nodes consume their path length which is checked by CheckExtension,
+		// the leaf fragment runs up to the padded start of the next layer
+		isLeafLayer := rlp.Equal(api, depth, index+1)
+		keyFragmentValidBranch = append(keyFragmentValidBranch, api.Or(api.Or(isSingleKeyFragment[index], nodeTypes[index]), isLeafLayer))
 		isStartRange = append(isStartRange, rlp.LessThan(api, keyFragmentStarts[index], keyLength))
 	}
 
@@ -79,21 +123,17 @@ func CheckMPTInclusionFixedKeyLength(
 	}
 	leafStartMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, leafStartInput)
 
-	var leafSubArrayInput [64]frontend.Variable
-	for i := 0; i < 64; i++ {
-		leafSubArrayInput[i] = key[i]
-	}
+	leafSubArrayInput := key[:keyLength]
 
 	log.Info(leafSubArrayInput, leafStartMultiplexer[0], keyLength)
 
-	subArray := rlp.NewSubArray(64, 64, 7)
-	leafSelector, leafSelectorLength := subArray.SubArray(api, leafSubArrayInput[:], leafStartMultiplexer[0], keyLength)
+	subArray := rlp.NewSubArray(keyLength, keyLength, rlp.LogCeil(keyLength))
+	leafSelector, leafSelectorLength := subArray.SubArray(api, leafSubArrayInput, leafStartMultiplexer[0], keyLength)
 
 	leafCheck := NewMPTLeafCheck(keyLength, maxValueLength)
 	leafCheckResult := leafCheck.CheckLeaf(api, leafSelectorLength, leafSelector, value, leafRlp, leafPathPrefixLength)
 
-	leafRlpBlock := keccak.NibblesToU64Array(api, leafRlp[:])
-	leafHash := rlp.Keccak256AsNibbles(api, len(leafRlp), leafRlpBlock, leafRoundIndex)
+	leafHash := rlp.Keccak256Nibbles(api, leafRlp, leafCheckResult.result.rlpTotalLength)
 
 	log.Info("leafHash:", leafHash.Output)
 
@@ -106,12 +146,14 @@ func CheckMPTInclusionFixedKeyLength(
 		depthLessThan = append(depthLessThan, rlp.LessThan(api, i, depth))
 	}
 
-	//maxNodeRLPLength := 1064
-	// maxRounds := (maxNodeRLPLength + 272) / 272
-
 	var extensionCheckResults []MPTCheckResult // [maxDepth - 1]
 	var branchCheckResults []MPTCheckResult    // [maxDepth - 1]
 	var nodeHashes []rlp.KeccakOrLiteralHex    // [maxDepth - 1]
+	layers := inclusionLayers{
+		keyNibbles: make([]frontend.Variable, maxDepth-1),
+		nodeRefs:   make([][]frontend.Variable, maxDepth-1),
+		leafHash:   leafHash,
+	}
 	for i := 0; i < maxDepth-1; i++ {
 		extensionCheckResults = append(extensionCheckResults, MPTCheckResult{})
 		branchCheckResults = append(branchCheckResults, MPTCheckResult{})
@@ -119,12 +161,7 @@ func CheckMPTInclusionFixedKeyLength(
 	}
 
 	for layer := maxDepth - 2; layer >= 0; layer-- {
-		var keySubArrayInput [64]frontend.Variable
-		for i := 0; i < 64; i++ {
-			keySubArrayInput[i] = key[i]
-		}
-
-		keySelector, _ := subArray.SubArray(api, keySubArrayInput[:], keyFragmentStarts[layer], keyFragmentStarts[layer+1])
+		keySelector, _ := subArray.SubArray(api, key[:keyLength], keyFragmentStarts[layer], keyFragmentStarts[layer+1])
 
 		extensionCheck := NewMPTExtensionCheck(keyLength, 64)
 
@@ -169,8 +206,11 @@ func CheckMPTInclusionFixedKeyLength(
 			nibbleSelectorInput[0] = append(nibbleSelectorInput[0], key[i])
 		}
 		nibbleSelector := rlp.Multiplexer(api, api.Mul(depthLessThan[layer], keyFragmentStarts[layer]), 1, keyLength, nibbleSelectorInput)
+		layers.keyNibbles[layer] = nibbleSelector[0]
+		layers.nodeRefs[layer] = nodeRefs
 
 		branchCheck := NewMPTBranchCheck(64)
+		branchCheck.maxRLPLength = maxBranchRlpHexLen
 
 		var branchCheckNodeRlpAtCurrentLayer []frontend.Variable
 
@@ -200,11 +240,15 @@ func CheckMPTInclusionFixedKeyLength(
 				branchCheckResults[layer].rlpTotalLength,
 			)
 
-		nodeRlpBlock := keccak.NibblesToU64Array(api, nodeRlp[layer][:])
-		nodeHashes[layer] = *rlp.Keccak256AsNibbles(api, nodeHashInputLengthAtCurrentLayer, nodeRlpBlock, nodeRoundIndexes[layer])
+		nodeHashes[layer] = *rlp.Keccak256Nibbles(api, nodeRlp[layer], nodeHashInputLengthAtCurrentLayer)
 	}
 
-	rootHashCheck := rlp.ArrayEqual(api, rootHash[:], nodeHashes[0].Output[:], 64, 64)
+	// the root of a single leaf trie is the leaf itself
+	var rootNodeHash [64]frontend.Variable
+	for i := range rootNodeHash {
+		rootNodeHash[i] = api.Select(depthEqual[0], leafHash.Output[i], nodeHashes[0].Output[i])
+	}
+	rootHashCheck := rlp.ArrayEqual(api, rootHash[:], rootNodeHash[:], 64, 64)
 	log.Info(rootHashCheck, leafCheckResult.result.output, allFragmentsValid, extensionCheckResults, branchCheckResults)
 
 	var allCheckMultiplexerInput [][]frontend.Variable
@@ -231,57 +275,97 @@ func CheckMPTInclusionFixedKeyLength(
 
 	allCheckMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, allCheckMultiplexerInput)
 
+	layers.branchChecks = branchCheckResults
+	layers.extensionChecks = extensionCheckResults
 	return CheckMPTInclusionFixedKeyLengthResult{
 		Output:      rlp.Equal(api, allCheckMultiplexer[0], api.Add(api.Mul(depth, 4), 2)),
 		ValueLength: leafCheckResult.valueLength,
-	}
+	}, layers
 }
 
 func CheckMPTInclusionNoBranchTermination(
 	api frontend.API,
-	maxDepth int,
-	maxKeyHexLen int,
-	key []frontend.Variable, // [keyLength]
+	config MPTConfig,
+	key []frontend.Variable, // [config.KeyLength]
 	keyLength frontend.Variable,
 	rootHash [64]frontend.Variable, // Root hash should be 32-bytes long value. Divide it by 4-bits ===> 0xcf78 will be [c, f, 7, 8]
 	keyFragmentStarts []frontend.Variable, // [maxDepth]
 	publicLeafHash [2]frontend.Variable,
-	nodeRlp [][]frontend.Variable, // [maxDepth - 1][maxBranchRlpHexLen]
-	nodeRoundIndexes []frontend.Variable,
+	nodeRlp [][]frontend.Variable, // [maxDepth - 1][config.MaxBranchRlpHexLen]
 	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
 	nodeTypes []frontend.Variable, // [maxDepth - 1]
 	depth frontend.Variable,
 ) CheckMPTInclusionFixedKeyLengthResult {
-	api.AssertIsLessOrEqual(maxDepth, 10)
+	err := config.Validate()
+	if err == nil {
+		err = config.validateNodes(config.MaxDepth-1, key, keyFragmentStarts, nodeRlp, nodePathPrefixLength, nodeTypes)
+	}
+	if err != nil {
+		panic(err)
+	}
 
-	maxBranchRlpHexLen := 1064
+	nodeHash := func(layer int, rlpTotalLength frontend.Variable) rlp.KeccakOrLiteralHex {
+		return *rlp.Keccak256Nibbles(api, nodeRlp[layer], rlpTotalLength)
+	}
+	return checkMPTInclusionNoBranchTermination(api, config, key, keyLength, rootHash, keyFragmentStarts, publicLeafHash,
+		nodeRlp, nodePathPrefixLength, nodeTypes, depth, nodeHash)
+}
+
+// checkMPTInclusionNoBranchTermination checks the key path of CheckMPTInclusionNoBranchTermination. nodeHash
+// returns the hash of the node at layer given its rlp length decoded by the branch or extension check, so that
+// callers can share the hash of a node between several key paths.
+func checkMPTInclusionNoBranchTermination(
+	api frontend.API,
+	config MPTConfig,
+	key []frontend.Variable,
+	keyLength frontend.Variable,
+	rootHash [64]frontend.Variable,
+	keyFragmentStarts []frontend.Variable,
+	publicLeafHash [2]frontend.Variable,
+	nodeRlp [][]frontend.Variable,
+	nodePathPrefixLength []frontend.Variable,
+	nodeTypes []frontend.Variable,
+	depth frontend.Variable,
+	nodeHash func(layer int, rlpTotalLength frontend.Variable) rlp.KeccakOrLiteralHex,
+) CheckMPTInclusionFixedKeyLengthResult {
+	maxDepth := config.MaxDepth
+	maxKeyHexLen := config.KeyLength
+	maxBranchRlpHexLen := config.MaxBranchRlpHexLen
 	maxExtensionRlpHexLen := 4 + 2 + maxKeyHexLen + 2 + 64
 
 	var keyFragmentValidBranch []frontend.Variable // [maxDepth - 1]
 	var isSingleKeyFragment []frontend.Variable    // [maxDepth - 1]
 	var isMonotoneStart []frontend.Variable        // [maxDepth - 1]
 	var isStartRange []frontend.Variable           // [maxDepth]
+	var isLeafStartRange []frontend.Variable       // [maxDepth]
 
 	for index := 0; index < maxDepth-1; index++ {
 		isSingleKeyFragment = append(isSingleKeyFragment, rlp.Equal(api, api.Add(keyFragmentStarts[index], 1), keyFragmentStarts[index+1]))
 		isMonotoneStart = append(isMonotoneStart, rlp.LessThan(api, keyFragmentStarts[index], keyFragmentStarts[index+1]))
-		keyFragmentValidBranch = append(keyFragmentValidBranch, api.Or(isSingleKeyFragment[index], api.Sub(1, nodeTypes[index])))
+		// branch nodes consume exactly one key nibble, extension nodes consume their path length which is checked by CheckExtension,
+		// the leaf fragment runs up to the padded start of the next layer
+		isLeafLayer := rlp.Equal(api, depth, index+1)
+		keyFragmentValidBranch = append(keyFragmentValidBranch, api.Or(api.Or(isSingleKeyFragment[index], nodeTypes[index]), isLeafLayer))
 		isStartRange = append(isStartRange, rlp.LessThan(api, keyFragmentStarts[index], keyLength))
+		// the leaf path is empty when the nodes above consume the whole key, e.g. a transaction key under two branches
+		isLeafStartRange = append(isLeafStartRange, rlp.LessThan(api, keyFragmentStarts[index], api.Add(keyLength, 1)))
 	}
 
 	isStartRange = append(isStartRange, rlp.LessThan(api, keyFragmentStarts[maxDepth-1], keyLength))
+	isLeafStartRange = append(isLeafStartRange, rlp.LessThan(api, keyFragmentStarts[maxDepth-1], api.Add(keyLength, 1)))
 
+	// the fragments of a proof of depth i + 1 are valid when entry i is 3 * (i + 1), layer i being the leaf
 	var allFragmentsInput [][]frontend.Variable
 	var tmp frontend.Variable = 0
 	for i := 0; i < maxDepth-1; i++ {
 		if len(allFragmentsInput) == 0 {
 			allFragmentsInput = append(allFragmentsInput, []frontend.Variable{})
 		}
+		allFragmentsInput[0] = append(allFragmentsInput[0], api.Add(tmp, keyFragmentValidBranch[i], isMonotoneStart[i], isLeafStartRange[i]))
 		tmp = api.Add(tmp, keyFragmentValidBranch[i], isMonotoneStart[i], isStartRange[i])
-		allFragmentsInput[0] = append(allFragmentsInput[0], tmp)
 	}
 
-	tmp = api.Add(tmp, 2, isStartRange[maxDepth-1])
+	tmp = api.Add(tmp, 2, isLeafStartRange[maxDepth-1])
 	allFragmentsInput[0] = append(allFragmentsInput[0], tmp)
 
 	allFragmentsValidMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, allFragmentsInput)
@@ -296,12 +380,7 @@ func CheckMPTInclusionNoBranchTermination(
 	}
 	leafStartMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, leafStartInput)
 
-	var leafSubArrayInput [6]frontend.Variable
-	for i := 0; i < 6; i++ {
-		leafSubArrayInput[i] = key[i]
-	}
-
-	log.Info(leafSubArrayInput, leafStartMultiplexer[0], keyLength)
+	log.Info(key[:maxKeyHexLen], leafStartMultiplexer[0], keyLength)
 
 	subArray := rlp.NewSubArray(maxKeyHexLen, maxKeyHexLen, rlp.LogCeil(maxKeyHexLen))
 
@@ -316,9 +395,6 @@ func CheckMPTInclusionNoBranchTermination(
 		depthLessThan = append(depthLessThan, rlp.LessThan(api, i, depth))
 	}
 
-	//maxNodeRLPLength := 1064
-	// maxRounds := (maxNodeRLPLength + 272) / 272
-
 	var extensionCheckResults []MPTCheckResult // [maxDepth - 1]
 	var branchCheckResults []MPTCheckResult    // [maxDepth - 1]
 	var nodeHashes []rlp.KeccakOrLiteralHex    // [maxDepth - 1]
@@ -329,12 +405,7 @@ func CheckMPTInclusionNoBranchTermination(
 	}
 
 	for layer := maxDepth - 2; layer >= 0; layer-- {
-		var keySubArrayInput [6]frontend.Variable
-		for i := 0; i < 6; i++ {
-			keySubArrayInput[i] = key[i]
-		}
-
-		keySelector, _ := subArray.SubArray(api, keySubArrayInput[:], keyFragmentStarts[layer], keyFragmentStarts[layer+1])
+		keySelector, _ := subArray.SubArray(api, key[:maxKeyHexLen], keyFragmentStarts[layer], keyFragmentStarts[layer+1])
 
 		extensionCheck := NewMPTExtensionCheck(maxKeyHexLen, 64)
 
@@ -381,6 +452,7 @@ func CheckMPTInclusionNoBranchTermination(
 		nibbleSelector := rlp.Multiplexer(api, api.Mul(depthLessThan[layer], keyFragmentStarts[layer]), 1, maxKeyHexLen, nibbleSelectorInput)
 
 		branchCheck := NewMPTBranchCheck(64)
+		branchCheck.maxRLPLength = maxBranchRlpHexLen
 
 		var branchCheckNodeRlpAtCurrentLayer []frontend.Variable
 
@@ -410,11 +482,15 @@ func CheckMPTInclusionNoBranchTermination(
 				branchCheckResults[layer].rlpTotalLength,
 			)
 
-		nodeRlpBlock := keccak.NibblesToU64Array(api, nodeRlp[layer][:])
-		nodeHashes[layer] = *rlp.Keccak256AsNibbles(api, nodeHashInputLengthAtCurrentLayer, nodeRlpBlock, nodeRoundIndexes[layer])
+		nodeHashes[layer] = nodeHash(layer, nodeHashInputLengthAtCurrentLayer)
 	}
 
-	rootHashCheck := rlp.ArrayEqual(api, rootHash[:], nodeHashes[0].Output[:], 64, 64)
+	// the root of a single leaf trie is the leaf itself
+	var rootNodeHash [64]frontend.Variable
+	for i := range rootNodeHash {
+		rootNodeHash[i] = api.Select(depthEqual[0], leafHash[i], nodeHashes[0].Output[i])
+	}
+	rootHashCheck := rlp.ArrayEqual(api, rootHash[:], rootNodeHash[:], 64, 64)
 	log.Info("Inclusion Check:", rootHashCheck, allFragmentsValid, extensionCheckResults, branchCheckResults)
 
 	var allCheckMultiplexerInput [][]frontend.Variable
@@ -442,11 +518,158 @@ func CheckMPTInclusionNoBranchTermination(
 	allCheckMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, allCheckMultiplexerInput)
 
 	return CheckMPTInclusionFixedKeyLengthResult{
-		Output:      rlp.Equal(api, allCheckMultiplexer[0], api.Add(api.Mul(depth, 4), 1)),
+		Output:      rlp.Equal(api, allCheckMultiplexer[0], api.Add(api.Mul(depth, 4), 2)),
 		ValueLength: 0,
 	}
 }
 
+type CheckMPTExclusionFixedKeyLengthResult struct {
+	Output frontend.Variable
+}
+
+// CheckMPTExclusionFixedKeyLength checks that key is not part of the trie. Unlike the inclusion check, all
+// proof nodes including the terminal one are passed in nodeRlp. The first depth - 1 nodes are branch or
+// extension nodes along the key, the terminal node is either a branch node whose child at the next key nibble is
+// empty, or a leaf or extension node whose path diverges from the rest of the key. The node type of the terminal
+// node is 0 for branch, 1 for extension and 2 for leaf nodes.
+func CheckMPTExclusionFixedKeyLength(
+	api frontend.API,
+	config MPTConfig,
+	maxValueLength int,
+	key []frontend.Variable, // [config.KeyLength]
+	rootHash [64]frontend.Variable,
+	keyFragmentStarts []frontend.Variable, // [maxDepth]
+	nodeRlp [][]frontend.Variable, // [maxDepth][BranchNodeMaxBlockSize]
+	nodePathPrefixLength []frontend.Variable, // [maxDepth]
+	nodeTypes []frontend.Variable, // [maxDepth]
+	depth frontend.Variable,
+) CheckMPTExclusionFixedKeyLengthResult {
+	err := config.Validate()
+	if err == nil {
+		err = config.validateNodes(config.MaxDepth, key, keyFragmentStarts, nodeRlp, nodePathPrefixLength, nodeTypes)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	maxDepth := config.MaxDepth
+	keyLength := config.KeyLength
+	maxBranchRlpHexLen := config.MaxBranchRlpHexLen
+	maxExtensionRlpHexLen := 4 + 2 + keyLength + 2 + 64
+
+	subArray := rlp.NewSubArray(keyLength, keyLength, rlp.LogCeil(keyLength))
+	branchCheck := NewMPTBranchCheck(64)
+	branchCheck.maxRLPLength = maxBranchRlpHexLen
+	extensionCheck := NewMPTExtensionCheck(keyLength, 64)
+	divergenceCheck := NewMPTDivergenceCheck(keyLength, maxValueLength)
+
+	var depthEqual []frontend.Variable
+	var depthLessThan []frontend.Variable
+	var depthInRange frontend.Variable = 0
+	for i := 0; i < maxDepth; i++ {
+		depthEqual = append(depthEqual, rlp.Equal(api, depth, i+1))
+		depthLessThan = append(depthLessThan, rlp.LessThan(api, i, depth))
+		depthInRange = api.Add(depthInRange, depthEqual[i])
+	}
+
+	var nibbleSelectorInput [][]frontend.Variable
+	nibbleSelectorInput = append(nibbleSelectorInput, key[:keyLength])
+
+	nodeHashes := make([]rlp.KeccakOrLiteralHex, maxDepth)
+	var allLayersValid frontend.Variable = 0
+
+	for layer := maxDepth - 1; layer >= 0; layer-- {
+		isBranch := rlp.Equal(api, nodeTypes[layer], 0)
+		isExtension := rlp.Equal(api, nodeTypes[layer], 1)
+		isTerminal := depthEqual[layer]
+
+		/// Inner nodes reference the hash of the next node, the terminal branch node must have an empty child at the key nibble
+		var nodeRefLength frontend.Variable = 0
+		var nodeRefs []frontend.Variable
+		for i := 0; i < 64; i++ {
+			if layer == maxDepth-1 {
+				nodeRefs = append(nodeRefs, 0)
+			} else {
+				nodeRefs = append(nodeRefs, api.Mul(api.Sub(1, isTerminal), nodeHashes[layer+1].Output[i]))
+			}
+		}
+		if layer < maxDepth-1 {
+			nodeRefLength = api.Mul(api.Sub(1, isTerminal), nodeHashes[layer+1].OutputLength)
+		}
+
+		var branchCheckNodeRlpAtCurrentLayer []frontend.Variable
+		for i := 0; i < maxBranchRlpHexLen; i++ {
+			branchCheckNodeRlpAtCurrentLayer = append(branchCheckNodeRlpAtCurrentLayer, api.Mul(isBranch, nodeRlp[layer][i]))
+		}
+		nibbleSelector := rlp.Multiplexer(api, api.Mul(depthLessThan[layer], keyFragmentStarts[layer]), 1, keyLength, nibbleSelectorInput)
+		branchCheckResult := branchCheck.CheckBranch(api, nibbleSelector[0], nodeRefLength, nodeRefs, branchCheckNodeRlpAtCurrentLayer)
+		branchValid := rlp.Equal(api, branchCheckResult.output, 4)
+
+		var divergenceCheckNodeRlpAtCurrentLayer []frontend.Variable
+		for i := 0; i < divergenceCheck.maxRLPLength; i++ {
+			divergenceCheckNodeRlpAtCurrentLayer = append(divergenceCheckNodeRlpAtCurrentLayer, api.Sub(nodeRlp[layer][i], api.Mul(isBranch, nodeRlp[layer][i])))
+		}
+		keyRest, keyRestLength := subArray.SubArray(api, key[:keyLength], keyFragmentStarts[layer], keyLength)
+		divergenceCheckResult := divergenceCheck.CheckDivergence(
+			api,
+			keyRestLength,
+			keyRest,
+			divergenceCheckNodeRlpAtCurrentLayer,
+			nodePathPrefixLength[layer],
+			rlp.Equal(api, nodeTypes[layer], 2),
+		)
+		divergenceValid := rlp.Equal(api, divergenceCheckResult.output, 3)
+
+		terminalValid := api.Add(api.Mul(isBranch, api.Sub(branchValid, divergenceValid)), divergenceValid)
+		terminalValid = api.Mul(terminalValid, rlp.LessThan(api, keyFragmentStarts[layer], keyLength))
+		twoItemsRlpLength := divergenceCheckResult.rlpTotalLength
+
+		var isInner frontend.Variable = 0
+		var innerValid frontend.Variable = 0
+		if layer < maxDepth-1 {
+			keySelector, _ := subArray.SubArray(api, key[:keyLength], keyFragmentStarts[layer], keyFragmentStarts[layer+1])
+
+			var extensionCheckNodeRlpAtCurrentLayer []frontend.Variable
+			for i := 0; i < maxExtensionRlpHexLen; i++ {
+				extensionCheckNodeRlpAtCurrentLayer = append(extensionCheckNodeRlpAtCurrentLayer, api.Mul(isExtension, nodeRlp[layer][i]))
+			}
+			extensionCheckResult := extensionCheck.CheckExtension(
+				api,
+				api.Sub(keyFragmentStarts[layer+1], keyFragmentStarts[layer]),
+				keySelector,
+				nodeRefLength,
+				nodeRefs,
+				extensionCheckNodeRlpAtCurrentLayer,
+				nodePathPrefixLength[layer],
+			)
+			extensionValid := api.Mul(isExtension, rlp.Equal(api, extensionCheckResult.output, 4))
+
+			// branch nodes consume exactly one key nibble, extension nodes consume their path length which is checked by CheckExtension
+			isSingleKeyFragment := rlp.Equal(api, api.Add(keyFragmentStarts[layer], 1), keyFragmentStarts[layer+1])
+			isMonotoneStart := rlp.LessThan(api, keyFragmentStarts[layer], keyFragmentStarts[layer+1])
+			fragmentValid := api.Mul(api.Or(isSingleKeyFragment, isExtension), isMonotoneStart)
+
+			isInner = depthLessThan[layer+1]
+			innerValid = api.Mul(fragmentValid, api.Add(api.Mul(isBranch, branchValid), extensionValid))
+			twoItemsRlpLength = api.Add(api.Mul(isTerminal, api.Sub(divergenceCheckResult.rlpTotalLength, extensionCheckResult.rlpTotalLength)), extensionCheckResult.rlpTotalLength)
+		}
+
+		/// Layers after the terminal node are not checked
+		layerValid := api.Add(api.Mul(isInner, innerValid), api.Mul(isTerminal, terminalValid), api.Sub(1, api.Add(isInner, isTerminal)))
+		allLayersValid = api.Add(allLayersValid, layerValid)
+
+		nodeHashInputLengthAtCurrentLayer := api.Add(api.Mul(isBranch, api.Sub(branchCheckResult.rlpTotalLength, twoItemsRlpLength)), twoItemsRlpLength)
+		nodeHashes[layer] = *rlp.Keccak256Nibbles(api, nodeRlp[layer], nodeHashInputLengthAtCurrentLayer)
+	}
+
+	rootHashCheck := rlp.ArrayEqual(api, rootHash[:], nodeHashes[0].Output[:], 64, 64)
+	log.Info("Exclusion Check:", rootHashCheck, depthInRange, allLayersValid)
+
+	return CheckMPTExclusionFixedKeyLengthResult{
+		Output: rlp.Equal(api, api.Add(rootHashCheck, depthInRange, allLayersValid), maxDepth+2),
+	}
+}
+
 func Recompose32ByteToNibbles(api frontend.API, trunk [2]frontend.Variable) [64]frontend.Variable {
 	var trunkBits []frontend.Variable
 	for i := 0; i < 2; i++ {
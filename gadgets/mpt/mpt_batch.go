@@ -0,0 +1,144 @@
+package mpt
+
+import (
+	"fmt"
+
+	"github.com/celer-network/brevis-circuits/gadgets/rlp"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// CheckMPTBatchInclusionNoBranchTermination runs CheckMPTInclusionNoBranchTermination for several keys against
+// the same root. The branch and extension nodes of all proofs are passed once in nodeRlp and hashed once, each key
+// path references the node of every layer by its index in nodeRlp. Nodes shared by several paths, e.g. the root
+// and the upper branch nodes of a receipt trie, therefore cost one keccak instead of one per key. The index of a
+// layer below the proof depth is ignored.
+func CheckMPTBatchInclusionNoBranchTermination(
+	api frontend.API,
+	config MPTConfig,
+	keys [][]frontend.Variable, // [keyNum][config.KeyLength]
+	keyLengths []frontend.Variable, // [keyNum]
+	rootHash [64]frontend.Variable,
+	keyFragmentStarts [][]frontend.Variable, // [keyNum][maxDepth]
+	publicLeafHashes [][2]frontend.Variable, // [keyNum]
+	nodeRlp [][]frontend.Variable, // [maxNodes][config.MaxBranchRlpHexLen]
+	nodePathPrefixLength []frontend.Variable, // [maxNodes]
+	nodeTypes []frontend.Variable, // [maxNodes]
+	nodeIndexes [][]frontend.Variable, // [keyNum][maxDepth - 1]
+	depths []frontend.Variable, // [keyNum]
+) []CheckMPTInclusionFixedKeyLengthResult {
+	err := config.Validate()
+	if err == nil {
+		err = validateBatch(config, keys, keyLengths, keyFragmentStarts, publicLeafHashes, nodeRlp, nodeIndexes, depths)
+	}
+	for i := 0; err == nil && i < len(keys); i++ {
+		err = config.validateNodes(len(nodeRlp), keys[i], keyFragmentStarts[i], nodeRlp, nodePathPrefixLength, nodeTypes)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	maxDepth := config.MaxDepth
+	maxNodes := len(nodeRlp)
+	// the per layer checks only read the rlp prefix holding a branch or an extension node
+	maxCheckedRlpHexLen := config.MaxBranchRlpHexLen
+	if extensionHexLen := 4 + 2 + config.KeyLength + 2 + 64; extensionHexLen > maxCheckedRlpHexLen {
+		maxCheckedRlpHexLen = extensionHexLen
+	}
+
+	// hash every distinct node once over the length of its list prefix, whether the node is hashed or embedded is
+	// decided per path from the length decoded by the path's node check
+	var nodeHashes []*rlp.KeccakOrLiteralHex // [maxNodes]
+	for i := 0; i < maxNodes; i++ {
+		nodeHashes = append(nodeHashes, rlp.Keccak256Nibbles(api, nodeRlp[i], rlpListHexLen(api, nodeRlp[i])))
+	}
+
+	// node selector input, one row per selected value: the checked rlp nibbles, the path prefix length, the
+	// node type and the hash nibbles
+	var nodeSelectorInput [][]frontend.Variable
+	for i := 0; i < maxCheckedRlpHexLen+2+64; i++ {
+		nodeSelectorInput = append(nodeSelectorInput, make([]frontend.Variable, maxNodes))
+	}
+	for n := 0; n < maxNodes; n++ {
+		for i := 0; i < maxCheckedRlpHexLen; i++ {
+			nodeSelectorInput[i][n] = nodeRlp[n][i]
+		}
+		nodeSelectorInput[maxCheckedRlpHexLen][n] = nodePathPrefixLength[n]
+		nodeSelectorInput[maxCheckedRlpHexLen+1][n] = nodeTypes[n]
+		for i := 0; i < 64; i++ {
+			nodeSelectorInput[maxCheckedRlpHexLen+2+i][n] = nodeHashes[n].Output[i]
+		}
+	}
+
+	var results []CheckMPTInclusionFixedKeyLengthResult
+	for k := range keys {
+		var pathNodeRlp [][]frontend.Variable            // [maxDepth - 1][maxCheckedRlpHexLen]
+		var pathNodePathPrefixLength []frontend.Variable // [maxDepth - 1]
+		var pathNodeTypes []frontend.Variable            // [maxDepth - 1]
+		var pathNodeHashes [][]frontend.Variable         // [maxDepth - 1][64]
+		for layer := 0; layer < maxDepth-1; layer++ {
+			selected := rlp.Multiplexer(api, nodeIndexes[k][layer], len(nodeSelectorInput), maxNodes, nodeSelectorInput)
+			// layers below the proof depth hold an empty node, the same as in a single proof witness
+			isNodeLayer := rlp.LessThan(api, layer+1, depths[k])
+			for i := range selected {
+				selected[i] = api.Mul(isNodeLayer, selected[i])
+			}
+			pathNodeRlp = append(pathNodeRlp, selected[:maxCheckedRlpHexLen])
+			pathNodePathPrefixLength = append(pathNodePathPrefixLength, selected[maxCheckedRlpHexLen])
+			pathNodeTypes = append(pathNodeTypes, selected[maxCheckedRlpHexLen+1])
+			pathNodeHashes = append(pathNodeHashes, selected[maxCheckedRlpHexLen+2:])
+		}
+
+		nodeHash := func(layer int, rlpTotalLength frontend.Variable) rlp.KeccakOrLiteralHex {
+			var hash rlp.KeccakOrLiteralHex
+			copy(hash.Output[:], pathNodeHashes[layer])
+			// same as Keccak256Nibbles, nodes shorter than 32 bytes are embedded instead of hashed
+			isShort := rlp.LessThan(api, rlpTotalLength, 63)
+			hash.OutputLength = api.Add(api.Mul(isShort, api.Sub(rlpTotalLength, 64)), 64)
+			return hash
+		}
+		results = append(results, checkMPTInclusionNoBranchTermination(api, config, keys[k], keyLengths[k], rootHash,
+			keyFragmentStarts[k], publicLeafHashes[k], pathNodeRlp, pathNodePathPrefixLength, pathNodeTypes, depths[k], nodeHash))
+	}
+	return results
+}
+
+// rlpListHexLen returns the hex length of the rlp list at the start of node, prefix included, and 0 if node does not
+// start with a list prefix. Nodes are shorter than 64KB, so the length of a long list takes at most 2 bytes.
+func rlpListHexLen(api frontend.API, node []frontend.Variable) frontend.Variable {
+	isBig, prefixOrTotalHexLen, isValid := rlp.RlpArrayPrefix(api, [2]frontend.Variable{node[0], node[1]})
+	lengthByte := api.Add(api.Mul(node[2], 16), node[3])
+	lengthBytes := api.Add(api.Mul(lengthByte, 256), api.Mul(node[4], 16), node[5])
+	longLength := api.Select(rlp.Equal(api, prefixOrTotalHexLen, 4), lengthBytes, lengthByte)
+	hexLen := api.Add(2, prefixOrTotalHexLen, api.Mul(isBig, 2, longLength))
+	return api.Mul(isValid, hexLen)
+}
+
+// validateBatch checks that the per key inputs of a batch inclusion check have one entry per key
+func validateBatch(
+	config MPTConfig,
+	keys [][]frontend.Variable,
+	keyLengths []frontend.Variable,
+	keyFragmentStarts [][]frontend.Variable,
+	publicLeafHashes [][2]frontend.Variable,
+	nodeRlp [][]frontend.Variable,
+	nodeIndexes [][]frontend.Variable,
+	depths []frontend.Variable,
+) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("mpt config: batch without keys")
+	}
+	if len(nodeRlp) == 0 {
+		return fmt.Errorf("mpt config: batch without nodes")
+	}
+	if len(keyLengths) != len(keys) || len(keyFragmentStarts) != len(keys) || len(publicLeafHashes) != len(keys) ||
+		len(nodeIndexes) != len(keys) || len(depths) != len(keys) {
+		return fmt.Errorf("mpt config: batch per key inputs do not match %d keys", len(keys))
+	}
+	for i, indexes := range nodeIndexes {
+		if len(indexes) != config.MaxDepth-1 {
+			return fmt.Errorf("mpt config: key %d has %d node indexes, need %d", i, len(indexes), config.MaxDepth-1)
+		}
+	}
+	return nil
+}
@@ -57,7 +57,6 @@ import (
 // 	leafPathPrefixLength frontend.Variable,
 // 	nodeRlp [][]frontend.Variable, // [maxDepth - 1][maxBranchRlpHexLen]
 // 	nodeRlpBlock [keccak.MAX_ROUNDS][17]frontend.Variable,
-// 	nodeRoundIndex frontend.Variable,
 // 	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
 // 	nodeTypes []frontend.Variable, // [maxDepth - 1]
 // 	depth frontend.Variable,
@@ -68,10 +67,8 @@ type MPTInclusionCircuit struct {
 	RootHash             [64]frontend.Variable
 	KeyFragmentStarts    []frontend.Variable
 	LeafRlp              [272]frontend.Variable
-	LeafRlpRoundIndex    frontend.Variable
 	LeafPathPrefixLength frontend.Variable
 	NodeRlp              [MaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [MaxDepth - 1]frontend.Variable
 	NodePathPrefixLength []frontend.Variable
 	NodeTypes            []frontend.Variable
 	Depth                frontend.Variable
@@ -91,18 +88,15 @@ func (c *MPTInclusionCircuit) Define(api frontend.API) error {
 
 	result := CheckMPTInclusionFixedKeyLength(
 		api,
-		MaxDepth,
-		AccountKeyLength,
+		NewMPTConfig(MaxDepth, AccountKeyLength, StorageLeafMaxBlockHexLen),
 		MaxValueLengthForStorage,
 		c.Key,
 		c.Value,
 		c.RootHash,
 		c.KeyFragmentStarts,
 		c.LeafRlp[:],
-		c.LeafRlpRoundIndex,
 		c.LeafPathPrefixLength,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength,
 		c.NodeTypes,
 		c.Depth,
@@ -210,12 +204,8 @@ func Test_Storage_MPT_INCLUSION(t *testing.T) {
 	}
 
 	var nodeRlp [MaxDepth - 1][BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [MaxDepth - 1]frontend.Variable
 	nodeRlp[0] = paddedNodeRlp0Hex
-
-	nodeRlpRoundIndexes[0] = keccak.GetKeccakRoundIndex(len(nodeRlp0HexString) - 2)
 	nodeRlp[1] = paddedNodeRlp1Hex
-	nodeRlpRoundIndexes[1] = keccak.GetKeccakRoundIndex(len(nodeRlp1HexString) - 2)
 
 	for i := 2; i < MaxDepth-1; i++ {
 		var empty [1088]frontend.Variable
@@ -223,7 +213,6 @@ func Test_Storage_MPT_INCLUSION(t *testing.T) {
 			empty[j] = 0
 		}
 		nodeRlp[i] = empty
-		nodeRlpRoundIndexes[i] = 0
 	}
 
 	var nodePathPrefixLength [MaxDepth - 1]frontend.Variable
@@ -245,11 +234,9 @@ func Test_Storage_MPT_INCLUSION(t *testing.T) {
 		KeyFragmentStarts:    keyFragmentStarts[:],
 		LeafRlp:              paddedLeafRlpHex,
 		LeafPathPrefixLength: leafPathPrefixLength,
-		LeafRlpRoundIndex:    0,
 		NodeRlp:              nodeRlp,
 		NodePathPrefixLength: nodePathPrefixLength[:],
 		NodeTypes:            nodeTypes[:],
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
 		Depth:                depth,
 		Output:               output,
 		OutputValueLength:    valueHexLen,
@@ -271,10 +258,8 @@ type AccountMPTInclusionCircuit struct {
 	RootHash             [64]frontend.Variable
 	KeyFragmentStarts    []frontend.Variable
 	LeafRlp              [272 * 2]frontend.Variable
-	LeafRlpRoundIndex    frontend.Variable
 	LeafPathPrefixLength frontend.Variable
 	NodeRlp              [MaxDepth - 1][272 * 4]frontend.Variable
-	NodeRlpRoundIndexes  [MaxDepth - 1]frontend.Variable
 	NodePathPrefixLength []frontend.Variable
 	NodeTypes            []frontend.Variable
 	Depth                frontend.Variable
@@ -294,18 +279,15 @@ func (c *AccountMPTInclusionCircuit) Define(api frontend.API) error {
 
 	result := CheckMPTInclusionFixedKeyLength(
 		api,
-		MaxDepth,
-		AccountKeyLength,
+		NewMPTConfig(MaxDepth, AccountKeyLength, AccountLeafMaxBlockHexLen),
 		MaxValueLengthForAccount,
 		c.Key,
 		c.Value,
 		c.RootHash,
 		c.KeyFragmentStarts,
 		c.LeafRlp[:],
-		c.LeafRlpRoundIndex,
 		c.LeafPathPrefixLength,
 		nodeRlp,
-		c.NodeRlpRoundIndexes[:],
 		c.NodePathPrefixLength,
 		c.NodeTypes,
 		c.Depth,
@@ -379,8 +361,6 @@ func Test_Account_MPT_INCLUSION(t *testing.T) {
 		paddedLeafRlpHex[i] = 0
 	}
 
-	leafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(leafRlpHexString) - 2)
-
 	/// 64 - (depth - 1) ===> length of nibbles represented by branch/extension node
 	/// depth = len(accountProof[])/len(storageProof[])
 	var leafPathPrefixLength int // 20 67bce79287a24c5e8453dee4b1c363dfccc5960e98b02dc0f56374bf
@@ -405,7 +385,6 @@ func Test_Account_MPT_INCLUSION(t *testing.T) {
 	realDataLength := len(nodeRlpHexStrings)
 
 	var nodeRlp [MaxDepth - 1][BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [MaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [MaxDepth - 1]frontend.Variable
 	var nodeTypes [MaxDepth - 1]frontend.Variable
 
@@ -428,8 +407,6 @@ func Test_Account_MPT_INCLUSION(t *testing.T) {
 			}
 
 			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
 		} else {
 			// Add placeholder data
 			var empty [1088]frontend.Variable
@@ -437,7 +414,6 @@ func Test_Account_MPT_INCLUSION(t *testing.T) {
 				empty[j] = 0
 			}
 			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
 		}
 
 		// TODO: add support for extension node
@@ -454,11 +430,9 @@ func Test_Account_MPT_INCLUSION(t *testing.T) {
 		KeyFragmentStarts:    keyFragmentStarts[:],
 		LeafRlp:              paddedLeafRlpHex,
 		LeafPathPrefixLength: leafPathPrefixLength,
-		LeafRlpRoundIndex:    leafRlpRoundIndex,
 		NodeRlp:              nodeRlp,
 		NodePathPrefixLength: nodePathPrefixLength[:],
 		NodeTypes:            nodeTypes[:],
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
 		Depth:                depth,
 		Output:               output,
 		OutputValueLength:    valueHexLen,
@@ -536,8 +510,6 @@ func Test_Storage_MPT_with_Extension_INCLUSION(t *testing.T) {
 		paddedLeafRlpHex[i] = 0
 	}
 
-	leafRlpRoundIndex := keccak.GetKeccakRoundIndex(len(leafRlpHexString) - 2)
-
 	/// 64 - (depth - 1) ===> length of nibbles represented by branch/extension node
 	/// depth = len(accountProof[])/len(storageProof[])
 	var leafPathPrefixLength int // 20 67bce79287a24c5e8453dee4b1c363dfccc5960e98b02dc0f56374bf
@@ -562,7 +534,6 @@ func Test_Storage_MPT_with_Extension_INCLUSION(t *testing.T) {
 	realDataLength := len(nodeRlpHexStrings)
 
 	var nodeRlp [MaxDepth - 1][BranchNodeMaxBlockSize]frontend.Variable
-	var nodeRlpRoundIndexes [MaxDepth - 1]frontend.Variable
 	var nodePathPrefixLength [MaxDepth - 1]frontend.Variable
 	var nodeTypes [MaxDepth - 1]frontend.Variable
 
@@ -585,8 +556,6 @@ func Test_Storage_MPT_with_Extension_INCLUSION(t *testing.T) {
 			}
 
 			nodeRlp[i] = rlp
-
-			nodeRlpRoundIndexes[i] = keccak.GetKeccakRoundIndex(len(rlpHexString) - 2)
 		} else {
 			// Add placeholder data
 			var empty [1088]frontend.Variable
@@ -594,7 +563,6 @@ func Test_Storage_MPT_with_Extension_INCLUSION(t *testing.T) {
 				empty[j] = 0
 			}
 			nodeRlp[i] = empty
-			nodeRlpRoundIndexes[i] = 0
 		}
 
 		// TODO: add support for extension node
@@ -616,11 +584,9 @@ func Test_Storage_MPT_with_Extension_INCLUSION(t *testing.T) {
 		KeyFragmentStarts:    keyFragmentStarts[:],
 		LeafRlp:              paddedLeafRlpHex,
 		LeafPathPrefixLength: leafPathPrefixLength,
-		LeafRlpRoundIndex:    leafRlpRoundIndex,
 		NodeRlp:              nodeRlp,
 		NodePathPrefixLength: nodePathPrefixLength[:],
 		NodeTypes:            nodeTypes[:],
-		NodeRlpRoundIndexes:  nodeRlpRoundIndexes,
 		Depth:                depth,
 		Output:               output,
 		OutputValueLength:    valueHexLen,
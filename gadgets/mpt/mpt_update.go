@@ -0,0 +1,181 @@
+package mpt
+
+import (
+	"fmt"
+
+	"github.com/celer-network/brevis-circuits/gadgets/rlp"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+type CheckMPTUpdateFixedKeyLengthResult struct {
+	Output         frontend.Variable
+	OldValueLength frontend.Variable
+	NewValueLength frontend.Variable
+}
+
+// CheckMPTUpdateFixedKeyLength checks that key holds oldValue in the trie of oldRoot and that replacing it by
+// newValue turns the trie into the trie of newRoot. The proof nodes are the inclusion proof of the old value, the
+// new nodes are recomputed bottom up by replacing the child reference along the key with the hash of the updated
+// child. Only the leaf is witnessed again, newLeafRlp is the leaf holding newValue. Updates that change the shape of the trie,
+// e.g. a leaf or node shrinking below 32 bytes and getting embedded into its parent, are not supported and output 0.
+func CheckMPTUpdateFixedKeyLength(
+	api frontend.API,
+	config MPTConfig,
+	maxValueLength int,
+	key []frontend.Variable, // [config.KeyLength]
+	oldValue []frontend.Variable, // [maxValueLength]
+	newValue []frontend.Variable, // [maxValueLength]
+	oldRoot [64]frontend.Variable,
+	newRoot [64]frontend.Variable,
+	keyFragmentStarts []frontend.Variable, // [maxDepth]
+	oldLeafRlp []frontend.Variable, // [config.MaxLeafRlpHexLen]
+	newLeafRlp []frontend.Variable, // [config.MaxLeafRlpHexLen]
+	leafPathPrefixLength frontend.Variable,
+	nodeRlp [][]frontend.Variable, // [maxDepth - 1][config.MaxBranchRlpHexLen]
+	nodePathPrefixLength []frontend.Variable, // [maxDepth - 1]
+	nodeTypes []frontend.Variable, // [maxDepth - 1]
+	depth frontend.Variable,
+) CheckMPTUpdateFixedKeyLengthResult {
+	err := config.Validate()
+	if err == nil {
+		err = config.validateLeaf(maxValueLength)
+	}
+	if err == nil && (len(oldLeafRlp) != config.MaxLeafRlpHexLen || len(newLeafRlp) != config.MaxLeafRlpHexLen) {
+		err = fmt.Errorf("mpt config: leaf rlps have lengths %d and %d, need %d", len(oldLeafRlp), len(newLeafRlp), config.MaxLeafRlpHexLen)
+	}
+	if err == nil && len(newValue) != maxValueLength {
+		err = fmt.Errorf("mpt config: new value has length %d, need %d", len(newValue), maxValueLength)
+	}
+	if err == nil {
+		err = config.validateNodes(config.MaxDepth-1, key, keyFragmentStarts, nodeRlp, nodePathPrefixLength, nodeTypes)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	maxDepth := config.MaxDepth
+	keyLength := config.KeyLength
+	// child references are located within the rlp prefix holding a branch or an extension node
+	maxCheckedRlpHexLen := config.MaxBranchRlpHexLen
+	if extensionHexLen := 4 + 2 + keyLength + 2 + 64; extensionHexLen > maxCheckedRlpHexLen {
+		maxCheckedRlpHexLen = extensionHexLen
+	}
+
+	oldResult, layers := checkMPTInclusionFixedKeyLength(api, config, maxValueLength, key, oldValue, oldRoot, keyFragmentStarts,
+		oldLeafRlp, leafPathPrefixLength, nodeRlp, nodePathPrefixLength, nodeTypes, depth)
+
+	// the new leaf keeps the key path of the old leaf
+	var leafStartInput [][]frontend.Variable
+	leafStartInput = append(leafStartInput, keyFragmentStarts)
+	leafStart := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, leafStartInput)[0]
+	subArray := rlp.NewSubArray(keyLength, keyLength, rlp.LogCeil(keyLength))
+	leafSelector, leafSelectorLength := subArray.SubArray(api, key[:keyLength], leafStart, keyLength)
+	leafCheck := NewMPTLeafCheck(keyLength, maxValueLength)
+	newLeafCheckResult := leafCheck.CheckLeaf(api, leafSelectorLength, leafSelector, newValue, newLeafRlp, leafPathPrefixLength)
+	newLeafLength := newLeafCheckResult.result.rlpTotalLength
+	newLeafHash := rlp.Keccak256Nibbles(api, newLeafRlp, newLeafLength)
+	newLeafValid := rlp.Equal(api, api.Add(newLeafCheckResult.result.output, rlp.Equal(api, newLeafHash.OutputLength, 64)), 5)
+
+	var depthEqual []frontend.Variable
+	for i := 0; i < maxDepth; i++ {
+		depthEqual = append(depthEqual, rlp.Equal(api, depth, i+1))
+	}
+
+	newNodeHashes := make([]*rlp.KeccakOrLiteralHex, maxDepth-1)
+	childRefsHashed := make([]frontend.Variable, maxDepth-1)
+	for layer := maxDepth - 2; layer >= 0; layer-- {
+		isNodeLayer := rlp.LessThan(api, layer+1, depth)
+
+		var newChildHash []frontend.Variable
+		for i := 0; i < 64; i++ {
+			if layer == maxDepth-2 {
+				newChildHash = append(newChildHash, api.Mul(depthEqual[layer+1], newLeafHash.Output[i]))
+			} else {
+				diff := api.Sub(newLeafHash.Output[i], newNodeHashes[layer+1].Output[i])
+				newChildHash = append(newChildHash, api.Add(api.Mul(depthEqual[layer+1], diff), newNodeHashes[layer+1].Output[i]))
+			}
+		}
+
+		childRefOffset, childRefLength := childRefPosition(api, nodeRlp[layer], nodeTypes[layer], layers.keyNibbles[layer],
+			layers.branchChecks[layer], layers.extensionChecks[layer])
+		childRefsHashed[layer] = rlp.Equal(api, childRefLength, 64)
+
+		// replace the old child reference checked by the inclusion proof with the new one
+		refDiff := make([]frontend.Variable, maxCheckedRlpHexLen)
+		for i := range refDiff {
+			refDiff[i] = 0
+			if i < 64 {
+				refDiff[i] = api.Sub(newChildHash[i], layers.nodeRefs[layer][i])
+			}
+		}
+		shift := api.Mul(isNodeLayer, childRefOffset)
+		refDiff = rlp.ShiftRight(api, maxCheckedRlpHexLen, rlp.LogCeil(maxCheckedRlpHexLen), refDiff, shift)
+
+		newNodeRlp := make([]frontend.Variable, len(nodeRlp[layer]))
+		for i := range newNodeRlp {
+			newNodeRlp[i] = nodeRlp[layer][i]
+			if i < maxCheckedRlpHexLen {
+				newNodeRlp[i] = api.Add(nodeRlp[layer][i], refDiff[i])
+			}
+		}
+
+		// the node keeps its length
+		branchLength := layers.branchChecks[layer].rlpTotalLength
+		nodeLength := api.Add(api.Mul(nodeTypes[layer], api.Sub(layers.extensionChecks[layer].rlpTotalLength, branchLength)), branchLength)
+		newNodeHashes[layer] = rlp.Keccak256Nibbles(api, newNodeRlp, nodeLength)
+	}
+
+	// every node above the leaf references its child by hash
+	var childRefsInput [][]frontend.Variable
+	childRefsInput = append(childRefsInput, []frontend.Variable{0})
+	for layer := 0; layer < maxDepth-1; layer++ {
+		childRefsInput[0] = append(childRefsInput[0], api.Add(childRefsInput[0][layer], childRefsHashed[layer]))
+	}
+	childRefsMultiplexer := rlp.Multiplexer(api, api.Sub(depth, 1), 1, maxDepth, childRefsInput)
+	allChildRefsHashed := rlp.Equal(api, childRefsMultiplexer[0], api.Sub(depth, 1))
+
+	newRootCheck := rlp.ArrayEqual(api, newRoot[:], newNodeHashes[0].Output[:], 64, 64)
+
+	return CheckMPTUpdateFixedKeyLengthResult{
+		Output:         rlp.Equal(api, api.Add(oldResult.Output, newLeafValid, allChildRefsHashed, newRootCheck), 4),
+		OldValueLength: oldResult.ValueLength,
+		NewValueLength: newLeafCheckResult.valueLength,
+	}
+}
+
+// childRefPosition returns the nibble offset and the length of the child reference at keyNibble of a branch node,
+// or of the child reference of an extension node
+func childRefPosition(
+	api frontend.API,
+	nodeRlp []frontend.Variable,
+	nodeType frontend.Variable,
+	keyNibble frontend.Variable,
+	branchCheck MPTCheckResult,
+	extensionCheck MPTCheckResult,
+) (offset frontend.Variable, length frontend.Variable) {
+	isBig, prefixOrTotalHexLen, _ := rlp.RlpArrayPrefix(api, [2]frontend.Variable{nodeRlp[0], nodeRlp[1]})
+	arrayPrefixHexLen := api.Add(2, api.Mul(isBig, prefixOrTotalHexLen))
+
+	// branch children are empty strings or 32 byte hashes, both with a one byte prefix
+	var childOffsetInput [][]frontend.Variable
+	var childLengthInput [][]frontend.Variable
+	childOffsetInput = append(childOffsetInput, []frontend.Variable{})
+	childLengthInput = append(childLengthInput, []frontend.Variable{})
+	var childStart frontend.Variable = arrayPrefixHexLen
+	for i := 0; i < 16; i++ {
+		childOffsetInput[0] = append(childOffsetInput[0], api.Add(childStart, 2))
+		childLengthInput[0] = append(childLengthInput[0], branchCheck.fieldsLength[i])
+		childStart = api.Add(childStart, 2, branchCheck.fieldsLength[i])
+	}
+	branchOffset := rlp.Multiplexer(api, keyNibble, 1, 16, childOffsetInput)[0]
+	branchLength := rlp.Multiplexer(api, keyNibble, 1, 16, childLengthInput)[0]
+
+	// the child reference is the last field of an extension node
+	extensionOffset := api.Sub(extensionCheck.rlpTotalLength, 64)
+	extensionLength := extensionCheck.fieldsLength[1]
+
+	offset = api.Add(api.Mul(nodeType, api.Sub(extensionOffset, branchOffset)), branchOffset)
+	length = api.Add(api.Mul(nodeType, api.Sub(extensionLength, branchLength)), branchLength)
+	return
+}
@@ -15,6 +15,7 @@ type MPTTwoItemsNodeCircuit struct {
 type MPTCheckResult struct {
 	output         frontend.Variable
 	rlpTotalLength frontend.Variable
+	fieldsLength   []frontend.Variable // decoded hex length of every rlp field
 }
 
 func (mr MPTCheckResult) GetOutput() frontend.Variable {
@@ -185,6 +186,74 @@ func (extensionCheck *MPTExtensionCheck) CheckExtension(
 	return MPTCheckResult{
 		output:         api.Add(rlpout, prefixCheck, keyPass, nodeRefPass),
 		rlpTotalLength: totalRlpLength,
+		fieldsLength:   fieldsLength,
+	}
+}
+
+// MPTDivergenceCheck checks the terminal leaf or extension node of an exclusion proof, whose path must not be
+// a prefix of the remaining key nibbles
+type MPTDivergenceCheck struct {
+	maxKeyLength            int
+	maxValueLength          int
+	maxRLPLength            int
+	maxRLPArrayPrefixLength int
+}
+
+func NewMPTDivergenceCheck(maxKeyLength int, maxValueLength int) MPTDivergenceCheck {
+	divergenceCheck := &MPTDivergenceCheck{}
+	divergenceCheck.maxKeyLength = maxKeyLength
+	// the second item is either a leaf value or the child reference of an extension node
+	divergenceCheck.maxValueLength = maxValueLength
+	if divergenceCheck.maxValueLength < 64 {
+		divergenceCheck.maxValueLength = 64
+	}
+	divergenceCheck.maxRLPLength = 4 + (maxKeyLength + 2) + 4 + divergenceCheck.maxValueLength
+	leafBits := rlp.LogCeil(divergenceCheck.maxRLPLength)
+	divergenceCheck.maxRLPArrayPrefixLength = 2 * int(leafBits/8+1)
+	return *divergenceCheck
+}
+
+func (divergenceCheck *MPTDivergenceCheck) CheckDivergence(
+	api frontend.API,
+	keyNibbleLen frontend.Variable,
+	keyNibbles []frontend.Variable,
+	nodeRlp []frontend.Variable,
+	nodePathPrefixLength frontend.Variable,
+	isLeaf frontend.Variable,
+) MPTCheckResult {
+	log.Info("divergence check params: ", divergenceCheck.maxKeyLength, divergenceCheck.maxValueLength, keyNibbleLen, keyNibbles, nodeRlp, nodePathPrefixLength, isLeaf)
+
+	arrayCheck := &rlp.ArrayCheck{}
+	arrayCheck.MaxHexLen = divergenceCheck.maxRLPLength
+	arrayCheck.MaxFields = 2
+	arrayCheck.ArrayPrefixMaxHexLen = divergenceCheck.maxRLPArrayPrefixLength
+	arrayCheck.FieldMinHexLen = []int{0, 0}
+	arrayCheck.FieldMaxHexLen = []int{divergenceCheck.maxKeyLength + 2, divergenceCheck.maxValueLength}
+
+	rlpout, totalRlpLength, fieldsLength, fields := arrayCheck.RlpArrayCheck(api, nodeRlp)
+
+	/// Leaf flags are 3 (odd) and 2 0 (even), extension flags are 1 (odd) and 0 0 (even)
+	oddLengthPrefix := rlp.Equal(api, nodePathPrefixLength, 1)
+	oddPath := rlp.Equal(api, fields[0][0], api.Add(api.Mul(isLeaf, 2), 1))
+
+	evenLengthPrefix := rlp.Equal(api, nodePathPrefixLength, 2)
+	firstPrefixForEven := rlp.Equal(api, fields[0][0], api.Mul(isLeaf, 2))
+	secondPrefixForEven := rlp.Equal(api, fields[0][1], 0)
+
+	prefixCheck := api.Add(api.Mul(oddLengthPrefix, oddPath), api.Mul(evenLengthPrefix, firstPrefixForEven, secondPrefixForEven))
+
+	pathNibbles := rlp.ShiftLeft(api, divergenceCheck.maxRLPLength, 0, 2, fields[0], nodePathPrefixLength)
+	pathLength := api.Sub(fieldsLength[0], nodePathPrefixLength)
+
+	// the key is excluded as soon as one nibble of the path differs from the key, or the path is longer than the key
+	pathMatched := rlp.ArrayEqual(api, keyNibbles, pathNibbles, divergenceCheck.maxKeyLength, pathLength)
+	pathFits := api.Sub(1, rlp.LessThan(api, keyNibbleLen, pathLength))
+	diverged := api.Sub(1, api.Mul(pathMatched, pathFits))
+
+	log.Info("Divergence check result: ", rlpout, prefixCheck, diverged)
+	return MPTCheckResult{
+		output:         api.Add(rlpout, prefixCheck, diverged),
+		rlpTotalLength: totalRlpLength,
 	}
 }
 
@@ -254,5 +323,6 @@ func (branchCheck *MPTBranchCheck) CheckBranch(
 	return MPTCheckResult{
 		output:         api.Add(rlpout, nodeRefCheck, nodeRefLengthCheck, 1),
 		rlpTotalLength: totalRlpLength,
+		fieldsLength:   fieldsLength,
 	}
 }
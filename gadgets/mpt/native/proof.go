@@ -0,0 +1,48 @@
+// Package native computes the mpt gadgets out of circuit
+package native
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// VerifyProof returns the value at key in the trie of root, proven by the rlp nodes of proof, from the root down
+// to the leaf. This is what the inclusion gadgets check, key being the path in the trie, e.g. the keccak of an
+// account address or a storage slot, and the value the rlp in the leaf.
+func VerifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	value, err := verifyProof(root, key, proof)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("mpt: key %x is not in the trie", key)
+	}
+	return value, nil
+}
+
+// VerifyExclusionProof returns nil when proof shows that key is not in the trie of root, what the exclusion
+// gadgets check
+func VerifyExclusionProof(root common.Hash, key []byte, proof [][]byte) error {
+	value, err := verifyProof(root, key, proof)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		return fmt.Errorf("mpt: key %x is in the trie", key)
+	}
+	return nil
+}
+
+func verifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return nil, err
+		}
+	}
+	return trie.VerifyProof(root, key, db)
+}
@@ -0,0 +1,205 @@
+package witness
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/common"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// The fuzz tests below check the mpt gadgets against the geth trie: the proof of a random trie must solve the
+// gadget, and a proof with a tampered byte, rejected by trie.VerifyProof, must not. The seed corpus runs with
+// go test, more inputs with e.g. go test -run XXX -fuzz FuzzMPTInclusionFixedKeyLength -fuzztime 1m.
+
+const (
+	fuzzMaxKeyNum = 64
+	fuzzMaxTxNum  = 300
+	// the transaction trie keys are the rlp encoded indexes, up to 3 bytes
+	txKeyLength = 6
+	txMaxDepth  = 6
+)
+
+type txInclusionCircuit struct {
+	Key                  [txKeyLength]frontend.Variable
+	KeyLength            frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    [txMaxDepth]frontend.Variable
+	LeafHash             [2]frontend.Variable
+	NodeRlp              [txMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [txMaxDepth - 1]frontend.Variable
+	NodeTypes            [txMaxDepth - 1]frontend.Variable
+	Depth                frontend.Variable
+}
+
+func (c *txInclusionCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	result := mpt.CheckMPTInclusionNoBranchTermination(
+		api, mpt.NewMPTConfig(txMaxDepth, txKeyLength, 0), c.Key[:], c.KeyLength, c.RootHash, c.KeyFragmentStarts[:],
+		c.LeafHash, nodeRlp, c.NodePathPrefixLength[:], c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+// tamper flips the bits of flip in a byte of a proof node, picked by node and pos, and returns the tampered copy
+// of the nodes with the index of the tampered node
+func tamper(nodes [][]byte, node uint8, pos uint16, flip byte) ([][]byte, int) {
+	if flip == 0 {
+		flip = 1
+	}
+	tampered := make([][]byte, len(nodes))
+	copy(tampered, nodes)
+	i := int(node) % len(nodes)
+	tampered[i] = append([]byte{}, nodes[i]...)
+	tampered[i][int(pos)%len(nodes[i])] ^= flip
+	return tampered, i
+}
+
+func FuzzMPTInclusionFixedKeyLength(f *testing.F) {
+	f.Add(int64(1), uint8(32), uint8(0), uint16(0), byte(0x80))
+	f.Add(int64(2), uint8(3), uint8(1), uint16(40), byte(0x01))
+	f.Add(int64(3), uint8(63), uint8(2), uint16(7), byte(0xff))
+	// a single key, the root is the leaf
+	f.Add(int64(4), uint8(0), uint8(0), uint16(5), byte(0x10))
+	f.Fuzz(func(t *testing.T, seed int64, keyNum uint8, node uint8, pos uint16, flip byte) {
+		rng := rand.New(rand.NewSource(seed))
+		tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+		var keys [][]byte
+		for i := 0; i < 1+int(keyNum)%fuzzMaxKeyNum; i++ {
+			k := randtest.Bytes(rng, 32)
+			v, err := rlp.EncodeToBytes(randtest.Bytes(rng, 1+rng.Intn(32)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Update(k, v)
+			keys = append(keys, k)
+		}
+		root := tr.Hash()
+		k := keys[rng.Intn(len(keys))]
+		proofWriter := &common.ProofWriter{}
+		if err := tr.Prove(k, 0, proofWriter); err != nil {
+			t.Fatal(err)
+		}
+		if len(proofWriter.Values) > testMaxDepth {
+			t.Skipf("proof of depth %d", len(proofWriter.Values))
+		}
+
+		v, err := native.VerifyProof(root, k, proofWriter.Values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewProof(proofWriter.Values, BytesToNibbles(k), 64, testMaxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := newInclusionAssignment(p, k, root.Bytes(), testMaxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(w.Value[:], NibblesToVariables(BytesToNibbles(v), 66))
+		if err := test.IsSolved(newInclusionCircuit(testMaxDepth), w, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("valid proof rejected: %v", err)
+		}
+
+		// the tampered node replaces the original one in the witness, the node layout is kept
+		tampered, i := tamper(proofWriter.Values, node, pos, flip)
+		if _, err := native.VerifyProof(root, k, tampered); err == nil {
+			t.Fatal("tampered proof accepted by the trie")
+		}
+		if i == p.Depth-1 {
+			leafRlp, err := PaddedNibbles(tampered[i], len(w.LeafRlp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			copy(w.LeafRlp[:], leafRlp)
+		} else {
+			nodeRlp, err := PaddedNibbles(tampered[i], mpt.BranchNodeMaxBlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			copy(w.NodeRlp[i][:], nodeRlp)
+		}
+		if err := test.IsSolved(newInclusionCircuit(testMaxDepth), w, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("proof with node %d tampered accepted", i)
+		}
+	})
+}
+
+func FuzzMPTInclusionNoBranchTermination(f *testing.F) {
+	f.Add(int64(1), uint16(40), uint8(0), uint16(0), byte(0x80))
+	f.Add(int64(2), uint16(2), uint8(1), uint16(3), byte(0x01))
+	f.Add(int64(3), uint16(fuzzMaxTxNum-1), uint8(2), uint16(100), byte(0xff))
+	// a single transaction, the root is the leaf
+	f.Add(int64(4), uint16(0), uint8(0), uint16(5), byte(0x10))
+	f.Fuzz(func(t *testing.T, seed int64, txNum uint16, node uint8, pos uint16, flip byte) {
+		rng := rand.New(rand.NewSource(seed))
+		// a transaction trie keyed by the rlp encoded index, as built by common.GetTransactionProof
+		tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+		n := 1 + int(txNum)%fuzzMaxTxNum
+		for i := 0; i < n; i++ {
+			tr.Update(rlp.AppendUint64(nil, uint64(i)), randtest.Bytes(rng, 32+rng.Intn(100)))
+		}
+		root := tr.Hash()
+		k := rlp.AppendUint64(nil, uint64(rng.Intn(n)))
+		proofWriter := &common.ProofWriter{}
+		if err := tr.Prove(k, 0, proofWriter); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := native.VerifyProof(root, k, proofWriter.Values); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := NewProof(proofWriter.Values, BytesToNibbles(k), txKeyLength, txMaxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafHash := crypto.Keccak256(p.Leaf.Rlp)
+		w := &txInclusionCircuit{
+			KeyLength: 2 * len(k),
+			LeafHash:  [2]frontend.Variable{leafHash[:16], leafHash[16:]},
+			Depth:     p.Depth,
+		}
+		copy(w.Key[:], NibblesToVariables(BytesToNibbles(k), txKeyLength))
+		copy(w.RootHash[:], NibblesToVariables(BytesToNibbles(root.Bytes()), 64))
+		copy(w.KeyFragmentStarts[:], p.KeyFragmentStarts)
+		for i := range w.NodeRlp {
+			copy(w.NodeRlp[i][:], p.NodeRlp[i])
+		}
+		copy(w.NodePathPrefixLength[:], p.NodePathPrefixLength)
+		copy(w.NodeTypes[:], p.NodeTypes)
+		if err := test.IsSolved(&txInclusionCircuit{}, w, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("valid proof of key %x rejected: %v", k, err)
+		}
+
+		tampered, i := tamper(proofWriter.Values, node, pos, flip)
+		if _, err := native.VerifyProof(root, k, tampered); err == nil {
+			t.Fatal("tampered proof accepted by the trie")
+		}
+		if i == len(tampered)-1 {
+			// the leaf is only bound through its public hash
+			leafHash = crypto.Keccak256(tampered[i])
+			w.LeafHash = [2]frontend.Variable{leafHash[:16], leafHash[16:]}
+		} else {
+			nodeRlp, err := PaddedNibbles(tampered[i], mpt.BranchNodeMaxBlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			copy(w.NodeRlp[i][:], nodeRlp)
+		}
+		if err := test.IsSolved(&txInclusionCircuit{}, w, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("proof of key %x with node %d tampered accepted", k, i)
+		}
+	})
+}
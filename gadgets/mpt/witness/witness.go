@@ -0,0 +1,338 @@
+// Package witness prepares the off-circuit assignments of the mpt gadgets from raw proof nodes, as returned
+// by eth_getProof or trie.Prove.
+package witness
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/consensys/gnark/frontend"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	BranchNode    = 0
+	ExtensionNode = 1
+	LeafNode      = 2
+)
+
+// Node is a decoded mpt proof node
+type Node struct {
+	Type int
+	// key nibbles of an extension or leaf node, without the hex prefix flag nibbles
+	Path []byte
+	// number of hex prefix flag nibbles preceding the path, 2 for even length paths and 1 for odd ones.
+	// always 0 for branch nodes
+	PathPrefixLength int
+	// value of a leaf node
+	Value []byte
+	// the 16 child references and the value of a branch node, empty children are encoded as 0x80
+	Children []rlp.RawValue
+	Rlp      []byte
+}
+
+func DecodeNode(nodeRlp []byte) (*Node, error) {
+	var items []rlp.RawValue
+	if err := rlp.DecodeBytes(nodeRlp, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode node: %s", err.Error())
+	}
+	node := &Node{Rlp: nodeRlp}
+	switch len(items) {
+	case 17:
+		node.Type = BranchNode
+		node.Children = items
+		return node, nil
+	case 2:
+	default:
+		return nil, fmt.Errorf("invalid node with %d items", len(items))
+	}
+
+	var compact []byte
+	if err := rlp.DecodeBytes(items[0], &compact); err != nil || len(compact) == 0 {
+		return nil, fmt.Errorf("invalid node path")
+	}
+	flag := compact[0] >> 4
+	if flag > 3 {
+		return nil, fmt.Errorf("invalid node path flag %d", flag)
+	}
+	if flag%2 == 1 {
+		node.PathPrefixLength = 1
+	} else {
+		if compact[0]&0x0F != 0 {
+			return nil, fmt.Errorf("invalid even length node path")
+		}
+		node.PathPrefixLength = 2
+	}
+	node.Path = BytesToNibbles(compact)[node.PathPrefixLength:]
+
+	if flag < 2 {
+		node.Type = ExtensionNode
+		return node, nil
+	}
+	node.Type = LeafNode
+	if err := rlp.DecodeBytes(items[1], &node.Value); err != nil {
+		return nil, fmt.Errorf("failed to decode leaf value: %s", err.Error())
+	}
+	return node, nil
+}
+
+// Proof is the per layer witness of an mpt inclusion proof, laid out for CheckMPTInclusionFixedKeyLength and
+// CheckMPTInclusionNoBranchTermination
+type Proof struct {
+	KeyFragmentStarts    []frontend.Variable   // [maxDepth]
+	NodeRlp              [][]frontend.Variable // [maxDepth - 1][mpt.BranchNodeMaxBlockSize]
+	NodePathPrefixLength []frontend.Variable   // [maxDepth - 1]
+	NodeTypes            []frontend.Variable   // [maxDepth - 1]
+	Depth                int
+	Leaf                 *Node
+}
+
+// NewProof walks the proof nodes along the key nibbles. Every branch node consumes one nibble and every
+// extension node consumes its path, the remaining nibbles must match the path of the leaf. Unused key fragment
+// starts are filled with maxKeyLength.
+func NewProof(nodes [][]byte, key []byte, maxKeyLength int, maxDepth int) (*Proof, error) {
+	depth := len(nodes)
+	if depth == 0 {
+		return nil, fmt.Errorf("empty proof")
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("proof depth %d exceeds max depth %d", depth, maxDepth)
+	}
+	if len(key) > maxKeyLength {
+		return nil, fmt.Errorf("key length %d exceeds max key length %d", len(key), maxKeyLength)
+	}
+
+	p := &Proof{Depth: depth}
+	start := 0
+	for i := 0; i < depth-1; i++ {
+		node, err := DecodeNode(nodes[i])
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+		nodeRlp, err := PaddedNibbles(nodes[i], mpt.BranchNodeMaxBlockSize)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+		p.KeyFragmentStarts = append(p.KeyFragmentStarts, start)
+		p.NodeRlp = append(p.NodeRlp, nodeRlp)
+		p.NodePathPrefixLength = append(p.NodePathPrefixLength, node.PathPrefixLength)
+		p.NodeTypes = append(p.NodeTypes, node.Type)
+
+		switch node.Type {
+		case BranchNode:
+			if start >= len(key) {
+				return nil, fmt.Errorf("branch node %d after the key is fully consumed", i)
+			}
+			start++
+		case ExtensionNode:
+			end := start + len(node.Path)
+			if end > len(key) || !bytes.Equal(node.Path, key[start:end]) {
+				return nil, fmt.Errorf("extension node %d does not match the key", i)
+			}
+			start = end
+		default:
+			return nil, fmt.Errorf("node %d is a leaf but is not the last node of the proof", i)
+		}
+	}
+
+	leaf, err := DecodeNode(nodes[depth-1])
+	if err != nil {
+		return nil, fmt.Errorf("leaf: %s", err.Error())
+	}
+	if leaf.Type != LeafNode {
+		return nil, fmt.Errorf("last proof node is not a leaf")
+	}
+	if !bytes.Equal(leaf.Path, key[start:]) {
+		return nil, fmt.Errorf("leaf path does not match the key")
+	}
+	p.Leaf = leaf
+	p.KeyFragmentStarts = append(p.KeyFragmentStarts, start)
+
+	for i := depth; i < maxDepth; i++ {
+		p.KeyFragmentStarts = append(p.KeyFragmentStarts, maxKeyLength)
+	}
+	for i := depth - 1; i < maxDepth-1; i++ {
+		p.NodeRlp = append(p.NodeRlp, NibblesToVariables(nil, mpt.BranchNodeMaxBlockSize))
+		p.NodePathPrefixLength = append(p.NodePathPrefixLength, 0)
+		p.NodeTypes = append(p.NodeTypes, 0)
+	}
+	return p, nil
+}
+
+// BatchProof is the witness of several inclusion proofs against the same root, laid out for
+// CheckMPTBatchInclusionNoBranchTermination. Branch and extension nodes shared by several proofs are stored once.
+type BatchProof struct {
+	Proofs               []*Proof              // per key witness, only the key fragment starts, depth and leaf are used
+	NodeRlp              [][]frontend.Variable // [maxNodes][mpt.BranchNodeMaxBlockSize]
+	NodePathPrefixLength []frontend.Variable   // [maxNodes]
+	NodeTypes            []frontend.Variable   // [maxNodes]
+	NodeIndexes          [][]frontend.Variable // [len(proofs)][maxDepth - 1]
+	NodeNum              int                   // number of distinct nodes
+}
+
+// NewBatchProof prepares the witness of the proofs of keys, see NewProof, storing every distinct node once. Layers
+// below a proof's depth reference node 0, unused node slots hold empty nodes.
+func NewBatchProof(proofs [][][]byte, keys [][]byte, maxKeyLength int, maxDepth int, maxNodes int) (*BatchProof, error) {
+	if len(proofs) != len(keys) {
+		return nil, fmt.Errorf("%d proofs for %d keys", len(proofs), len(keys))
+	}
+	b := &BatchProof{}
+	nodeIndexes := make(map[string]int)
+	for i, nodes := range proofs {
+		p, err := NewProof(nodes, keys[i], maxKeyLength, maxDepth)
+		if err != nil {
+			return nil, fmt.Errorf("proof %d: %s", i, err.Error())
+		}
+		b.Proofs = append(b.Proofs, p)
+
+		indexes := make([]frontend.Variable, maxDepth-1)
+		for layer := range indexes {
+			indexes[layer] = 0
+			if layer >= p.Depth-1 {
+				continue
+			}
+			index, ok := nodeIndexes[string(nodes[layer])]
+			if !ok {
+				index = len(b.NodeRlp)
+				nodeIndexes[string(nodes[layer])] = index
+				b.NodeRlp = append(b.NodeRlp, p.NodeRlp[layer])
+				b.NodePathPrefixLength = append(b.NodePathPrefixLength, p.NodePathPrefixLength[layer])
+				b.NodeTypes = append(b.NodeTypes, p.NodeTypes[layer])
+			}
+			indexes[layer] = index
+		}
+		b.NodeIndexes = append(b.NodeIndexes, indexes)
+	}
+
+	b.NodeNum = len(b.NodeRlp)
+	if b.NodeNum > maxNodes {
+		return nil, fmt.Errorf("%d distinct nodes exceed max nodes %d", b.NodeNum, maxNodes)
+	}
+	for i := b.NodeNum; i < maxNodes; i++ {
+		b.NodeRlp = append(b.NodeRlp, NibblesToVariables(nil, mpt.BranchNodeMaxBlockSize))
+		b.NodePathPrefixLength = append(b.NodePathPrefixLength, 0)
+		b.NodeTypes = append(b.NodeTypes, 0)
+	}
+	return b, nil
+}
+
+// ExclusionProof is the per layer witness of an mpt exclusion proof, laid out for CheckMPTExclusionFixedKeyLength.
+// Unlike Proof, the terminal node is part of the node arrays.
+type ExclusionProof struct {
+	KeyFragmentStarts    []frontend.Variable   // [maxDepth]
+	NodeRlp              [][]frontend.Variable // [maxDepth][mpt.BranchNodeMaxBlockSize]
+	NodePathPrefixLength []frontend.Variable   // [maxDepth]
+	NodeTypes            []frontend.Variable   // [maxDepth]
+	Depth                int
+}
+
+// NewExclusionProof walks the proof nodes of an absent key, as returned by eth_getProof or trie.Prove. The walk
+// must end at a branch node with an empty child at the next key nibble, or at a leaf or extension node whose
+// path diverges from the rest of the key.
+func NewExclusionProof(nodes [][]byte, key []byte, maxKeyLength int, maxDepth int) (*ExclusionProof, error) {
+	depth := len(nodes)
+	if depth == 0 {
+		return nil, fmt.Errorf("empty proof")
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("proof depth %d exceeds max depth %d", depth, maxDepth)
+	}
+	if len(key) > maxKeyLength {
+		return nil, fmt.Errorf("key length %d exceeds max key length %d", len(key), maxKeyLength)
+	}
+
+	p := &ExclusionProof{Depth: depth}
+	start := 0
+	for i := 0; i < depth; i++ {
+		node, err := DecodeNode(nodes[i])
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+		nodeRlp, err := PaddedNibbles(nodes[i], mpt.BranchNodeMaxBlockSize)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+		if start >= len(key) {
+			return nil, fmt.Errorf("node %d after the key is fully consumed", i)
+		}
+		p.KeyFragmentStarts = append(p.KeyFragmentStarts, start)
+		p.NodeRlp = append(p.NodeRlp, nodeRlp)
+		p.NodePathPrefixLength = append(p.NodePathPrefixLength, node.PathPrefixLength)
+		p.NodeTypes = append(p.NodeTypes, node.Type)
+
+		terminal := i == depth-1
+		switch node.Type {
+		case BranchNode:
+			empty := len(node.Children[key[start]]) == 1 && node.Children[key[start]][0] == 0x80
+			if terminal != empty {
+				return nil, fmt.Errorf("branch node %d child %d does not match the proof", i, key[start])
+			}
+			start++
+		default:
+			end := start + len(node.Path)
+			diverged := end > len(key) || !bytes.Equal(node.Path, key[start:end])
+			if terminal != diverged {
+				return nil, fmt.Errorf("node %d path does not match the proof", i)
+			}
+			if node.Type == LeafNode && !terminal {
+				return nil, fmt.Errorf("node %d is a leaf but is not the last node of the proof", i)
+			}
+			start = end
+		}
+	}
+
+	for i := depth; i < maxDepth; i++ {
+		p.KeyFragmentStarts = append(p.KeyFragmentStarts, maxKeyLength)
+		p.NodeRlp = append(p.NodeRlp, NibblesToVariables(nil, mpt.BranchNodeMaxBlockSize))
+		p.NodePathPrefixLength = append(p.NodePathPrefixLength, 0)
+		p.NodeTypes = append(p.NodeTypes, 0)
+	}
+	return p, nil
+}
+
+// LeafRlp returns the keccak padded leaf nibbles, filled up with zeros to size
+func (p *Proof) LeafRlp(size int) ([]frontend.Variable, error) {
+	return PaddedNibbles(p.Leaf.Rlp, size)
+}
+
+// UpdatedLeafRlp returns the rlp of the leaf holding value instead of its current value, as written to the trie
+// when the value of the leaf's key is updated
+func (p *Proof) UpdatedLeafRlp(value []byte) ([]byte, error) {
+	var items []rlp.RawValue
+	if err := rlp.DecodeBytes(p.Leaf.Rlp, &items); err != nil || len(items) != 2 {
+		return nil, fmt.Errorf("invalid leaf")
+	}
+	return rlp.EncodeToBytes([]interface{}{items[0], value})
+}
+
+// PaddedNibbles keccak pads data and returns its nibbles, filled up with zeros to size
+func PaddedNibbles(data []byte, size int) ([]frontend.Variable, error) {
+	padded := keccak.Pad101Bytes(append([]byte{}, data...))
+	if len(padded)*2 > size {
+		return nil, fmt.Errorf("%d bytes do not fit into %d nibbles after padding", len(data), size)
+	}
+	return NibblesToVariables(BytesToNibbles(padded), size), nil
+}
+
+// NibblesToVariables returns the nibbles as variables, filled up with zeros to size
+func NibblesToVariables(nibbles []byte, size int) []frontend.Variable {
+	ret := make([]frontend.Variable, size)
+	for i := range ret {
+		if i < len(nibbles) {
+			ret[i] = nibbles[i]
+		} else {
+			ret[i] = 0
+		}
+	}
+	return ret
+}
+
+func BytesToNibbles(data []byte) []byte {
+	var nibbles []byte
+	for _, b := range data {
+		nibbles = append(nibbles, b>>4, b&0x0F)
+	}
+	return nibbles
+}
@@ -0,0 +1,497 @@
+package witness
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/common"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const testMaxDepth = 5
+
+type inclusionCircuit struct {
+	Key                  [64]frontend.Variable
+	Value                [66]frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    []frontend.Variable // [maxDepth]
+	LeafRlp              [mpt.StorageLeafMaxBlockHexLen]frontend.Variable
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [][mpt.BranchNodeMaxBlockSize]frontend.Variable // [maxDepth - 1]
+	NodePathPrefixLength []frontend.Variable                             // [maxDepth - 1]
+	NodeTypes            []frontend.Variable                             // [maxDepth - 1]
+	Depth                frontend.Variable
+}
+
+func newInclusionCircuit(maxDepth int) *inclusionCircuit {
+	return &inclusionCircuit{
+		KeyFragmentStarts:    make([]frontend.Variable, maxDepth),
+		NodeRlp:              make([][mpt.BranchNodeMaxBlockSize]frontend.Variable, maxDepth-1),
+		NodePathPrefixLength: make([]frontend.Variable, maxDepth-1),
+		NodeTypes:            make([]frontend.Variable, maxDepth-1),
+	}
+}
+
+func (c *inclusionCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	result := mpt.CheckMPTInclusionFixedKeyLength(
+		api, mpt.NewMPTConfig(len(c.KeyFragmentStarts), 64, mpt.StorageLeafMaxBlockHexLen), 66, c.Key[:], c.Value[:], c.RootHash, c.KeyFragmentStarts,
+		c.LeafRlp[:], c.LeafPathPrefixLength, nodeRlp, c.NodePathPrefixLength,
+		c.NodeTypes, c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+type exclusionCircuit struct {
+	Key                  [64]frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    [testMaxDepth]frontend.Variable
+	NodeRlp              [testMaxDepth][mpt.BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [testMaxDepth]frontend.Variable
+	NodeTypes            [testMaxDepth]frontend.Variable
+	Depth                frontend.Variable
+}
+
+func (c *exclusionCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	result := mpt.CheckMPTExclusionFixedKeyLength(
+		api, mpt.NewMPTConfig(testMaxDepth, 64, 0), 66, c.Key[:], c.RootHash, c.KeyFragmentStarts[:], nodeRlp,
+		c.NodePathPrefixLength[:], c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+func TestNewProofWithExtensionNodes(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	tr := newTestTrie()
+	root := tr.Hash()
+
+	expectedTypes := map[string][]int{
+		"abc1":     {BranchNode, BranchNode, ExtensionNode, BranchNode},
+		"55556601": {BranchNode, ExtensionNode, BranchNode},
+		"7777771":  {BranchNode, ExtensionNode, BranchNode},
+		"12":       {BranchNode},
+	}
+	for prefix, types := range expectedTypes {
+		k := key(prefix)
+		proofWriter := &common.ProofWriter{}
+		assert.NoError(tr.Prove(k, 0, proofWriter))
+
+		p, err := NewProof(proofWriter.Values, BytesToNibbles(k), 64, testMaxDepth)
+		assert.NoError(err)
+		assert.Equal(len(types)+1, p.Depth, prefix)
+		for i, typ := range types {
+			assert.Equal(typ, p.NodeTypes[i], prefix)
+		}
+
+		w, err := newInclusionAssignment(p, k, root.Bytes(), testMaxDepth)
+		assert.NoError(err)
+		err = test.IsSolved(newInclusionCircuit(testMaxDepth), w, ecc.BN254.ScalarField())
+		assert.NoError(err, prefix)
+	}
+}
+
+func TestNewProofDeeperThanTenLevels(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// every key branches off the previous one a nibble deeper, giving 15 branch nodes above the leaf
+	const maxDepth = 16
+	tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	var deepest []byte
+	for i := 0; i < 16; i++ {
+		deepest = key(strings.Repeat("0", i) + "1")
+		tr.Update(deepest, value(deepest))
+	}
+	root := tr.Hash()
+
+	proofWriter := &common.ProofWriter{}
+	assert.NoError(tr.Prove(deepest, 0, proofWriter))
+	p, err := NewProof(proofWriter.Values, BytesToNibbles(deepest), 64, maxDepth)
+	assert.NoError(err)
+	assert.Equal(16, p.Depth)
+
+	w, err := newInclusionAssignment(p, deepest, root.Bytes(), maxDepth)
+	assert.NoError(err)
+	err = test.IsSolved(newInclusionCircuit(maxDepth), w, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
+
+func TestInclusionRandom(t *testing.T) {
+	randtest.Check(t, 2, newInclusionCircuit(testMaxDepth), func(rng *rand.Rand) frontend.Circuit {
+		tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+		var keys [][]byte
+		for i := 0; i < 32; i++ {
+			k := randtest.Bytes(rng, 32)
+			v, err := rlp.EncodeToBytes(randtest.Bytes(rng, 1+rng.Intn(32)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Update(k, v)
+			keys = append(keys, k)
+		}
+		root := tr.Hash()
+		k := keys[rng.Intn(len(keys))]
+		proofWriter := &common.ProofWriter{}
+		if err := tr.Prove(k, 0, proofWriter); err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := native.VerifyProof(root, k, proofWriter.Values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewProof(proofWriter.Values, BytesToNibbles(k), 64, testMaxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := newInclusionAssignment(p, k, root.Bytes(), testMaxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(w.Value[:], NibblesToVariables(BytesToNibbles(v), 66))
+		return w
+	})
+}
+
+func TestNewExclusionProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	tr := newTestTrie()
+	root := tr.Hash()
+
+	expectedTypes := map[string][]int{
+		"d0":   {BranchNode},                                        // empty root child
+		"abc3": {BranchNode, BranchNode, ExtensionNode, BranchNode}, // empty child below an extension
+		"13":   {BranchNode, LeafNode},                              // diverging leaf
+		"5556": {BranchNode, ExtensionNode},                         // diverging extension
+	}
+	for prefix, types := range expectedTypes {
+		k := key(prefix)
+		proofWriter := &common.ProofWriter{}
+		assert.NoError(tr.Prove(k, 0, proofWriter))
+
+		p, err := NewExclusionProof(proofWriter.Values, BytesToNibbles(k), 64, testMaxDepth)
+		assert.NoError(err, prefix)
+		assert.Equal(len(types), p.Depth, prefix)
+		for i, typ := range types {
+			assert.Equal(typ, p.NodeTypes[i], prefix)
+		}
+
+		w := newExclusionAssignment(p, k, root.Bytes())
+		err = test.IsSolved(&exclusionCircuit{}, w, ecc.BN254.ScalarField())
+		assert.NoError(err, prefix)
+	}
+
+	// an included key has no exclusion proof
+	proofWriter := &common.ProofWriter{}
+	assert.NoError(tr.Prove(key("abc1"), 0, proofWriter))
+	_, err := NewExclusionProof(proofWriter.Values, BytesToNibbles(key("abc1")), 64, testMaxDepth)
+	assert.Error(err)
+
+	// nor can the exclusion proof of a sibling key be reused for it
+	proofWriter = &common.ProofWriter{}
+	assert.NoError(tr.Prove(key("abc3"), 0, proofWriter))
+	p, err := NewExclusionProof(proofWriter.Values, BytesToNibbles(key("abc3")), 64, testMaxDepth)
+	assert.NoError(err)
+	w := newExclusionAssignment(p, key("abc1"), root.Bytes())
+	err = test.IsSolved(&exclusionCircuit{}, w, ecc.BN254.ScalarField())
+	assert.Error(err)
+}
+
+func TestNewProofKeyMismatch(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	tr.Update(key("abc1"), value(key("abc1")))
+	tr.Update(key("abc2"), value(key("abc2")))
+
+	proofWriter := &common.ProofWriter{}
+	assert.NoError(tr.Prove(key("abc1"), 0, proofWriter))
+	_, err := NewProof(proofWriter.Values, BytesToNibbles(key("abc2")), 64, testMaxDepth)
+	assert.Error(err)
+	_, err = NewProof(proofWriter.Values, BytesToNibbles(key("abd1")), 64, testMaxDepth)
+	assert.Error(err)
+}
+
+// newTestTrie returns a trie with single nibble, even and odd length extension nodes
+func newTestTrie() *trie.Trie {
+	keys := [][]byte{
+		key("abc1"), key("abc2"), key("a0"), // single nibble extension "c"
+		key("55556601"), key("55556602"), // even extension "555660"
+		key("7777771"), key("7777772"), // odd extension "77777"
+		key("12"),
+	}
+	tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	for _, k := range keys {
+		tr.Update(k, value(k))
+	}
+	return tr
+}
+
+func newInclusionAssignment(p *Proof, k []byte, root []byte, maxDepth int) (*inclusionCircuit, error) {
+	leafRlp, err := p.LeafRlp(272)
+	if err != nil {
+		return nil, err
+	}
+	w := newInclusionCircuit(maxDepth)
+	w.LeafPathPrefixLength = p.Leaf.PathPrefixLength
+	w.Depth = p.Depth
+	copy(w.Key[:], NibblesToVariables(BytesToNibbles(k), 64))
+	copy(w.Value[:], NibblesToVariables(BytesToNibbles(value(k)), 66))
+	copy(w.RootHash[:], NibblesToVariables(BytesToNibbles(root), 64))
+	copy(w.KeyFragmentStarts, p.KeyFragmentStarts)
+	copy(w.LeafRlp[:], leafRlp)
+	for i := 0; i < maxDepth-1; i++ {
+		copy(w.NodeRlp[i][:], p.NodeRlp[i])
+	}
+	copy(w.NodePathPrefixLength, p.NodePathPrefixLength)
+	copy(w.NodeTypes, p.NodeTypes)
+	return w, nil
+}
+
+func newExclusionAssignment(p *ExclusionProof, k []byte, root []byte) *exclusionCircuit {
+	w := &exclusionCircuit{Depth: p.Depth}
+	copy(w.Key[:], NibblesToVariables(BytesToNibbles(k), 64))
+	copy(w.RootHash[:], NibblesToVariables(BytesToNibbles(root), 64))
+	copy(w.KeyFragmentStarts[:], p.KeyFragmentStarts)
+	for i := 0; i < testMaxDepth; i++ {
+		copy(w.NodeRlp[i][:], p.NodeRlp[i])
+	}
+	copy(w.NodePathPrefixLength[:], p.NodePathPrefixLength)
+	copy(w.NodeTypes[:], p.NodeTypes)
+	return w
+}
+
+// key returns a 32 byte key starting with the given hex nibbles, followed by the hash of the prefix
+func key(prefix string) []byte {
+	nibbles := make([]byte, 64)
+	for i, c := range prefix {
+		var n byte
+		if c >= 'a' {
+			n = byte(c-'a') + 10
+		} else {
+			n = byte(c - '0')
+		}
+		nibbles[i] = n
+	}
+	rest := BytesToNibbles(crypto.Keccak256([]byte(prefix)))
+	copy(nibbles[len(prefix):], rest)
+	k := make([]byte, 32)
+	for i := range k {
+		k[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return k
+}
+
+func value(k []byte) []byte {
+	v, _ := rlp.EncodeToBytes(crypto.Keccak256(k))
+	return v
+}
+
+type batchInclusionCircuit struct {
+	Keys                 [][6]frontend.Variable
+	KeyLengths           []frontend.Variable
+	RootHash             [64]frontend.Variable
+	KeyFragmentStarts    [][]frontend.Variable
+	LeafHashes           [][2]frontend.Variable
+	NodeRlp              [][mpt.BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength []frontend.Variable
+	NodeTypes            []frontend.Variable
+	NodeIndexes          [][]frontend.Variable
+	Depths               []frontend.Variable
+}
+
+func newBatchInclusionCircuit(keyNum int, maxNodes int, maxDepth int) *batchInclusionCircuit {
+	c := &batchInclusionCircuit{
+		Keys:                 make([][6]frontend.Variable, keyNum),
+		KeyLengths:           make([]frontend.Variable, keyNum),
+		KeyFragmentStarts:    make([][]frontend.Variable, keyNum),
+		LeafHashes:           make([][2]frontend.Variable, keyNum),
+		NodeRlp:              make([][mpt.BranchNodeMaxBlockSize]frontend.Variable, maxNodes),
+		NodePathPrefixLength: make([]frontend.Variable, maxNodes),
+		NodeTypes:            make([]frontend.Variable, maxNodes),
+		NodeIndexes:          make([][]frontend.Variable, keyNum),
+		Depths:               make([]frontend.Variable, keyNum),
+	}
+	for i := 0; i < keyNum; i++ {
+		c.KeyFragmentStarts[i] = make([]frontend.Variable, maxDepth)
+		c.NodeIndexes[i] = make([]frontend.Variable, maxDepth-1)
+	}
+	return c
+}
+
+func (c *batchInclusionCircuit) Define(api frontend.API) error {
+	var keys, nodeRlp [][]frontend.Variable
+	for i := range c.Keys {
+		keys = append(keys, c.Keys[i][:])
+	}
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	results := mpt.CheckMPTBatchInclusionNoBranchTermination(api, mpt.NewMPTConfig(len(c.NodeIndexes[0])+1, 6, 0),
+		keys, c.KeyLengths, c.RootHash, c.KeyFragmentStarts, c.LeafHashes, nodeRlp,
+		c.NodePathPrefixLength, c.NodeTypes, c.NodeIndexes, c.Depths)
+	for _, result := range results {
+		api.AssertIsEqual(result.Output, 1)
+	}
+	return nil
+}
+
+func TestNewBatchProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// a transaction trie keyed by the rlp encoded index
+	tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	for i := uint(0); i < 40; i++ {
+		k, _ := rlp.EncodeToBytes(i)
+		tr.Update(k, crypto.Keccak256(k, []byte("transaction"), k))
+	}
+	root := tr.Hash()
+
+	const maxDepth, maxNodes = 4, 4
+	var keys [][]byte
+	var nodes [][][]byte
+	for _, i := range []uint{1, 2, 0x21, 0x22} {
+		k, _ := rlp.EncodeToBytes(i)
+		proofWriter := &common.ProofWriter{}
+		assert.NoError(tr.Prove(k, 0, proofWriter))
+		nodes = append(nodes, proofWriter.Values)
+		keys = append(keys, BytesToNibbles(k))
+	}
+	b, err := NewBatchProof(nodes, keys, 6, maxDepth, maxNodes)
+	assert.NoError(err)
+	// the root and the branch nodes below nibbles 0 and 2 are shared
+	assert.Equal(3, b.NodeNum)
+
+	w := newBatchInclusionCircuit(len(keys), maxNodes, maxDepth)
+	copy(w.RootHash[:], NibblesToVariables(BytesToNibbles(root.Bytes()), 64))
+	for i, p := range b.Proofs {
+		copy(w.Keys[i][:], NibblesToVariables(keys[i], 6))
+		w.KeyLengths[i] = len(keys[i])
+		leafHash := crypto.Keccak256(p.Leaf.Rlp)
+		w.LeafHashes[i] = [2]frontend.Variable{leafHash[:16], leafHash[16:]}
+		copy(w.KeyFragmentStarts[i], p.KeyFragmentStarts)
+		copy(w.NodeIndexes[i], b.NodeIndexes[i])
+		w.Depths[i] = p.Depth
+	}
+	for i := range w.NodeRlp {
+		copy(w.NodeRlp[i][:], b.NodeRlp[i])
+	}
+	copy(w.NodePathPrefixLength, b.NodePathPrefixLength)
+	copy(w.NodeTypes, b.NodeTypes)
+
+	err = test.IsSolved(newBatchInclusionCircuit(len(keys), maxNodes, maxDepth), w, ecc.BN254.ScalarField())
+	assert.NoError(err)
+
+	// a path referencing the node of another path fails
+	w.NodeIndexes[2][1], w.NodeIndexes[0][1] = w.NodeIndexes[0][1], w.NodeIndexes[2][1]
+	err = test.IsSolved(newBatchInclusionCircuit(len(keys), maxNodes, maxDepth), w, ecc.BN254.ScalarField())
+	assert.Error(err)
+}
+
+type updateCircuit struct {
+	Key                  [64]frontend.Variable
+	OldValue             [66]frontend.Variable
+	NewValue             [66]frontend.Variable
+	OldRoot              [64]frontend.Variable
+	NewRoot              [64]frontend.Variable
+	KeyFragmentStarts    [testMaxDepth]frontend.Variable
+	OldLeafRlp           [mpt.StorageLeafMaxBlockHexLen]frontend.Variable
+	NewLeafRlp           [mpt.StorageLeafMaxBlockHexLen]frontend.Variable
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [testMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [testMaxDepth - 1]frontend.Variable
+	NodeTypes            [testMaxDepth - 1]frontend.Variable
+	Depth                frontend.Variable
+}
+
+func (c *updateCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	result := mpt.CheckMPTUpdateFixedKeyLength(
+		api, mpt.NewMPTConfig(testMaxDepth, 64, mpt.StorageLeafMaxBlockHexLen), 66, c.Key[:], c.OldValue[:], c.NewValue[:], c.OldRoot,
+		c.NewRoot, c.KeyFragmentStarts[:], c.OldLeafRlp[:], c.NewLeafRlp[:],
+		c.LeafPathPrefixLength, nodeRlp, c.NodePathPrefixLength[:], c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	return nil
+}
+
+func TestUpdateProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// a literal, a shorter and a same length value as the 33 byte test values
+	newValues := [][]byte{{0x05}, crypto.Keccak256([]byte("new value"))[:16], crypto.Keccak256([]byte("new value"))}
+	for i, prefix := range []string{"abc1", "55556601", "7777772", "12"} {
+		tr := newTestTrie()
+		oldRoot := tr.Hash()
+		k := key(prefix)
+		proofWriter := &common.ProofWriter{}
+		assert.NoError(tr.Prove(k, 0, proofWriter))
+		p, err := NewProof(proofWriter.Values, BytesToNibbles(k), 64, testMaxDepth)
+		assert.NoError(err)
+
+		newValue, _ := rlp.EncodeToBytes(newValues[i%len(newValues)])
+		tr.Update(k, newValue)
+		newRoot := tr.Hash()
+
+		oldLeafRlp, err := p.LeafRlp(272)
+		assert.NoError(err)
+		newLeaf, err := p.UpdatedLeafRlp(newValue)
+		assert.NoError(err)
+		newLeafRlp, err := PaddedNibbles(newLeaf, 272)
+		assert.NoError(err)
+
+		w := &updateCircuit{
+			LeafPathPrefixLength: p.Leaf.PathPrefixLength,
+			Depth:                p.Depth,
+		}
+		copy(w.Key[:], NibblesToVariables(BytesToNibbles(k), 64))
+		copy(w.OldValue[:], NibblesToVariables(BytesToNibbles(value(k)), 66))
+		copy(w.NewValue[:], NibblesToVariables(BytesToNibbles(newValue), 66))
+		copy(w.OldRoot[:], NibblesToVariables(BytesToNibbles(oldRoot.Bytes()), 64))
+		copy(w.NewRoot[:], NibblesToVariables(BytesToNibbles(newRoot.Bytes()), 64))
+		copy(w.KeyFragmentStarts[:], p.KeyFragmentStarts)
+		copy(w.OldLeafRlp[:], oldLeafRlp)
+		copy(w.NewLeafRlp[:], newLeafRlp)
+		for i := range w.NodeRlp {
+			copy(w.NodeRlp[i][:], p.NodeRlp[i])
+		}
+		copy(w.NodePathPrefixLength[:], p.NodePathPrefixLength)
+		copy(w.NodeTypes[:], p.NodeTypes)
+
+		err = test.IsSolved(&updateCircuit{}, w, ecc.BN254.ScalarField())
+		assert.NoError(err, prefix)
+		if i > 0 {
+			continue
+		}
+
+		// nibbles after the new leaf are not hashed, the padding is applied in circuit
+		w.NewLeafRlp[len(newLeaf)*2] = 0xf
+		err = test.IsSolved(&updateCircuit{}, w, ecc.BN254.ScalarField())
+		assert.NoError(err, prefix)
+		copy(w.NewLeafRlp[:], newLeafRlp)
+
+		w.NewRoot = w.OldRoot
+		err = test.IsSolved(&updateCircuit{}, w, ecc.BN254.ScalarField())
+		assert.Error(err, prefix)
+	}
+}
@@ -1,14 +1,19 @@
 package rlp
 
 import (
+	"bytes"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/gadgets/rlp/native"
+	"github.com/celer-network/brevis-circuits/gadgets/utils/randtest"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/profile"
 	"github.com/consensys/gnark/test"
-	"strconv"
-	"testing"
 )
 
 const LeafMaxHexLen = 140
@@ -76,3 +81,230 @@ func Test_MPT_Leaf_Node_check(t *testing.T) {
 	assert := test.NewAssert(t)
 	assert.NoError(err)
 }
+
+const strictMaxHexLen = 320
+
+type StrictArrayCheckCircuit struct {
+	In     [strictMaxHexLen]frontend.Variable
+	strict bool
+}
+
+// Define checks a list of an integer of at most 8 bytes and a string of at most 128 bytes
+func (c *StrictArrayCheckCircuit) Define(api frontend.API) error {
+	arrayCheck := &ArrayCheck{
+		MaxHexLen:            strictMaxHexLen,
+		MaxFields:            2,
+		ArrayPrefixMaxHexLen: 4,
+		FieldMinHexLen:       []int{0, 0},
+		FieldMaxHexLen:       []int{16, 256},
+		Strict:               c.strict,
+		FieldIsInteger:       []bool{true, false},
+	}
+	out, _, _, _ := arrayCheck.RlpArrayCheck(api, c.In[:])
+	api.AssertIsEqual(out, 1)
+	return nil
+}
+
+// rlpList prefixes payload with a canonical list prefix
+func rlpList(payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	if len(data) < 56 {
+		return append([]byte{0xc0 + byte(len(data))}, data...)
+	}
+	return append([]byte{0xf8, byte(len(data))}, data...)
+}
+
+func Test_Strict_Array_Check(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	solve := func(data []byte, strict bool) error {
+		witness := &StrictArrayCheckCircuit{}
+		nibbles := bytesToNibbles(data)
+		for i := range witness.In {
+			witness.In[i] = 0
+			if i < len(nibbles) {
+				witness.In[i] = nibbles[i]
+			}
+		}
+		return test.IsSolved(&StrictArrayCheckCircuit{strict: strict}, witness, ecc.BN254.ScalarField())
+	}
+
+	dog := []byte{0x83, 'd', 'o', 'g'}
+	long := append([]byte{0xb8, 60}, bytes.Repeat([]byte{0xab}, 60)...)
+	canonical := [][]byte{
+		rlpList([]byte{0x80}, dog),
+		rlpList([]byte{0x05}, []byte{0x81, 0x80}),
+		rlpList([]byte{0x82, 0x01, 0x00}, long),
+	}
+	for _, data := range canonical {
+		assert.NoError(solve(data, false), "%x", data)
+		assert.NoError(solve(data, true), "%x", data)
+	}
+
+	nonCanonical := [][]byte{
+		// long list prefix for a short list
+		append([]byte{0xf8, 5, 0x80}, dog...),
+		// list length with a leading zero byte
+		append([]byte{0xf9, 0, byte(1 + len(long)), 0x80}, long...),
+		// single byte below 0x80 behind a string prefix
+		rlpList([]byte{0x80}, []byte{0x81, 0x05}),
+		// long string prefix for a short string
+		rlpList([]byte{0x80}, []byte{0xb8, 3, 'd', 'o', 'g'}),
+		// string length with a leading zero byte
+		rlpList([]byte{0x80}, append([]byte{0xb9, 0, 60}, bytes.Repeat([]byte{0xab}, 60)...)),
+		// integer with a leading zero byte
+		rlpList([]byte{0x82, 0x00, 0x05}, dog),
+		// integer 0 as the literal 0x00
+		rlpList([]byte{0x00}, dog),
+	}
+	for _, data := range nonCanonical {
+		assert.NoError(solve(data, false), "%x", data)
+		assert.Error(solve(data, true), "%x", data)
+	}
+}
+
+type LeadingArrayCheckCircuit struct {
+	In          [strictMaxHexLen]frontend.Variable
+	Checked     frontend.Variable
+	FieldHexLen frontend.Variable
+	leading     bool
+}
+
+// Define decodes the first two fields of a list, the integer and the string of StrictArrayCheckCircuit
+func (c *LeadingArrayCheckCircuit) Define(api frontend.API) error {
+	arrayCheck := &ArrayCheck{
+		MaxHexLen:            strictMaxHexLen,
+		MaxFields:            2,
+		ArrayPrefixMaxHexLen: 4,
+		FieldMinHexLen:       []int{0, 0},
+		FieldMaxHexLen:       []int{16, 256},
+		Leading:              c.leading,
+	}
+	out, _, fieldHexLens, _ := arrayCheck.RlpArrayCheck(api, c.In[:])
+	api.AssertIsEqual(out, c.Checked)
+	api.AssertIsEqual(fieldHexLens[1], c.FieldHexLen)
+	return nil
+}
+
+func Test_Leading_Array_Check(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dog := []byte{0x83, 'd', 'o', 'g'}
+	for _, tc := range []struct {
+		data    []byte
+		leading bool
+		checked int
+	}{
+		{rlpList([]byte{0x05}, dog), false, 1},
+		{rlpList([]byte{0x05}, dog), true, 1},
+		// more fields than MaxFields
+		{rlpList([]byte{0x05}, dog, []byte{0x80}, dog), false, 0},
+		{rlpList([]byte{0x05}, dog, []byte{0x80}, dog), true, 1},
+		// the list ends before the second field
+		{append(rlpList([]byte{0x05}), dog...), true, 0},
+	} {
+		witness := &LeadingArrayCheckCircuit{Checked: tc.checked, FieldHexLen: 6}
+		nibbles := bytesToNibbles(tc.data)
+		for i := range witness.In {
+			witness.In[i] = 0
+			if i < len(nibbles) {
+				witness.In[i] = nibbles[i]
+			}
+		}
+		err := test.IsSolved(&LeadingArrayCheckCircuit{leading: tc.leading}, witness, ecc.BN254.ScalarField())
+		assert.NoError(err, "%x leading %t", tc.data, tc.leading)
+	}
+}
+
+const (
+	randomCheckMaxHexLen = 352
+	randomCheckFields    = 3
+	// short string fields only, the field shifts are sized by FieldMaxHexLen
+	randomCheckFieldMaxLen = 55
+)
+
+type RandomArrayCheckCircuit struct {
+	In          [randomCheckMaxHexLen]frontend.Variable
+	Checked     frontend.Variable
+	TotalHexLen frontend.Variable
+	FieldHexLen [randomCheckFields]frontend.Variable
+	Fields      [randomCheckFields][randomCheckMaxHexLen]frontend.Variable
+}
+
+// Define compares ArrayCheck with native.ArrayCheck, the lengths and the fields only when the list is valid
+func (c *RandomArrayCheckCircuit) Define(api frontend.API) error {
+	arrayCheck := &ArrayCheck{
+		MaxHexLen:            randomCheckMaxHexLen,
+		MaxFields:            randomCheckFields,
+		ArrayPrefixMaxHexLen: 4,
+		FieldMinHexLen:       make([]int, randomCheckFields),
+		FieldMaxHexLen:       []int{2 * randomCheckFieldMaxLen, 2 * randomCheckFieldMaxLen, 2 * randomCheckFieldMaxLen},
+	}
+	out, totalRlpHexLen, fieldHexLens, fields := arrayCheck.RlpArrayCheck(api, c.In[:])
+	api.AssertIsEqual(out, c.Checked)
+	api.AssertIsEqual(api.Mul(c.Checked, api.Sub(totalRlpHexLen, c.TotalHexLen)), 0)
+	for i := range fields {
+		api.AssertIsEqual(api.Mul(c.Checked, api.Sub(fieldHexLens[i], c.FieldHexLen[i])), 0)
+		for j := range c.Fields[i] {
+			inField := api.Mul(c.Checked, LessThan(api, j, c.FieldHexLen[i]))
+			api.AssertIsEqual(api.Mul(inField, api.Sub(fields[i][j], c.Fields[i][j])), 0)
+		}
+	}
+	return nil
+}
+
+// randomField returns the rlp of a random literal, string or empty list field
+func randomField(rng *rand.Rand) []byte {
+	switch rng.Intn(4) {
+	case 0:
+		return []byte{byte(rng.Intn(0x80))}
+	case 1:
+		return []byte{0xc0}
+	default:
+		value := randtest.Bytes(rng, rng.Intn(randomCheckFieldMaxLen+1))
+		return append([]byte{0x80 + byte(len(value))}, value...)
+	}
+}
+
+func Test_Random_Array_Check(t *testing.T) {
+	randtest.Check(t, 8, &RandomArrayCheckCircuit{}, func(rng *rand.Rand) frontend.Circuit {
+		var fields [][]byte
+		for i := 0; i < randomCheckFields; i++ {
+			fields = append(fields, randomField(rng))
+		}
+		data := rlpList(fields...)
+		// corrupt the list length now and then. Field lengths above FieldMaxHexLen make ArrayCheck unsatisfiable
+		// instead of failing the check, so the fields are kept valid.
+		if rng.Intn(3) == 0 {
+			data[len(data)-len(bytes.Join(fields, nil))-1] += byte(1 - 2*rng.Intn(2))
+		}
+
+		ok, totalLen, decoded := native.ArrayCheck(data, randomCheckFields)
+		w := &RandomArrayCheckCircuit{Checked: 0, TotalHexLen: 2 * totalLen}
+		if ok {
+			w.Checked = 1
+		}
+		nibbles := native.Nibbles(data)
+		for i := range w.In {
+			w.In[i] = 0
+			if i < len(nibbles) {
+				w.In[i] = nibbles[i]
+			}
+		}
+		for i := range w.Fields {
+			w.FieldHexLen[i] = 0
+			var fieldNibbles []byte
+			if ok {
+				w.FieldHexLen[i] = 2 * len(decoded[i])
+				fieldNibbles = native.Nibbles(decoded[i])
+			}
+			for j := range w.Fields[i] {
+				w.Fields[i][j] = 0
+				if j < len(fieldNibbles) {
+					w.Fields[i][j] = fieldNibbles[j]
+				}
+			}
+		}
+		return w
+	})
+}
@@ -0,0 +1,176 @@
+package rlp
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// The gadgets below are the byte granular counterparts of RlpArrayPrefix, RlpFieldPrefix and ArrayCheck. They take
+// one variable per byte instead of two nibbles and return lengths in bytes, so the shifts and multiplexers over an
+// rlp buffer are half as wide. ShiftLeft, ShiftRight, SubArray and Multiplexer do not interpret their elements and
+// are used on byte arrays unchanged, with shifts and offsets counted in bytes.
+
+// NibblesToBytes packs pairs of big endian nibbles into bytes. The nibbles are expected to be range checked.
+func NibblesToBytes(api frontend.API, nibbles []frontend.Variable) []frontend.Variable {
+	if len(nibbles)%2 != 0 {
+		panic(fmt.Sprintf("NibblesToBytes: odd nibble length %d", len(nibbles)))
+	}
+	var out []frontend.Variable
+	for i := 0; i < len(nibbles); i += 2 {
+		out = append(out, api.Add(api.Mul(nibbles[i], 16), nibbles[i+1]))
+	}
+	return out
+}
+
+// BytesToNibbles splits bytes into big endian nibbles, range checking every byte to 8 bits
+func BytesToNibbles(api frontend.API, bytes []frontend.Variable) []frontend.Variable {
+	var out []frontend.Variable
+	for _, b := range bytes {
+		bits := api.ToBinary(b, 8)
+		out = append(out, api.FromBinary(bits[4:]...), api.FromBinary(bits[:4]...))
+	}
+	return out
+}
+
+// RlpArrayPrefixBytes is RlpArrayPrefix for the prefix byte of a list, returning the list length in bytes, or the
+// number of bytes of the length for a long list
+func RlpArrayPrefixBytes(api frontend.API, in frontend.Variable) (frontend.Variable, frontend.Variable, frontend.Variable) {
+	// prefix bits, least significant first
+	bits := api.ToBinary(in, 8)
+
+	// >= 0xc0
+	isValid := api.Mul(bits[7], bits[6])
+	// >= 0xf8
+	isBig := api.Mul(isValid, api.Mul(bits[5], api.Mul(bits[4], bits[3])))
+
+	lenTemp := api.Sub(in, 192)
+	lenTemp = api.Add(lenTemp, api.Mul(isBig, -55))
+	prefixOrTotalLen := api.Mul(isValid, lenTemp)
+
+	return isBig, prefixOrTotalLen, isValid
+}
+
+// RlpFieldPrefixBytes is RlpFieldPrefix for the prefix byte of a string field, returning the string length in
+// bytes, or the number of bytes of the length for a long string
+func RlpFieldPrefixBytes(api frontend.API, in frontend.Variable) (frontend.Variable,
+	frontend.Variable,
+	frontend.Variable,
+	frontend.Variable,
+	frontend.Variable) {
+
+	// prefix bits, least significant first
+	bits := api.ToBinary(in, 8)
+
+	// < 0x80
+	isLiteral := api.Sub(1, bits[7])
+	// < 0xc0
+	isString := api.Sub(1, api.Mul(bits[7], bits[6]))
+	// 0xb8 - 0xbf
+	isBig := api.Mul(api.Mul(bits[7], api.Sub(1, bits[6])), api.Mul(bits[5], api.Mul(bits[4], bits[3])))
+	// 0xc0
+	isEmptyList := api.IsZero(api.Sub(in, 192))
+
+	lenTemp := api.Sub(in, 128)
+	lenTemp = api.Add(lenTemp, api.Mul(isBig, -55))
+	lenTemp = api.Mul(api.Sub(1, isLiteral), lenTemp)
+	prefixOrTotalLen := api.Mul(lenTemp, api.Sub(1, isEmptyList))
+
+	// the empty list is the only list accepted as a field, the two cases are exclusive
+	isValid := api.Add(isString, isEmptyList)
+
+	return isBig, isLiteral, prefixOrTotalLen, isValid, isEmptyList
+}
+
+// ByteArrayCheck is ArrayCheck on a byte buffer, all lengths are in bytes
+type ByteArrayCheck struct {
+	MaxLen            int
+	MaxFields         int
+	ArrayPrefixMaxLen int
+	FieldMinLen       []int
+	FieldMaxLen       []int
+}
+
+// RlpArrayCheck rlp array length checker on bytes, return the check result,
+// the total length of the array with rlp prefix in bytes,
+// array of each decoded-field length in bytes,
+// array of each decoded field, left aligned.
+func (a *ByteArrayCheck) RlpArrayCheck(api frontend.API, in []frontend.Variable) (
+	out frontend.Variable,
+	totalRlpLen frontend.Variable,
+	fieldLens []frontend.Variable,
+	fields [][]frontend.Variable) {
+
+	isBig, prefixOrTotalLen, isValid := RlpArrayPrefixBytes(api, in[0])
+
+	check := isValid
+
+	lenSum := frontend.Variable(0)
+
+	var temp = frontend.Variable(0)
+	totalArrayIn := [][]frontend.Variable{make([]frontend.Variable, a.ArrayPrefixMaxLen)}
+	for idx := 0; idx < a.ArrayPrefixMaxLen; idx++ {
+		temp = api.Add(api.Mul(256, temp), in[1+idx])
+		totalArrayIn[0][idx] = temp
+	}
+
+	arrayRlpPrefix1Len := api.Mul(isBig, prefixOrTotalLen)
+
+	// when isBig, arrayRlpPrefix1Len is the number of length bytes. if <55byte, there are no length bytes
+	sel := api.Mul(isBig, api.Sub(arrayRlpPrefix1Len, 1))
+	totalArrayOut := Multiplexer(api, sel, 1, a.ArrayPrefixMaxLen, totalArrayIn)
+
+	totalArrayLen := api.Sub(totalArrayOut[0], prefixOrTotalLen)
+	totalArrayLen = api.Add(prefixOrTotalLen, api.Mul(isBig, totalArrayLen))
+
+	totalRlpLen = api.Add(1, arrayRlpPrefix1Len, totalArrayLen)
+
+	// the previous field, left aligned, the next field rlp starts right after its decoded length
+	var prevField []frontend.Variable
+	for idx := 0; idx < a.MaxFields; idx++ {
+		var fieldRlp []frontend.Variable
+		if idx == 0 {
+			fieldRlp = ShiftLeft(api, a.MaxLen, 1, 1+a.ArrayPrefixMaxLen, in[:a.MaxLen], api.Add(1, arrayRlpPrefix1Len))
+		} else {
+			fieldRlp = ShiftLeft(api, a.MaxLen, a.FieldMinLen[idx-1], a.FieldMaxLen[idx-1], prevField, fieldLens[idx-1])
+		}
+
+		fieldPrefixIsBig, fieldPrefixIsLiteral, fieldPrefixPrefixOrTotalLen, fieldPrefixIsValid, _ := RlpFieldPrefixBytes(api, fieldRlp[0])
+
+		fieldRlpPrefix1Len := api.Mul(fieldPrefixIsBig, fieldPrefixPrefixOrTotalLen)
+
+		lenPrefixMaxLen := LogCeil(a.FieldMaxLen[idx])/8 + 1
+		shlToFieldShift := api.Mul(api.Sub(1, fieldPrefixIsLiteral), api.Add(1, fieldRlpPrefix1Len))
+		field := ShiftLeft(api, a.MaxLen, 0, 1+lenPrefixMaxLen, fieldRlp, shlToFieldShift)
+		fields = append(fields, field)
+		prevField = field
+
+		fieldLenMultiSelc := api.Mul(fieldPrefixIsBig, api.Sub(fieldRlpPrefix1Len, 1))
+		fieldLenMultiIn := [][]frontend.Variable{make([]frontend.Variable, lenPrefixMaxLen)}
+		var tmp = frontend.Variable(0)
+		for j := 0; j < lenPrefixMaxLen; j++ {
+			tmp = api.Add(api.Mul(256, tmp), fieldRlp[1+j])
+			fieldLenMultiIn[0][j] = tmp
+		}
+		fieldLenMultiOut := Multiplexer(api, fieldLenMultiSelc, 1, lenPrefixMaxLen, fieldLenMultiIn)
+
+		temp2 := api.Sub(fieldLenMultiOut[0], fieldPrefixPrefixOrTotalLen)
+		fieldTemp := api.Add(fieldPrefixPrefixOrTotalLen, api.Mul(fieldPrefixIsBig, temp2))
+
+		// a literal is its own 1 byte field
+		fieldLen := api.Add(fieldTemp, api.Mul(fieldPrefixIsLiteral, api.Sub(1, fieldTemp)))
+		fieldLens = append(fieldLens, fieldLen)
+
+		check = api.Add(check, fieldPrefixIsValid)
+
+		// lenSum = lenSum + 1 - isLiteral + fieldRlpPrefix1Len + fieldLen
+		lenSum = api.Sub(api.Add(lenSum, 1), fieldPrefixIsLiteral)
+		lenSum = api.Add(lenSum, fieldRlpPrefix1Len, fieldLen)
+	}
+
+	lenCheck := api.IsZero(api.Sub(totalArrayLen, lenSum))
+
+	out = api.IsZero(api.Sub(api.Add(check, lenCheck), api.Add(a.MaxFields, 2)))
+
+	return
+}
@@ -0,0 +1,139 @@
+package rlp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	gethrlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+type PrefixBytesCircuit struct {
+	In [256]frontend.Variable
+}
+
+// Define checks the byte prefix gadgets against the nibble ones for every prefix byte
+func (c *PrefixBytesCircuit) Define(api frontend.API) error {
+	for _, b := range c.In {
+		nibbles := BytesToNibbles(api, []frontend.Variable{b})
+		in := [2]frontend.Variable{nibbles[0], nibbles[1]}
+
+		isBig, prefixOrTotalHexLen, isValid := RlpArrayPrefix(api, in)
+		isBigBytes, prefixOrTotalLen, isValidBytes := RlpArrayPrefixBytes(api, b)
+		api.AssertIsEqual(isBig, isBigBytes)
+		api.AssertIsEqual(prefixOrTotalHexLen, api.Mul(2, prefixOrTotalLen))
+		api.AssertIsEqual(isValid, isValidBytes)
+
+		isBig, isLiteral, prefixOrTotalHexLen, isValid, isEmptyList := RlpFieldPrefix(api, in)
+		isBigBytes, isLiteralBytes, prefixOrTotalLen, isValidBytes, isEmptyListBytes := RlpFieldPrefixBytes(api, b)
+		api.AssertIsEqual(isBig, isBigBytes)
+		api.AssertIsEqual(isLiteral, isLiteralBytes)
+		api.AssertIsEqual(prefixOrTotalHexLen, api.Mul(2, prefixOrTotalLen))
+		api.AssertIsEqual(isValid, isValidBytes)
+		api.AssertIsEqual(isEmptyList, isEmptyListBytes)
+	}
+	return nil
+}
+
+func Test_PrefixBytes(t *testing.T) {
+	assert := test.NewAssert(t)
+	witness := &PrefixBytesCircuit{}
+	for i := range witness.In {
+		witness.In[i] = i
+	}
+	err := test.IsSolved(&PrefixBytesCircuit{}, witness, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
+
+const (
+	byteCheckMaxHexLen = 256
+	byteCheckFields    = 2
+)
+
+type ByteArrayCheckCircuit struct {
+	In      [byteCheckMaxHexLen]frontend.Variable
+	Checked frontend.Variable
+}
+
+// Define runs ArrayCheck on the nibbles and ByteArrayCheck on the bytes of the same rlp and compares the results
+func (c *ByteArrayCheckCircuit) Define(api frontend.API) error {
+	arrayCheck := &ArrayCheck{
+		MaxHexLen:            byteCheckMaxHexLen,
+		MaxFields:            byteCheckFields,
+		ArrayPrefixMaxHexLen: 4,
+		FieldMinHexLen:       []int{0, 0},
+		FieldMaxHexLen:       []int{66, 256},
+	}
+	byteArrayCheck := &ByteArrayCheck{
+		MaxLen:            byteCheckMaxHexLen / 2,
+		MaxFields:         byteCheckFields,
+		ArrayPrefixMaxLen: 2,
+		FieldMinLen:       []int{0, 0},
+		FieldMaxLen:       []int{33, 128},
+	}
+
+	out, totalRlpHexLen, fieldHexLens, fields := arrayCheck.RlpArrayCheck(api, c.In[:])
+	in := NibblesToBytes(api, c.In[:])
+	// the nibbles survive the round trip
+	for i, nibble := range BytesToNibbles(api, in) {
+		api.AssertIsEqual(nibble, c.In[i])
+	}
+	outBytes, totalRlpLen, fieldLens, fieldsBytes := byteArrayCheck.RlpArrayCheck(api, in)
+
+	api.AssertIsEqual(out, c.Checked)
+	api.AssertIsEqual(outBytes, c.Checked)
+	api.AssertIsEqual(totalRlpHexLen, api.Mul(2, totalRlpLen))
+	for i := 0; i < byteCheckFields; i++ {
+		api.AssertIsEqual(fieldHexLens[i], api.Mul(2, fieldLens[i]))
+		fieldNibbles := BytesToNibbles(api, fieldsBytes[i])
+		for j := range fieldNibbles {
+			api.AssertIsEqual(fieldNibbles[j], fields[i][j])
+		}
+	}
+	return nil
+}
+
+func Test_ByteArrayCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	newWitness := func(data []byte, checked int) *ByteArrayCheckCircuit {
+		witness := &ByteArrayCheckCircuit{Checked: checked}
+		nibbles := bytesToNibbles(data)
+		for i := range witness.In {
+			witness.In[i] = 0
+			if i < len(nibbles) {
+				witness.In[i] = nibbles[i]
+			}
+		}
+		return witness
+	}
+
+	// a short list shaped like an mpt leaf
+	leaf, err := gethrlp.EncodeToBytes([][]byte{
+		append([]byte{0x20}, bytes.Repeat([]byte{0x0d}, 31)...),
+		append([]byte{0x94}, bytes.Repeat([]byte{0xbc}, 20)...),
+	})
+	assert.NoError(err)
+	// a long list with a long string field
+	long, err := gethrlp.EncodeToBytes([][]byte{
+		append([]byte{0x20}, bytes.Repeat([]byte{0x0d}, 32)...),
+		bytes.Repeat([]byte{0xab}, 60),
+	})
+	assert.NoError(err)
+	// literal fields
+	literal, err := gethrlp.EncodeToBytes([][]byte{{0x01}, {0x7f}})
+	assert.NoError(err)
+	// the list length is one byte short of its fields
+	invalid := append([]byte{}, long...)
+	invalid[1]--
+
+	for _, c := range []struct {
+		data    []byte
+		checked int
+	}{{leaf, 1}, {long, 1}, {literal, 1}, {invalid, 0}} {
+		err = test.IsSolved(&ByteArrayCheckCircuit{}, newWitness(c.data, c.checked), ecc.BN254.ScalarField())
+		assert.NoError(err)
+	}
+}
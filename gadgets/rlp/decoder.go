@@ -0,0 +1,192 @@
+package rlp
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Item locates an rlp item inside the nibble buffer of an ItemDecoder. Offsets and lengths are in nibbles and
+// relative to the start of the buffer, so an item nested several lists deep is still addressed in the original
+// buffer and its payload can be read with Payload, ShiftLeft or SubArray.
+type Item struct {
+	Offset        frontend.Variable // offset of the item prefix
+	PayloadOffset frontend.Variable // offset of the payload, equal to Offset for a single byte literal
+	PayloadLength frontend.Variable // payload length, 2 for a single byte literal
+	IsList        frontend.Variable // 1 for a list, 0 for a string or a single byte literal
+	IsValid       frontend.Variable // 1 if the prefix is well formed and the item ends within its parent
+}
+
+// End returns the offset right after the item
+func (item Item) End(api frontend.API) frontend.Variable {
+	return api.Add(item.PayloadOffset, item.PayloadLength)
+}
+
+// List holds the items of a decoded rlp list. Items has one entry per possible item, entries past Length are
+// not part of the list and have IsValid 0.
+type List struct {
+	Items   []Item
+	Length  frontend.Variable // number of items in the list
+	IsValid frontend.Variable // 1 if the list item is valid and its payload is exactly covered by valid items
+}
+
+// ItemDecoder decodes nested rlp items of a nibble buffer, e.g. the logs of a receipt, the topics of a log or the
+// access list of a typed transaction. An item is decoded from its offset in the buffer, a list is decoded into its
+// items and an item of a list is selected by index, nested lists are walked by decoding the selected item again.
+//
+// Nothing is asserted on malformed input, every decoded item carries an IsValid flag instead, so a caller can
+// decide whether a malformed item fails the proof or yields a false output. The buffer nibbles are expected to be
+// range checked by the caller.
+type ItemDecoder struct {
+	api         frontend.API
+	in          []frontend.Variable
+	maxLenBytes int // max bytes of the length of a long string or list
+}
+
+// NewItemDecoder returns a decoder for the nibbles in. maxLenBytes bounds the length of the length of long strings
+// and lists, between 1 and 8 bytes, items with a longer length are invalid. 2 bytes are enough for any item of
+// less than 64KB.
+func NewItemDecoder(api frontend.API, in []frontend.Variable, maxLenBytes int) *ItemDecoder {
+	if maxLenBytes < 1 || maxLenBytes > 8 {
+		panic(fmt.Sprintf("NewItemDecoder: max length bytes %d not in [1, 8]", maxLenBytes))
+	}
+	return &ItemDecoder{api: api, in: in, maxLenBytes: maxLenBytes}
+}
+
+// Item decodes the item starting at offset. The item is valid if it ends within the buffer.
+func (d *ItemDecoder) Item(offset frontend.Variable) Item {
+	return d.decode(offset, len(d.in))
+}
+
+// DecodeList decodes the items of list, with at most maxItems items
+func (d *ItemDecoder) DecodeList(list Item, maxItems int) List {
+	api := d.api
+	end := list.End(api)
+
+	var result List
+	result.Length = 0
+	allValid := frontend.Variable(1)
+	offset := list.PayloadOffset
+	for i := 0; i < maxItems; i++ {
+		item := d.decode(offset, end)
+		// an item exists if it starts before the end of the list, the offset stays at the end of the list once
+		// it is reached so that the items past it are not decoded from the bytes following the list
+		exists := LessThan(api, offset, end)
+		item.IsValid = api.Mul(item.IsValid, exists)
+		allValid = api.Mul(allValid, api.Sub(1, api.Sub(exists, item.IsValid)))
+		result.Length = api.Add(result.Length, exists)
+		result.Items = append(result.Items, item)
+		offset = api.Add(offset, api.Mul(exists, api.Sub(item.End(api), offset)))
+	}
+
+	// the items must cover the payload exactly, an item overflowing the list or more than maxItems items leave
+	// the offset off the end of the list
+	result.IsValid = api.Mul(api.Mul(list.IsValid, list.IsList), api.Mul(allValid, Equal(api, offset, end)))
+	return result
+}
+
+// Item selects the item at index of the list. The item is invalid if the list is invalid or index is out of range.
+func (l List) Item(api frontend.API, index frontend.Variable) Item {
+	selector, inRange := Decoder(api, len(l.Items), index)
+	var offsets, payloadOffsets, payloadLengths, isLists, isValids []frontend.Variable
+	for _, item := range l.Items {
+		offsets = append(offsets, item.Offset)
+		payloadOffsets = append(payloadOffsets, item.PayloadOffset)
+		payloadLengths = append(payloadLengths, item.PayloadLength)
+		isLists = append(isLists, item.IsList)
+		isValids = append(isValids, item.IsValid)
+	}
+	n := len(l.Items)
+	return Item{
+		Offset:        EscalarProduct(api, n, offsets, selector),
+		PayloadOffset: EscalarProduct(api, n, payloadOffsets, selector),
+		PayloadLength: EscalarProduct(api, n, payloadLengths, selector),
+		IsList:        EscalarProduct(api, n, isLists, selector),
+		IsValid:       api.Mul(api.Mul(l.IsValid, inRange), EscalarProduct(api, n, isValids, selector)),
+	}
+}
+
+// ListItem decodes list and selects the item at index, see DecodeList and List.Item
+func (d *ItemDecoder) ListItem(list Item, index frontend.Variable, maxItems int) Item {
+	return d.DecodeList(list, maxItems).Item(d.api, index)
+}
+
+// Payload returns the payload nibbles of item, left aligned and zero after the payload length. The payload of a
+// valid item must fit into maxHexLen nibbles.
+func (d *ItemDecoder) Payload(item Item, maxHexLen int) []frontend.Variable {
+	api := d.api
+	api.AssertIsLessOrEqual(api.Mul(item.IsValid, item.PayloadLength), maxHexLen)
+
+	n := len(d.in)
+	// the offset of an invalid item may not fit into the shift bits
+	shifted := ShiftLeft(api, n, 0, n, d.in, api.Mul(item.IsValid, item.PayloadOffset))
+	// mask[i] is 1 while i is below the payload length
+	ends, _ := Decoder(api, maxHexLen+1, item.PayloadLength)
+	var out []frontend.Variable
+	mask := frontend.Variable(1)
+	for i := 0; i < maxHexLen; i++ {
+		mask = api.Sub(mask, ends[i])
+		// ShiftLeft wraps around, the mask also clears the nibbles wrapped from the start of the buffer
+		v := frontend.Variable(0)
+		if i < n {
+			v = api.Mul(mask, shifted[i])
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// decode decodes the prefix at offset, the item is valid if it ends at or before bound
+func (d *ItemDecoder) decode(offset, bound frontend.Variable) Item {
+	api := d.api
+	nibbles := d.read(offset, 2+2*d.maxLenBytes)
+
+	// prefix bits, least significant first
+	bits := api.ToBinary(api.Add(api.Mul(nibbles[0], 16), nibbles[1]), 8)
+	isLiteral := api.Sub(1, bits[7])
+	isList := api.Mul(bits[7], bits[6])
+	// 0xb8-0xbf and 0xf8-0xff, the low 3 bits hold the length of the length minus one
+	isLong := api.Mul(bits[7], api.Mul(bits[5], api.Mul(bits[4], bits[3])))
+	shortLength := api.FromBinary(bits[:6]...)
+
+	// big endian length of a long item, accumulated per byte and selected by the length of the length
+	var lengths []frontend.Variable
+	length := frontend.Variable(0)
+	for i := 0; i < d.maxLenBytes; i++ {
+		length = api.Add(api.Mul(length, 256), api.Add(api.Mul(nibbles[2+2*i], 16), nibbles[3+2*i]))
+		lengths = append(lengths, length)
+	}
+	lenOfLenSelector, lenOfLenValid := Decoder(api, d.maxLenBytes, api.FromBinary(bits[:3]...))
+	longLength := EscalarProduct(api, d.maxLenBytes, lengths, lenOfLenSelector)
+	lenOfLen := api.Add(api.FromBinary(bits[:3]...), 1)
+
+	var item Item
+	item.Offset = offset
+	item.IsList = isList
+	// literal: no prefix, short: 1 byte prefix, long: 1 byte prefix followed by the length
+	prefixLength := api.Mul(api.Sub(1, isLiteral), api.Add(2, api.Mul(isLong, api.Mul(2, lenOfLen))))
+	item.PayloadOffset = api.Add(offset, prefixLength)
+	itemLength := api.Add(api.Mul(isLong, api.Sub(longLength, shortLength)), shortLength)
+	item.PayloadLength = api.Add(api.Mul(isLiteral, api.Sub(1, itemLength)), itemLength)
+	item.PayloadLength = api.Mul(item.PayloadLength, 2)
+
+	lenOfLenOk := api.Sub(1, api.Mul(isLong, api.Sub(1, lenOfLenValid)))
+	item.IsValid = api.Mul(lenOfLenOk, api.Sub(1, LessThan(api, bound, item.End(api))))
+	return item
+}
+
+// read returns the width nibbles starting at offset, nibbles past the end of the buffer read as zero
+func (d *ItemDecoder) read(offset frontend.Variable, width int) []frontend.Variable {
+	api := d.api
+	n := len(d.in)
+	selector, _ := Decoder(api, n, offset)
+	var out []frontend.Variable
+	for j := 0; j < width; j++ {
+		v := frontend.Variable(0)
+		for i := 0; i+j < n; i++ {
+			v = api.Add(v, api.Mul(selector[i], d.in[i+j]))
+		}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package rlp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	gethrlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	decoderTestHexLen     = 512
+	decoderTestMaxItems   = 4
+	decoderTestPayloadLen = decoderTestHexLen
+)
+
+type ItemDecoderCircuit struct {
+	In            [decoderTestHexLen]frontend.Variable
+	Path          []frontend.Variable
+	Offset        frontend.Variable
+	PayloadOffset frontend.Variable
+	PayloadLength frontend.Variable
+	IsList        frontend.Variable
+	IsValid       frontend.Variable
+	Payload       [decoderTestPayloadLen]frontend.Variable
+}
+
+func (c *ItemDecoderCircuit) Define(api frontend.API) error {
+	d := NewItemDecoder(api, c.In[:], 2)
+	item := d.Item(0)
+	for _, index := range c.Path {
+		item = d.ListItem(item, index, decoderTestMaxItems)
+	}
+	api.AssertIsEqual(item.IsValid, c.IsValid)
+	// the location of an invalid item is not defined
+	api.AssertIsEqual(api.Mul(c.IsValid, api.Sub(item.Offset, c.Offset)), 0)
+	api.AssertIsEqual(api.Mul(c.IsValid, api.Sub(item.PayloadOffset, c.PayloadOffset)), 0)
+	api.AssertIsEqual(api.Mul(c.IsValid, api.Sub(item.PayloadLength, c.PayloadLength)), 0)
+	api.AssertIsEqual(api.Mul(c.IsValid, api.Sub(item.IsList, c.IsList)), 0)
+	payload := d.Payload(item, decoderTestPayloadLen)
+	for i := range payload {
+		api.AssertIsEqual(api.Mul(c.IsValid, api.Sub(payload[i], c.Payload[i])), 0)
+	}
+	return nil
+}
+
+type decodedItem struct {
+	offset, payloadOffset, payloadLength int // bytes
+	isList                               bool
+}
+
+// locateItem walks path through the rlp encoding buf with the go-ethereum decoder
+func locateItem(buf []byte, path []int) (decodedItem, bool) {
+	start, end := 0, len(buf)
+	for depth := 0; ; depth++ {
+		kind, content, rest, err := gethrlp.Split(buf[start:end])
+		if err != nil {
+			return decodedItem{}, false
+		}
+		itemEnd := end - len(rest)
+		item := decodedItem{offset: start, payloadOffset: itemEnd - len(content), payloadLength: len(content), isList: kind == gethrlp.List}
+		if kind == gethrlp.Byte {
+			item.payloadOffset, item.payloadLength = start, 1
+		}
+		if depth == len(path) {
+			return item, true
+		}
+		if !item.isList {
+			return decodedItem{}, false
+		}
+		// the gadget checks that the items cover the whole list
+		if _, err := gethrlp.CountValues(content); err != nil {
+			return decodedItem{}, false
+		}
+		start, end = item.payloadOffset, itemEnd
+		for i := 0; i < path[depth] && start < end; i++ {
+			_, _, rest, err := gethrlp.Split(buf[start:end])
+			if err != nil {
+				return decodedItem{}, false
+			}
+			start = end - len(rest)
+		}
+		if start == end {
+			return decodedItem{}, false
+		}
+	}
+}
+
+func bytesToNibbles(data []byte) []int {
+	var nibbles []int
+	for _, b := range data {
+		nibbles = append(nibbles, int(b>>4), int(b&0xf))
+	}
+	return nibbles
+}
+
+func newItemDecoderWitness(buf []byte, path []int, isValid bool) *ItemDecoderCircuit {
+	c := &ItemDecoderCircuit{}
+	nibbles := bytesToNibbles(buf)
+	for i := range c.In {
+		c.In[i] = 0
+		if i < len(nibbles) {
+			c.In[i] = nibbles[i]
+		}
+	}
+	for _, index := range path {
+		c.Path = append(c.Path, index)
+	}
+	c.IsValid, c.Offset, c.PayloadOffset, c.PayloadLength, c.IsList = 0, 0, 0, 0, 0
+	for i := range c.Payload {
+		c.Payload[i] = 0
+	}
+	if !isValid {
+		return c
+	}
+	item, _ := locateItem(buf, path)
+	c.IsValid = 1
+	c.Offset, c.PayloadOffset, c.PayloadLength = 2*item.offset, 2*item.payloadOffset, 2*item.payloadLength
+	if item.isList {
+		c.IsList = 1
+	}
+	payload := bytesToNibbles(buf[item.payloadOffset : item.payloadOffset+item.payloadLength])
+	for i := range payload {
+		c.Payload[i] = payload[i]
+	}
+	return c
+}
+
+func Test_ItemDecoder(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	logs := []*types.Log{
+		{
+			Address: common.HexToAddress("0x00000000000000000000000000000000deadbeef"),
+			Topics:  []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")},
+			Data:    []byte{1, 2, 3, 4},
+		},
+		{
+			Address: common.HexToAddress("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"),
+			Topics:  []common.Hash{common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")},
+			Data:    bytes.Repeat([]byte{0xab}, 70),
+		},
+	}
+	logsRlp, err := gethrlp.EncodeToBytes(logs)
+	assert.NoError(err)
+
+	accessList := types.AccessList{
+		{Address: common.HexToAddress("0x01"), StorageKeys: []common.Hash{common.HexToHash("0x02"), common.HexToHash("0x03")}},
+		{Address: common.HexToAddress("0x04")},
+	}
+	accessListRlp, err := gethrlp.EncodeToBytes(accessList)
+	assert.NoError(err)
+
+	// a literal, an empty string, a short string and an empty list
+	mixedRlp, err := gethrlp.EncodeToBytes([]interface{}{uint64(5), []byte{}, []byte("dog"), []uint64{}})
+	assert.NoError(err)
+	// the list prefix claims two bytes less, the string item overflows the list
+	truncatedRlp := append([]byte{}, mixedRlp...)
+	truncatedRlp[0] -= 2
+
+	cases := []struct {
+		name string
+		buf  []byte
+		path []int
+	}{
+		{"logs", logsRlp, nil},
+		{"list", mixedRlp, nil},
+		{"log", logsRlp, []int{1}},
+		{"address", logsRlp, []int{0, 0}},
+		{"topics", logsRlp, []int{0, 1}},
+		{"topic", logsRlp, []int{1, 1, 0}},
+		{"short data", logsRlp, []int{0, 2}},
+		{"long data", logsRlp, []int{1, 2}},
+		{"storage key", accessListRlp, []int{0, 1, 1}},
+		{"empty storage keys", accessListRlp, []int{1, 1}},
+		{"literal", mixedRlp, []int{0}},
+		{"empty string", mixedRlp, []int{1}},
+		{"string", mixedRlp, []int{2}},
+		{"empty list", mixedRlp, []int{3}},
+	}
+	for _, c := range cases {
+		_, ok := locateItem(c.buf, c.path)
+		assert.True(ok, c.name)
+		witness := newItemDecoderWitness(c.buf, c.path, true)
+		err = test.IsSolved(&ItemDecoderCircuit{Path: make([]frontend.Variable, len(c.path))}, witness, ecc.BN254.ScalarField())
+		assert.NoError(err, c.name)
+	}
+
+	invalidCases := []struct {
+		name string
+		buf  []byte
+		path []int
+	}{
+		{"log out of range", logsRlp, []int{2}},
+		{"topic out of range", logsRlp, []int{0, 1, 2}},
+		{"item of a string", logsRlp, []int{0, 0, 0}},
+		{"item of an empty list", accessListRlp, []int{1, 1, 0}},
+		{"item of an overflowing list", truncatedRlp, []int{0}},
+	}
+	for _, c := range invalidCases {
+		_, ok := locateItem(c.buf, c.path)
+		assert.False(ok, c.name)
+		witness := newItemDecoderWitness(c.buf, c.path, false)
+		err = test.IsSolved(&ItemDecoderCircuit{Path: make([]frontend.Variable, len(c.path))}, witness, ecc.BN254.ScalarField())
+		assert.NoError(err, c.name)
+		// an invalid item must not be reported as valid
+		witness.IsValid = 1
+		err = test.IsSolved(&ItemDecoderCircuit{Path: make([]frontend.Variable, len(c.path))}, witness, ecc.BN254.ScalarField())
+		assert.Error(err, c.name)
+	}
+}
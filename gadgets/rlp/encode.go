@@ -0,0 +1,131 @@
+package rlp
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// The encoders below build canonical rlp from variable length values. They work on bytes, BytesToNibbles turns an
+// encoding into the nibbles taken by the keccak gadgets. Every encoding is returned in a fixed size array that is
+// zero after its length, the length being returned next to it, so encodings can be passed to EncodeList as they
+// are. The value bytes are expected to be range checked by the caller.
+
+// EncodeUint returns the rlp encoding of value as an integer of at most maxBytes bytes. Integers are encoded as
+// big endian strings without leading zero bytes, 0 is the empty string.
+func EncodeUint(api frontend.API, value frontend.Variable, maxBytes int) ([]frontend.Variable, frontend.Variable) {
+	valueBytes, length := minimalBytes(api, value, maxBytes)
+	return EncodeString(api, valueBytes, length)
+}
+
+// EncodeString returns the rlp encoding of the length first bytes of value. A single byte below 0x80 is its own
+// encoding, other strings get a short or long string prefix.
+func EncodeString(api frontend.API, value []frontend.Variable, length frontend.Variable) ([]frontend.Variable, frontend.Variable) {
+	maxLength := len(value)
+	if maxLength == 0 {
+		panic("EncodeString: empty value array")
+	}
+	api.AssertIsLessOrEqual(length, maxLength)
+
+	// a single byte below 0x80, bit 7 is the most significant bit of the first byte
+	firstBits := api.ToBinary(value[0], 8)
+	isLiteral := api.Mul(Equal(api, length, 1), api.Sub(1, firstBits[7]))
+
+	prefix, prefixLength := encodePrefix(api, 0x80, length, maxLength)
+	for i := range prefix {
+		prefix[i] = api.Mul(api.Sub(1, isLiteral), prefix[i])
+	}
+	prefixLength = api.Mul(api.Sub(1, isLiteral), prefixLength)
+
+	return concat(api, prefix, prefixLength, maskAfter(api, value, length), length)
+}
+
+// EncodeList returns the rlp encoding of the list of the encoded items, e.g. returned by EncodeString, EncodeUint
+// or EncodeList. Every item must be zero after its length.
+func EncodeList(api frontend.API, items [][]frontend.Variable, itemLengths []frontend.Variable) ([]frontend.Variable, frontend.Variable) {
+	if len(items) != len(itemLengths) {
+		panic(fmt.Sprintf("EncodeList: %d items with %d lengths", len(items), len(itemLengths)))
+	}
+	var payload []frontend.Variable
+	payloadLength := frontend.Variable(0)
+	for i := range items {
+		payload, payloadLength = concat(api, payload, payloadLength, items[i], itemLengths[i])
+	}
+	if len(payload) == 0 {
+		return []frontend.Variable{0xc0}, 1
+	}
+
+	prefix, prefixLength := encodePrefix(api, 0xc0, payloadLength, len(payload))
+	return concat(api, prefix, prefixLength, payload, payloadLength)
+}
+
+// encodePrefix returns the string (offset 0x80) or list (offset 0xc0) prefix of a payload of length bytes, left
+// aligned and zero after the prefix length
+func encodePrefix(api frontend.API, offset int, length frontend.Variable, maxLength int) ([]frontend.Variable, frontend.Variable) {
+	if maxLength <= 55 {
+		return []frontend.Variable{api.Add(offset, length)}, 1
+	}
+
+	// a long payload has its length as minimal big endian bytes after the prefix
+	lenBytes, lenOfLen := minimalBytes(api, length, (LogCeil(maxLength)+7)/8)
+	isLong := api.Sub(1, LessThan(api, length, 56))
+
+	prefix := []frontend.Variable{api.Add(offset, length, api.Mul(isLong, api.Sub(api.Add(55, lenOfLen), length)))}
+	for _, b := range lenBytes {
+		prefix = append(prefix, api.Mul(isLong, b))
+	}
+	return prefix, api.Add(1, api.Mul(isLong, lenOfLen))
+}
+
+// minimalBytes returns the big endian bytes of value without leading zero bytes, left aligned, and their number.
+// value is range checked to maxBytes bytes.
+func minimalBytes(api frontend.API, value frontend.Variable, maxBytes int) ([]frontend.Variable, frontend.Variable) {
+	bits := api.ToBinary(value, 8*maxBytes)
+	var valueBytes []frontend.Variable
+	length := frontend.Variable(0)
+	seen := frontend.Variable(0)
+	for i := maxBytes - 1; i >= 0; i-- {
+		b := api.FromBinary(bits[8*i : 8*i+8]...)
+		valueBytes = append(valueBytes, b)
+		// 1 from the first non zero byte on
+		seen = api.Sub(1, api.Mul(api.Sub(1, seen), api.IsZero(b)))
+		length = api.Add(length, seen)
+	}
+	// the leading zero bytes wrap around to the end
+	return ShiftLeft(api, maxBytes, 0, maxBytes, valueBytes, api.Sub(maxBytes, length)), length
+}
+
+// maskAfter zeroes the elements of in from index length on
+func maskAfter(api frontend.API, in []frontend.Variable, length frontend.Variable) []frontend.Variable {
+	ends, _ := Decoder(api, len(in)+1, length)
+	var out []frontend.Variable
+	mask := frontend.Variable(1)
+	for i := range in {
+		mask = api.Sub(mask, ends[i])
+		out = append(out, api.Mul(mask, in[i]))
+	}
+	return out
+}
+
+// concat appends b to the first aLength elements of a, both zero after their lengths
+func concat(api frontend.API, a []frontend.Variable, aLength frontend.Variable, b []frontend.Variable, bLength frontend.Variable) ([]frontend.Variable, frontend.Variable) {
+	n := len(a) + len(b)
+	out := make([]frontend.Variable, n)
+	shifted := make([]frontend.Variable, n)
+	for i := 0; i < n; i++ {
+		out[i], shifted[i] = 0, 0
+		if i < len(a) {
+			out[i] = a[i]
+		}
+		if i < len(b) {
+			shifted[i] = b[i]
+		}
+	}
+	if len(a) > 0 {
+		shifted = ShiftRight(api, n, LogCeil(len(a)), shifted, aLength)
+	}
+	for i := range out {
+		out[i] = api.Add(out[i], shifted[i])
+	}
+	return out, api.Add(aLength, bLength)
+}
@@ -0,0 +1,113 @@
+package rlp
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	gethrlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	encodeMaxUintBytes = 8
+	encodeMaxStrLen    = 100
+	encodeMaxOutLen    = 256
+)
+
+// EncodeCircuit encodes the list [Uint, Str, [Uint, Str]]
+type EncodeCircuit struct {
+	Uint      frontend.Variable
+	Str       [encodeMaxStrLen]frontend.Variable
+	StrLength frontend.Variable
+	Out       [encodeMaxOutLen]frontend.Variable
+	OutLength frontend.Variable
+}
+
+func (c *EncodeCircuit) Define(api frontend.API) error {
+	uintRlp, uintRlpLength := EncodeUint(api, c.Uint, encodeMaxUintBytes)
+	strRlp, strRlpLength := EncodeString(api, c.Str[:], c.StrLength)
+	inner, innerLength := EncodeList(api, [][]frontend.Variable{uintRlp, strRlp}, []frontend.Variable{uintRlpLength, strRlpLength})
+	out, outLength := EncodeList(api,
+		[][]frontend.Variable{uintRlp, strRlp, inner},
+		[]frontend.Variable{uintRlpLength, strRlpLength, innerLength})
+
+	api.AssertIsEqual(outLength, c.OutLength)
+	for i := range out {
+		if i < encodeMaxOutLen {
+			api.AssertIsEqual(out[i], c.Out[i])
+		} else {
+			api.AssertIsEqual(out[i], 0)
+		}
+	}
+	return nil
+}
+
+func newEncodeWitness(u uint64, str []byte) (*EncodeCircuit, error) {
+	encoded, err := gethrlp.EncodeToBytes([]interface{}{u, str, []interface{}{u, str}})
+	if err != nil {
+		return nil, err
+	}
+	witness := &EncodeCircuit{Uint: new(big.Int).SetUint64(u), StrLength: len(str), OutLength: len(encoded)}
+	for i := range witness.Str {
+		witness.Str[i] = 0
+		if i < len(str) {
+			witness.Str[i] = str[i]
+		}
+	}
+	for i := range witness.Out {
+		witness.Out[i] = 0
+		if i < len(encoded) {
+			witness.Out[i] = encoded[i]
+		}
+	}
+	return witness, nil
+}
+
+func Test_Encode(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	cases := []struct {
+		u   uint64
+		str []byte
+	}{
+		{0, nil},
+		{1, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x80}},
+		{0x1234, []byte("dog")},
+		{0xffffffffffffffff, bytes.Repeat([]byte{0xab}, 55)},
+		{0x0100, bytes.Repeat([]byte{0xcd}, 56)},
+		{42, bytes.Repeat([]byte{0xef}, encodeMaxStrLen)},
+	}
+	for _, c := range cases {
+		witness, err := newEncodeWitness(c.u, c.str)
+		assert.NoError(err)
+		err = test.IsSolved(&EncodeCircuit{}, witness, ecc.BN254.ScalarField())
+		assert.NoError(err, "%d %x", c.u, c.str)
+	}
+
+	// a non minimal integer, 0x05 encoded as the string 0x0005, is not what the encoder produces
+	witness, err := newEncodeWitness(5, []byte("dog"))
+	assert.NoError(err)
+	nonCanonical, err := gethrlp.EncodeToBytes([]interface{}{[]byte{0, 5}, []byte("dog"), []interface{}{[]byte{0, 5}, []byte("dog")}})
+	assert.NoError(err)
+	for i := range witness.Out {
+		witness.Out[i] = 0
+		if i < len(nonCanonical) {
+			witness.Out[i] = nonCanonical[i]
+		}
+	}
+	witness.OutLength = len(nonCanonical)
+	err = test.IsSolved(&EncodeCircuit{}, witness, ecc.BN254.ScalarField())
+	assert.Error(err)
+
+	// the value does not fit into the max integer bytes
+	witness, err = newEncodeWitness(5, nil)
+	assert.NoError(err)
+	witness.Uint = new(big.Int).Lsh(big.NewInt(1), 8*encodeMaxUintBytes)
+	err = test.IsSolved(&EncodeCircuit{}, witness, ecc.BN254.ScalarField())
+	assert.Error(err)
+}
@@ -0,0 +1,81 @@
+// Package native computes the rlp gadgets out of circuit
+package native
+
+import (
+	"fmt"
+)
+
+// Nibbles splits bytes into big endian nibbles, the layout of the rlp gadget inputs
+func Nibbles(data []byte) []byte {
+	var nibbles []byte
+	for _, b := range data {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	return nibbles
+}
+
+// Split decodes the prefix of the rlp item at the start of data and returns whether it is a list, its payload and
+// the bytes following it. Like the gadgets, and unlike geth, it accepts non canonical prefixes, e.g. a long prefix
+// for a short payload.
+func Split(data []byte) (isList bool, payload []byte, rest []byte, err error) {
+	if len(data) == 0 {
+		return false, nil, nil, fmt.Errorf("rlp: empty input")
+	}
+	prefix := int(data[0])
+	var offset, length int
+	switch {
+	case prefix < 0x80:
+		return false, data[:1], data[1:], nil
+	case prefix < 0xb8:
+		offset, length = 1, prefix-0x80
+	case prefix < 0xc0:
+		offset, length, err = longLength(data, prefix-0xb7)
+	case prefix < 0xf8:
+		isList, offset, length = true, 1, prefix-0xc0
+	default:
+		isList = true
+		offset, length, err = longLength(data, prefix-0xf7)
+	}
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if offset+length > len(data) {
+		return false, nil, nil, fmt.Errorf("rlp: item of %d bytes overflows the %d input bytes", offset+length, len(data))
+	}
+	return isList, data[offset : offset+length], data[offset+length:], nil
+}
+
+// longLength decodes the big endian length following a long prefix
+func longLength(data []byte, lenOfLen int) (offset int, length int, err error) {
+	if 1+lenOfLen > len(data) {
+		return 0, 0, fmt.Errorf("rlp: length of %d bytes overflows the input", lenOfLen)
+	}
+	for _, b := range data[1 : 1+lenOfLen] {
+		length = length<<8 | int(b)
+	}
+	return 1 + lenOfLen, length, nil
+}
+
+// ArrayCheck is rlp.ArrayCheck (not strict) on bytes. It decodes the list at the start of data, whose numFields
+// fields must be strings or empty lists filling the list exactly, and returns the total length of the list with
+// its prefix and the decoded fields. Bytes after the list are ignored.
+func ArrayCheck(data []byte, numFields int) (ok bool, totalLen int, fields [][]byte) {
+	isList, payload, rest, err := Split(data)
+	if err != nil || !isList {
+		return false, 0, nil
+	}
+	totalLen = len(data) - len(rest)
+	for i := 0; i < numFields; i++ {
+		var field []byte
+		isList, field, payload, err = Split(payload)
+		// the empty list is the only list accepted as a field
+		if err != nil || (isList && len(field) > 0) {
+			return false, 0, nil
+		}
+		fields = append(fields, field)
+	}
+	if len(payload) != 0 {
+		return false, 0, nil
+	}
+	return true, totalLen, fields
+}
@@ -187,6 +187,15 @@ type ArrayCheck struct {
 	ArrayPrefixMaxHexLen int
 	FieldMinHexLen       []int
 	FieldMaxHexLen       []int
+	// Strict makes RlpArrayCheck reject encodings go-ethereum would not produce: long prefixes for lengths below
+	// 56 bytes, lengths with leading zero bytes and single bytes below 0x80 behind a 0x81 prefix
+	Strict bool
+	// FieldIsInteger marks the fields holding integers, in strict mode their value must not have leading zero
+	// bytes, including the literal 0x00 in place of the empty string for 0. nil marks no field.
+	FieldIsInteger []bool
+	// Leading makes RlpArrayCheck accept a list holding more fields than MaxFields, only its MaxFields first fields
+	// are decoded and its length must cover them
+	Leading bool
 }
 
 // RlpArrayCheck rlp array length checker (1 layer data in trie), return the check result,
@@ -227,6 +236,11 @@ func (a *ArrayCheck) RlpArrayCheck(api frontend.API, in []frontend.Variable) (
 
 	totalRlpHexLen = api.Add(2, arrayRlpPrefix1HexLen, totalArrayHexLen)
 
+	canonical := frontend.Variable(1)
+	if a.Strict {
+		canonical = canonicalLength(api, isBig, totalArrayHexLen, [2]frontend.Variable{in[2], in[3]})
+	}
+
 	//shiftToField[nFields]
 	var shiftToFieldOuts [][]frontend.Variable
 	var shiftToFieldRlpsOuts [][]frontend.Variable
@@ -296,18 +310,65 @@ func (a *ArrayCheck) RlpArrayCheck(api frontend.API, in []frontend.Variable) (
 
 		check = api.Add(check, fieldPrefixIsValid)
 
+		if a.Strict {
+			fieldRlp := shiftToFieldRlpsOuts[idx]
+			canonical = api.Mul(canonical, canonicalLength(api, fieldPrefixIsBig, fieldHexLen, [2]frontend.Variable{fieldRlp[2], fieldRlp[3]}))
+
+			// 0x81 followed by a byte below 0x80
+			isSingleByte := api.IsZero(api.Sub(api.Add(api.Mul(16, fieldRlp[0]), fieldRlp[1]), 0x81))
+			canonical = api.Mul(canonical, api.Sub(1, api.Mul(isSingleByte, LessThan(api, fieldRlp[2], 8))))
+
+			if idx < len(a.FieldIsInteger) && a.FieldIsInteger[idx] {
+				// a non empty integer starting with a zero byte
+				leadingZero := api.IsZero(api.Add(api.Mul(16, fields[idx][0]), fields[idx][1]))
+				isEmpty := api.IsZero(fieldHexLen)
+				canonical = api.Mul(canonical, api.Sub(1, api.Mul(api.Sub(1, isEmpty), leadingZero)))
+			}
+		}
+
 		//  lenSum = lenSum + 2 - 2 * fieldPrefix[idx].isLiteral + fieldRlpPrefix1HexLen[idx] + fieldHexLen[idx];
 		lenSum = api.Sub(api.Add(lenSum, 2), api.Mul(2, fieldPrefixIsLiteral))
 		lenSum = api.Add(lenSum, fieldRlpPrefix1HexLen, fieldHexLen)
 	}
 
 	lenCheck := api.IsZero(api.Sub(totalArrayHexLen, lenSum))
+	if a.Leading {
+		lenCheck = LessThan(api, lenSum, api.Add(totalArrayHexLen, 1))
+	}
 
 	out = api.IsZero(api.Sub(api.Add(check, lenCheck), api.Add(a.MaxFields, 2)))
+	out = api.Mul(out, canonical)
 
 	return
 }
 
+// canonicalLength returns 0 if a long prefix (isBig) is used for a payload below 56 bytes or if the first length
+// byte is zero, hexLen is the decoded payload length
+func canonicalLength(api frontend.API, isBig frontend.Variable, hexLen frontend.Variable, firstLenByte [2]frontend.Variable) frontend.Variable {
+	tooShort := LessThan(api, hexLen, 2*56)
+	leadingZero := api.IsZero(api.Add(api.Mul(16, firstLenByte[0]), firstLenByte[1]))
+	nonCanonical := api.Sub(api.Add(tooShort, leadingZero), api.Mul(tooShort, leadingZero))
+	return api.Sub(1, api.Mul(isBig, nonCanonical))
+}
+
+// BlkHeaderMaxFields is the number of fields of a block header as of Prague, whose last field is requestsHash.
+// Headers of earlier forks end with fewer fields: 16 from London, 17 from Shanghai and 20 from Cancun.
+const BlkHeaderMaxFields = 21
+
+// NewBlkHeaderArrayCheck returns the BlkHeaderRlpCheck config of block headers of at most maxHexLen nibbles with
+// up to BlkHeaderMaxFields fields. The number, gas and time fields are uint64, the difficulty and the base fee
+// uint256 and the extra data at most 32 bytes. The lengths of the fields after the base fee are 0 in headers of the
+// forks before them, hence their min lengths of 0.
+func NewBlkHeaderArrayCheck(maxHexLen int) *ArrayCheck {
+	return &ArrayCheck{
+		MaxHexLen:            maxHexLen,
+		MaxFields:            BlkHeaderMaxFields,
+		ArrayPrefixMaxHexLen: 4,
+		FieldMinHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 0, 0, 0, 0, 0, 0, 64, 16, 0, 0, 0, 0, 0, 0},
+		FieldMaxHexLen:       []int{64, 64, 40, 64, 64, 64, 512, 64, 16, 16, 16, 16, 64, 64, 16, 64, 64, 16, 16, 64, 64},
+	}
+}
+
 // BlkHeaderRlpCheck block header rlp length checker (1 layer data in trie), return the check result,
 // the total length of the array length with rlp prefix in hex,
 // array of each field hex length with rlp prefix
@@ -418,13 +479,15 @@ func (a *ArrayCheck) BlkHeaderRlpCheck(api frontend.API, in []frontend.Variable,
 		check = api.Add(check, fieldPrefixIsValid)
 
 		//  lenSum = lenSum + 2 - 2 * fieldPrefix[idx].isLiteral + fieldRlpPrefix1HexLen[idx] + fieldHexLen[idx];
-		lenSum = api.Sub(api.Add(lenSum, 2), api.Mul(2, fieldPrefixIsLiteral))
-		lenSum = api.Add(lenSum, fieldRlpPrefix1HexLen, fieldHexLen)
+		// fields past FieldsNum parse the bytes after the list, e.g. the keccak padding, and count for nothing
+		fieldRlpHexLen := api.Sub(api.Add(2, fieldRlpPrefix1HexLen, fieldHexLen), api.Mul(2, fieldPrefixIsLiteral))
+		lenSum = api.Add(lenSum, api.Mul(fieldRlpHexLen, idxLessThanMaxFields))
 	}
 
 	lenCheck := api.IsZero(api.Sub(totalArrayHexLen, lenSum))
+	fieldsNumCheck := LessThan(api, FieldsNum, a.MaxFields+1)
 
-	out = api.IsZero(api.Sub(api.Add(check, lenCheck), api.Add(a.MaxFields, 2)))
+	out = api.IsZero(api.Sub(api.Add(check, lenCheck, fieldsNumCheck), api.Add(a.MaxFields, 3)))
 
 	return
 }
@@ -50,52 +50,15 @@ func EscalarProduct(api frontend.API, width int, inputA []frontend.Variable, inp
 	return
 }
 
-func Keccak256AsNibbles(
-	api frontend.API,
-	inLen frontend.Variable, // if input is a padded rlp data, the inlen is the decoded rlp length without padding part
-	blocks [keccak.MAX_ROUNDS][17]frontend.Variable,
-	roundIndex frontend.Variable,
-) *KeccakOrLiteralHex {
-
-	var h = keccak.Keccak256(api, blocks, roundIndex)
-
-	// 64 nibble
-	var nibbles [64]frontend.Variable
-	for i, r := range h {
-		rBits := api.ToBinary(r, 64)
-
-		for j := 0; j < 16; j++ {
-			nibbles[i*16+j] = api.FromBinary(rBits[j*4 : (j+1)*4]...)
-		}
-	}
-
-	var results [64]frontend.Variable
-	for i := 0; i < 32; i++ {
-		results[i*2] = nibbles[2*i+1]
-		results[i*2+1] = nibbles[2*i]
-	}
-
-	// inLen <= 62 returns 1
-	isShort := LessThan(api, inLen, 63)
-
-	outLen := api.Mul(isShort, api.Sub(inLen, 64))
-	outLen = api.Add(outLen, 64)
-
-	return &KeccakOrLiteralHex{
-		Output:       results,
-		OutputLength: outLen,
-	}
+// Keccak256Nibbles returns the keccak256 hash of the first inLen nibbles of data, the output length is inLen for data
+// shorter than a hash, which is embedded instead of hashed. The padding is applied in circuit, data is a buffer of
+// the padded size of its longest rlp, see keccak.Keccak256Nibbles.
+func Keccak256Nibbles(api frontend.API, data []frontend.Variable, inLen frontend.Variable) *KeccakOrLiteralHex {
+	return keccakOrLiteralHex(api, inLen, keccak.Keccak256Nibbles(api, data, inLen))
 }
 
-func Keccak256ForNormalTransactionAsNibbles(
-	api frontend.API,
-	inLen frontend.Variable, // if input is a padded rlp data, the inlen is the decoded rlp length without padding part
-	blocks [keccak.NORMAL_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable,
-	roundIndex frontend.Variable,
-) *KeccakOrLiteralHex {
-	var h = keccak.Keccak256ForNormalTransaction(api, blocks, roundIndex)
-
-	// 64 nibble
+// lanesToNibbles converts the little endian keccak lanes h into big endian nibbles
+func lanesToNibbles(api frontend.API, h [4]frontend.Variable) [64]frontend.Variable {
 	var nibbles [64]frontend.Variable
 	for i, r := range h {
 		rBits := api.ToBinary(r, 64)
@@ -110,44 +73,12 @@ func Keccak256ForNormalTransactionAsNibbles(
 		results[i*2] = nibbles[2*i+1]
 		results[i*2+1] = nibbles[2*i]
 	}
-
-	// inLen <= 62 returns 1
-	isShort := LessThan(api, inLen, 63)
-
-	outLen := api.Mul(isShort, api.Sub(inLen, 64))
-	outLen = api.Add(outLen, 64)
-
-	return &KeccakOrLiteralHex{
-		Output:       results,
-		OutputLength: outLen,
-	}
+	return results
 }
 
-func Keccak256ForMaxTransactionAsNibbles(
-	api frontend.API,
-	inLen frontend.Variable, // if input is a padded rlp data, the inlen is the decoded rlp length without padding part
-	blocks [keccak.MAX_TRANSACTION_LEAF_ROUNDS][17]frontend.Variable,
-	roundIndex frontend.Variable,
-) *KeccakOrLiteralHex {
-
-	var h = keccak.Keccak256ForMaxTransaction(api, blocks, roundIndex)
-
-	// 64 nibble
-	var nibbles [64]frontend.Variable
-	for i, r := range h {
-		rBits := api.ToBinary(r, 64)
-
-		for j := 0; j < 16; j++ {
-			nibbles[i*16+j] = api.FromBinary(rBits[j*4 : (j+1)*4]...)
-		}
-	}
-
-	var results [64]frontend.Variable
-	for i := 0; i < 32; i++ {
-		results[i*2] = nibbles[2*i+1]
-		results[i*2+1] = nibbles[2*i]
-	}
-
+// keccakOrLiteralHex returns the hash nibbles with the output length inLen for data shorter than a hash, which is
+// embedded instead of hashed
+func keccakOrLiteralHex(api frontend.API, inLen frontend.Variable, hash [64]frontend.Variable) *KeccakOrLiteralHex {
 	// inLen <= 62 returns 1
 	isShort := LessThan(api, inLen, 63)
 
@@ -155,7 +86,7 @@ func Keccak256ForMaxTransactionAsNibbles(
 	outLen = api.Add(outLen, 64)
 
 	return &KeccakOrLiteralHex{
-		Output:       results,
+		Output:       hash,
 		OutputLength: outLen,
 	}
 }
@@ -233,7 +164,7 @@ func (subArray *SubArray) SubArray(api frontend.API, in []frontend.Variable, fro
 
 	var shifts [][]frontend.Variable
 	for i := 0; i < subArray.nIn; i++ {
-		shifts = append(shifts, make([]frontend.Variable, 7))
+		shifts = append(shifts, make([]frontend.Variable, subArray.nInBits))
 	}
 	for i := 0; i < subArray.nInBits; i++ {
 		for j := 0; j < subArray.nIn; j++ {
@@ -1,6 +1,8 @@
 package sha256
 
 import (
+	"fmt"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 )
@@ -142,3 +144,92 @@ func (d *digest) checkSum() []frontend.Variable {
 	}
 	return dv
 }
+
+// Sha256 returns the sha256 hash of the length first bytes of data as 32 bytes. The padding is applied in circuit,
+// so data is passed unpadded and length may be anything up to maxBytes = len(data). The compression runs on
+// (maxBytes+8)/64 + 1 blocks and the state after the block holding the end of the padding is returned.
+func Sha256(api frontend.API, maxBytes int, data []frontend.Variable, length frontend.Variable) (out [32]frontend.Variable) {
+	if len(data) != maxBytes {
+		panic(fmt.Sprintf("Sha256: data length %d does not match max bytes %d", len(data), maxBytes))
+	}
+	api.AssertIsLessOrEqual(length, maxBytes)
+	blocks, isLastBlock := padVariable(api, data, length)
+
+	d := New(api)
+	var h [8]xuint32
+	for k := range h {
+		for b := range h[k] {
+			h[k][b] = 0
+		}
+	}
+	for r, block := range blocks {
+		blockGeneric(&d, block)
+		for k := range h {
+			for b := range h[k] {
+				h[k][b] = api.Add(h[k][b], api.Mul(isLastBlock[r], d.h[k][b]))
+			}
+		}
+	}
+
+	var digest [32]xuint8
+	for k := range h {
+		PutUint32(api, digest[4*k:], h[k])
+	}
+	u8api := newUint8API(api)
+	for i := range out {
+		out[i] = u8api.fromUint8(digest[i])
+	}
+	return
+}
+
+// padVariable splits the length first bytes of data padded in circuit into blocks, isLastBlock is 1 for the block
+// ending with the 8 bytes of the bit length
+func padVariable(api frontend.API, data []frontend.Variable, length frontend.Variable) ([][]xuint8, []frontend.Variable) {
+	const lenBytes = 8
+	maxBlocks := (len(data)+lenBytes)/chunk + 1
+	paddedLen := maxBlocks * chunk
+
+	// isEnd[i] is 1 at the 0x80 padding byte, inData[i] is 1 before it
+	isEnd := make([]frontend.Variable, paddedLen)
+	inData := make([]frontend.Variable, paddedLen)
+	seenEnd := frontend.Variable(0)
+	for i := range isEnd {
+		isEnd[i] = api.IsZero(api.Sub(length, i))
+		seenEnd = api.Add(seenEnd, isEnd[i])
+		inData[i] = api.Sub(1, seenEnd)
+	}
+	// the big endian bit length, length <= len(data) fits easily
+	lenBits := api.ToBinary(api.Mul(length, 8), 8*lenBytes)
+	var bitLen [lenBytes]frontend.Variable
+	for k := range bitLen {
+		bitLen[k] = api.FromBinary(lenBits[8*(lenBytes-1-k) : 8*(lenBytes-k)]...)
+	}
+
+	u8api := newUint8API(api)
+	var blocks [][]xuint8
+	var isLastBlock []frontend.Variable
+	for r := 0; r < maxBlocks; r++ {
+		// the 0x80 byte fits into the block before the bit length
+		isLast := frontend.Variable(0)
+		for i := r*chunk - lenBytes; i < (r+1)*chunk-lenBytes; i++ {
+			if i >= 0 {
+				isLast = api.Add(isLast, isEnd[i])
+			}
+		}
+		isLastBlock = append(isLastBlock, isLast)
+
+		var block []xuint8
+		for i := r * chunk; i < (r+1)*chunk; i++ {
+			padded := api.Mul(0x80, isEnd[i])
+			if i < len(data) {
+				padded = api.Add(padded, api.Mul(inData[i], data[i]))
+			}
+			if k := i%chunk - (chunk - lenBytes); k >= 0 {
+				padded = api.Add(padded, api.Mul(isLast, bitLen[k]))
+			}
+			block = append(block, u8api.asUint8(padded))
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, isLastBlock
+}
@@ -0,0 +1,97 @@
+package slot
+
+import (
+	"github.com/celer-network/brevis-circuits/gadgets/conv"
+	"github.com/celer-network/brevis-circuits/gadgets/keccak"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Word is a 32-byte evm word split into its big endian upper and lower 128 bits, the same layout the circuits
+// use for Slot and SlotValue public inputs
+type Word = [2]frontend.Variable
+
+// MappingSlot derives the slot of mapping[key] for a mapping declared at slot, keccak256(key . slot). The key
+// must already be abi encoded into a word, e.g. left padded for addresses and unsigned integers.
+func MappingSlot(api frontend.API, key Word, slot Word) Word {
+	return conv.Bits2Uint128s(api, keccak256Words(api, key, slot))
+}
+
+// NestedMappingSlot derives the slot of mapping[keys[0]][keys[1]]... for a mapping declared at slot
+func NestedMappingSlot(api frontend.API, keys []Word, slot Word) Word {
+	for _, key := range keys {
+		slot = MappingSlot(api, key, slot)
+	}
+	return slot
+}
+
+// DynamicArraySlot derives the slot of array[index] for a dynamic array declared at slot, keccak256(slot) +
+// index * elementSlots. elementSlots is the number of slots one element occupies, arrays packing several elements
+// into one slot are not supported. index must be less than 2^64.
+func DynamicArraySlot(api frontend.API, slot Word, index frontend.Variable, elementSlots int) Word {
+	api.ToBinary(index, 64)
+	base := conv.Bits2Uint128s(api, keccak256Words(api, slot))
+	return addToWord(api, base, api.Mul(index, elementSlots), 64+bitLen(elementSlots))
+}
+
+// StructFieldSlot derives the slot of the struct member or fixed size array element that is offset slots into
+// the value stored at slot
+func StructFieldSlot(api frontend.API, slot Word, offset int) Word {
+	return addToWord(api, slot, offset, bitLen(offset))
+}
+
+// TrieKey returns the storage trie key of slot, keccak256(slot), as the nibbles CheckEthStorageProof takes as
+// slotHash
+func TrieKey(api frontend.API, slot Word) [64]frontend.Variable {
+	bits := keccak256Words(api, slot)
+	var nibbles [64]frontend.Variable
+	for i := 0; i < 32; i++ {
+		// keccak bits are little endian within each byte
+		nibbles[2*i] = api.FromBinary(bits[8*i+4 : 8*i+8]...)
+		nibbles[2*i+1] = api.FromBinary(bits[8*i : 8*i+4]...)
+	}
+	return nibbles
+}
+
+// keccak256Words hashes the concatenation of at most 4 words, all fitting into one keccak round
+func keccak256Words(api frontend.API, words ...Word) [256]frontend.Variable {
+	if len(words) > 4 {
+		panic("keccak256Words: more than 4 words do not fit into one keccak round")
+	}
+	var data []frontend.Variable
+	for _, word := range words {
+		for _, half := range word {
+			bits := api.ToBinary(half, 128)
+			// big endian bytes, little endian bits within each byte
+			for i := 15; i >= 0; i-- {
+				data = append(data, bits[8*i:8*i+8]...)
+			}
+		}
+	}
+	// the input length is fixed, so the 101 padding is hardwired
+	data = append(data, 1)
+	for len(data) < 1087 {
+		data = append(data, 0)
+	}
+	data = append(data, 1)
+	return keccak.NewHasher(api).Keccak256Bits(1, 0, data)
+}
+
+// addToWord adds v < 2^vBits to w modulo 2^256
+func addToWord(api frontend.API, w Word, v frontend.Variable, vBits int) Word {
+	if vBits < 128 {
+		vBits = 128
+	}
+	loBits := api.ToBinary(api.Add(w[1], v), vBits+1)
+	carry := api.FromBinary(loBits[128:]...)
+	hiBits := api.ToBinary(api.Add(w[0], carry), vBits+1)
+	return Word{api.FromBinary(hiBits[:128]...), api.FromBinary(loBits[:128]...)}
+}
+
+func bitLen(v int) int {
+	n := 0
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+	return n
+}
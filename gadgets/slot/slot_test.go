@@ -0,0 +1,197 @@
+package slot
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/brevis-circuits/common"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt"
+	"github.com/celer-network/brevis-circuits/gadgets/mpt/witness"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+type slotCircuit struct {
+	User          Word
+	Spender       Word
+	Index         frontend.Variable
+	Mapping       Word `gnark:",public"`
+	NestedMapping Word `gnark:",public"`
+	ArrayElement  Word `gnark:",public"`
+	StructField   Word `gnark:",public"`
+	TrieKey       [64]frontend.Variable
+}
+
+func (c *slotCircuit) Define(api frontend.API) error {
+	// balanceOf at slot 3, allowance at slot 4, a dynamic array of 3 slot structs at slot 5
+	mapping := MappingSlot(api, c.User, Word{0, 3})
+	nested := NestedMappingSlot(api, []Word{c.User, c.Spender}, Word{0, 4})
+	element := DynamicArraySlot(api, Word{0, 5}, c.Index, 3)
+	field := StructFieldSlot(api, element, 2)
+	trieKey := TrieKey(api, mapping)
+
+	for i := 0; i < 2; i++ {
+		api.AssertIsEqual(mapping[i], c.Mapping[i])
+		api.AssertIsEqual(nested[i], c.NestedMapping[i])
+		api.AssertIsEqual(element[i], c.ArrayElement[i])
+		api.AssertIsEqual(field[i], c.StructField[i])
+	}
+	for i := 0; i < 64; i++ {
+		api.AssertIsEqual(trieKey[i], c.TrieKey[i])
+	}
+	return nil
+}
+
+func TestSlotDerivation(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	user := ethcommon.HexToAddress("0x881D40237659C251811CEC9c364ef91dC08D300C")
+	spender := ethcommon.HexToAddress("0x690b9a9e9aa1c9db991c7721a92d351db4fac990")
+	index := uint64(7)
+
+	mapping := mappingSlot(user.Hash(), ethcommon.BigToHash(big.NewInt(3)))
+	nested := mappingSlot(spender.Hash(), mappingSlot(user.Hash(), ethcommon.BigToHash(big.NewInt(4))))
+	element := addToHash(crypto.Keccak256Hash(ethcommon.BigToHash(big.NewInt(5)).Bytes()), index*3)
+	field := addToHash(element, 2)
+
+	w := &slotCircuit{
+		User:          toWord(user.Hash()),
+		Spender:       toWord(spender.Hash()),
+		Index:         index,
+		Mapping:       toWord(mapping),
+		NestedMapping: toWord(nested),
+		ArrayElement:  toWord(element),
+		StructField:   toWord(field),
+	}
+	copy(w.TrieKey[:], witness.NibblesToVariables(witness.BytesToNibbles(crypto.Keccak256(mapping.Bytes())), 64))
+
+	err := test.IsSolved(&slotCircuit{}, w, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
+
+type structFieldCircuit struct {
+	Slot  Word
+	Field Word `gnark:",public"`
+}
+
+func (c *structFieldCircuit) Define(api frontend.API) error {
+	field := StructFieldSlot(api, c.Slot, 2)
+	api.AssertIsEqual(field[0], c.Field[0])
+	api.AssertIsEqual(field[1], c.Field[1])
+	return nil
+}
+
+func TestStructFieldSlotCarry(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	max128 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	// the lower half carries into the upper half
+	w := &structFieldCircuit{Slot: Word{5, max128}, Field: Word{6, 1}}
+	assert.NoError(test.IsSolved(&structFieldCircuit{}, w, ecc.BN254.ScalarField()))
+	// slots wrap around modulo 2^256
+	w = &structFieldCircuit{Slot: Word{max128, max128}, Field: Word{0, 1}}
+	assert.NoError(test.IsSolved(&structFieldCircuit{}, w, ecc.BN254.ScalarField()))
+}
+
+type mappingStorageProofCircuit struct {
+	User                 Word              `gnark:",public"`
+	Balance              Word              `gnark:",public"`
+	Amount               frontend.Variable `gnark:",public"`
+	StorageRoot          [64]frontend.Variable
+	ValueRlp             [66]frontend.Variable
+	KeyFragmentStarts    [mpt.StorageMPTMaxDepth]frontend.Variable
+	LeafRlp              [mpt.StorageLeafMaxBlockHexLen]frontend.Variable
+	LeafPathPrefixLength frontend.Variable
+	NodeRlp              [mpt.StorageMPTMaxDepth - 1][mpt.BranchNodeMaxBlockSize]frontend.Variable
+	NodePathPrefixLength [mpt.StorageMPTMaxDepth - 1]frontend.Variable
+	NodeTypes            [mpt.StorageMPTMaxDepth - 1]frontend.Variable
+	Depth                frontend.Variable
+}
+
+func (c *mappingStorageProofCircuit) Define(api frontend.API) error {
+	var nodeRlp [][]frontend.Variable
+	for i := range c.NodeRlp {
+		nodeRlp = append(nodeRlp, c.NodeRlp[i][:])
+	}
+	slotHash := TrieKey(api, MappingSlot(api, c.User, Word{0, 3}))
+	result := mpt.CheckEthStorageProof(api, mpt.StorageMPTMaxDepth, c.StorageRoot, slotHash, c.ValueRlp, c.KeyFragmentStarts[:],
+		c.LeafRlp[:], c.LeafPathPrefixLength, nodeRlp, c.NodePathPrefixLength[:],
+		c.NodeTypes[:], c.Depth)
+	api.AssertIsEqual(result.Output, 1)
+	api.AssertIsEqual(result.SlotValue[0], c.Balance[0])
+	api.AssertIsEqual(result.SlotValue[1], c.Balance[1])
+	api.AssertIsEqual(result.SlotUint256[0], 0)
+	api.AssertIsEqual(result.SlotUint256[1], c.Amount)
+	return nil
+}
+
+func TestMappingSlotStorageProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// a storage trie holding balanceOf[user] at mapping slot 3 for a few users
+	tr := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	var users []ethcommon.Address
+	for i := 0; i < 32; i++ {
+		user := ethcommon.BytesToAddress(crypto.Keccak256(big.NewInt(int64(i)).Bytes()))
+		users = append(users, user)
+		slot := mappingSlot(user.Hash(), ethcommon.BigToHash(big.NewInt(3)))
+		value, _ := rlp.EncodeToBytes(big.NewInt(int64(i+1) * 1e15).Bytes())
+		tr.Update(crypto.Keccak256(slot.Bytes()), value)
+	}
+	root := tr.Hash()
+
+	user := users[17]
+	slotHash := crypto.Keccak256(mappingSlot(user.Hash(), ethcommon.BigToHash(big.NewInt(3))).Bytes())
+	proofWriter := &common.ProofWriter{}
+	assert.NoError(tr.Prove(slotHash, 0, proofWriter))
+	p, err := witness.NewProof(proofWriter.Values, witness.BytesToNibbles(slotHash), 64, mpt.StorageMPTMaxDepth)
+	assert.NoError(err)
+	leafRlp, err := p.LeafRlp(mpt.StorageLeafMaxBlockHexLen)
+	assert.NoError(err)
+
+	// the circuit decodes the slot value left aligned
+	var balance [32]byte
+	copy(balance[:], big.NewInt(18e15).Bytes())
+	w := &mappingStorageProofCircuit{
+		User:                 toWord(user.Hash()),
+		Balance:              Word{balance[:16], balance[16:]},
+		Amount:               big.NewInt(18e15),
+		LeafPathPrefixLength: p.Leaf.PathPrefixLength,
+		Depth:                p.Depth,
+	}
+	copy(w.StorageRoot[:], witness.NibblesToVariables(witness.BytesToNibbles(root.Bytes()), 64))
+	copy(w.ValueRlp[:], witness.NibblesToVariables(witness.BytesToNibbles(p.Leaf.Value), 66))
+	copy(w.KeyFragmentStarts[:], p.KeyFragmentStarts)
+	copy(w.LeafRlp[:], leafRlp)
+	for i := range w.NodeRlp {
+		copy(w.NodeRlp[i][:], p.NodeRlp[i])
+	}
+	copy(w.NodePathPrefixLength[:], p.NodePathPrefixLength)
+	copy(w.NodeTypes[:], p.NodeTypes)
+
+	err = test.IsSolved(&mappingStorageProofCircuit{}, w, ecc.BN254.ScalarField())
+	assert.NoError(err)
+
+	w.User = toWord(users[16].Hash())
+	err = test.IsSolved(&mappingStorageProofCircuit{}, w, ecc.BN254.ScalarField())
+	assert.Error(err)
+}
+
+func mappingSlot(key ethcommon.Hash, slot ethcommon.Hash) ethcommon.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), slot.Bytes())
+}
+
+func addToHash(h ethcommon.Hash, v uint64) ethcommon.Hash {
+	sum := new(big.Int).Add(h.Big(), new(big.Int).SetUint64(v))
+	return ethcommon.BigToHash(sum.Mod(sum, new(big.Int).Lsh(big.NewInt(1), 256)))
+}
+
+func toWord(h ethcommon.Hash) Word {
+	return Word{new(big.Int).SetBytes(h[:16]), new(big.Int).SetBytes(h[16:])}
+}
@@ -0,0 +1,47 @@
+package slot
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Solidity packs state variables smaller than 32 bytes into one slot from right to left, so a variable at byte
+// offset o occupies the bytes [32-o-size, 32-o) of the big endian slot value. The helpers below take the value as
+// decoded by mpt.DecodeSlotValue and range check both limbs to 128 bits.
+
+// ExtractBytes returns the size bytes packed at byte offset offset of value as a number. size must be at most 31
+// so that the result fits into the scalar field.
+func ExtractBytes(api frontend.API, value Word, offset, size int) frontend.Variable {
+	if size <= 0 || size > 31 || offset < 0 || offset+size > 32 {
+		panic(fmt.Sprintf("ExtractBytes: invalid offset %d and size %d", offset, size))
+	}
+	bits := wordBits(api, value)
+	return api.FromBinary(bits[8*offset : 8*(offset+size)]...)
+}
+
+// ExtractAddress returns the address stored in the low 20 bytes of value
+func ExtractAddress(api frontend.API, value Word) frontend.Variable {
+	return ExtractBytes(api, value, 0, 20)
+}
+
+// ExtractUint128s returns the upper and lower uint128 halves of value, e.g. two uint128 variables packed into one
+// slot, after checking that both limbs fit into 128 bits
+func ExtractUint128s(api frontend.API, value Word) (hi, lo frontend.Variable) {
+	api.ToBinary(value[0], 128)
+	api.ToBinary(value[1], 128)
+	return value[0], value[1]
+}
+
+// ExtractBool returns the bool packed at byte offset offset of value. Solidity stores bools as a 0 or 1 byte, any
+// other byte is rejected.
+func ExtractBool(api frontend.API, value Word, offset int) frontend.Variable {
+	b := ExtractBytes(api, value, offset, 1)
+	api.AssertIsBoolean(b)
+	return b
+}
+
+// wordBits returns the 256 bits of value, least significant bit first
+func wordBits(api frontend.API, value Word) []frontend.Variable {
+	return append(api.ToBinary(value[1], 128), api.ToBinary(value[0], 128)...)
+}
@@ -0,0 +1,67 @@
+package slot
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+type packedValueCircuit struct {
+	Value   Word
+	Owner   frontend.Variable `gnark:",public"`
+	Expiry  frontend.Variable `gnark:",public"`
+	Paused  frontend.Variable `gnark:",public"`
+	Enabled frontend.Variable `gnark:",public"`
+	Hi      frontend.Variable `gnark:",public"`
+	Lo      frontend.Variable `gnark:",public"`
+}
+
+func (c *packedValueCircuit) Define(api frontend.API) error {
+	// address owner; uint64 expiry; bool paused; bool enabled;
+	api.AssertIsEqual(ExtractAddress(api, c.Value), c.Owner)
+	api.AssertIsEqual(ExtractBytes(api, c.Value, 20, 8), c.Expiry)
+	api.AssertIsEqual(ExtractBool(api, c.Value, 28), c.Paused)
+	api.AssertIsEqual(ExtractBool(api, c.Value, 29), c.Enabled)
+	hi, lo := ExtractUint128s(api, c.Value)
+	api.AssertIsEqual(hi, c.Hi)
+	api.AssertIsEqual(lo, c.Lo)
+	return nil
+}
+
+func TestPackedValue(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	owner := ethcommon.HexToAddress("0x881D40237659C251811CEC9c364ef91dC08D300C")
+	expiry := uint64(0xfedcba9876543210)
+	var value ethcommon.Hash
+	copy(value[12:], owner.Bytes())
+	new(big.Int).SetUint64(expiry).FillBytes(value[4:12])
+	value[3] = 0
+	value[2] = 1
+
+	w := &packedValueCircuit{
+		Value:   toWord(value),
+		Owner:   owner.Big(),
+		Expiry:  expiry,
+		Paused:  0,
+		Enabled: 1,
+		Hi:      new(big.Int).SetBytes(value[:16]),
+		Lo:      new(big.Int).SetBytes(value[16:]),
+	}
+	assert.NoError(test.IsSolved(&packedValueCircuit{}, w, ecc.BN254.ScalarField()))
+
+	// a bool byte other than 0 or 1 is rejected
+	value[2] = 2
+	w.Value = toWord(value)
+	w.Hi = new(big.Int).SetBytes(value[:16])
+	assert.Error(test.IsSolved(&packedValueCircuit{}, w, ecc.BN254.ScalarField()))
+
+	// limbs must fit into 128 bits
+	w.Value = Word{0, new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 128), owner.Big())}
+	w.Expiry, w.Enabled, w.Hi, w.Lo = 0, 0, 0, w.Value[1]
+	assert.Error(test.IsSolved(&packedValueCircuit{}, w, ecc.BN254.ScalarField()))
+}
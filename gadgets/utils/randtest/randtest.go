@@ -0,0 +1,45 @@
+// Package randtest checks gadgets against their native counterparts on random inputs
+package randtest
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// SeedEnv overrides the random seed of Check, to replay a failure
+const SeedEnv = "RANDTEST_SEED"
+
+// Check runs n random tests of a gadget. For each test, assign draws random inputs from rng, computes the expected
+// outputs with the native implementation and returns the full assignment of circuit, which must solve it. Failures
+// report the seed to replay them with SeedEnv.
+func Check(t *testing.T, n int, circuit frontend.Circuit, assign func(rng *rand.Rand) frontend.Circuit) {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	if s, ok := os.LookupEnv(SeedEnv); ok {
+		var err error
+		if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			t.Fatalf("invalid %s %q: %v", SeedEnv, s, err)
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		witness := assign(rng)
+		if err := test.IsSolved(circuit, witness, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("random test %d of %d failed, %s=%d: %v", i, n, SeedEnv, seed, err)
+		}
+	}
+}
+
+// Bytes returns n random bytes
+func Bytes(rng *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	rng.Read(b)
+	return b
+}